@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Yiheyistm/go-restful-api/internal/database"
+	"github.com/Yiheyistm/go-restful-api/internal/env"
+	"github.com/Yiheyistm/go-restful-api/internal/payment"
+
+	_ "github.com/joho/godotenv/autoload"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+const usage = `Usage: admin <command> [flags]
+
+Commands:
+  backup       take a hot backup into the backup directory and rotate old ones
+  verify       run PRAGMA integrity_check on a backup file
+  restore      restore the live database from a verified backup file
+  grant-admin  give a user admin rights (use -revoke to take them away)
+  purge        hard-delete events and users soft-deleted before the retention period
+  pay          send a signed local-provider webhook settling (or failing) an order`
+
+func main() {
+	if len(os.Args) < 2 {
+		log.Fatal(usage)
+	}
+
+	ctx := context.Background()
+	args := os.Args[2:]
+
+	switch os.Args[1] {
+	case "backup":
+		backup(ctx, args)
+	case "verify":
+		verify(ctx, args)
+	case "restore":
+		restore(ctx, args)
+	case "grant-admin":
+		grantAdmin(args)
+	case "purge":
+		purge(args)
+	case "pay":
+		pay(args)
+	default:
+		log.Fatal(usage)
+	}
+}
+
+func backup(ctx context.Context, args []string) {
+	fs := flag.NewFlagSet("backup", flag.ExitOnError)
+	dir := fs.String("dir", env.GetEnvString("BACKUP_DIR", "./backups"), "directory to write backups to")
+	method := fs.String("method", "backup", "backup (online backup API) or vacuum (VACUUM INTO)")
+	keep := fs.Int("keep", env.GetEnvInt("BACKUP_KEEP", 7), "number of backups to keep in the directory")
+	fs.Parse(args)
+
+	if err := os.MkdirAll(*dir, 0o750); err != nil {
+		log.Fatal("Failed to create backup directory: ", err)
+	}
+
+	db := openDatabase()
+	defer db.Close()
+
+	path := filepath.Join(*dir, database.BackupFileName("backup", time.Now()))
+	var err error
+	switch *method {
+	case "backup":
+		err = db.Backup(ctx, path)
+	case "vacuum":
+		err = db.VacuumInto(ctx, path)
+	default:
+		log.Fatal("Invalid backup method. Please use 'backup' or 'vacuum'.")
+	}
+	if err != nil {
+		os.Remove(path)
+		log.Fatal("Failed to back up database: ", err)
+	}
+
+	if err := database.Verify(ctx, path); err != nil {
+		os.Rename(path, path+".corrupt")
+		log.Fatal("Backup failed verification: ", err)
+	}
+	log.Println("Backup written to", path)
+
+	removed, err := database.RotateBackups(*dir, "backup", *keep)
+	if err != nil {
+		log.Fatal("Failed to rotate backups: ", err)
+	}
+	for _, path := range removed {
+		log.Println("Removed old backup", path)
+	}
+}
+
+func verify(ctx context.Context, args []string) {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		log.Fatal("Usage: admin verify <backup file>")
+	}
+
+	if err := database.Verify(ctx, fs.Arg(0)); err != nil {
+		log.Fatal(err)
+	}
+	log.Println("Backup is OK.")
+}
+
+func restore(ctx context.Context, args []string) {
+	fs := flag.NewFlagSet("restore", flag.ExitOnError)
+	dir := fs.String("dir", env.GetEnvString("BACKUP_DIR", "./backups"), "directory to save the pre-restore copy to")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		log.Fatal("Usage: admin restore [-dir backups] <backup file>")
+	}
+
+	if err := os.MkdirAll(*dir, 0o750); err != nil {
+		log.Fatal("Failed to create backup directory: ", err)
+	}
+
+	db := openDatabase()
+	defer db.Close()
+
+	// Keep whatever is live right now, so a bad restore can be undone.
+	safetyCopy := filepath.Join(*dir, database.BackupFileName("pre-restore", time.Now()))
+	if err := db.Restore(ctx, fs.Arg(0), safetyCopy); err != nil {
+		log.Fatal("Failed to restore database: ", err)
+	}
+	log.Printf("Database restored from %s (previous state saved to %s).", fs.Arg(0), safetyCopy)
+}
+
+func grantAdmin(args []string) {
+	fs := flag.NewFlagSet("grant-admin", flag.ExitOnError)
+	revoke := fs.Bool("revoke", false, "remove admin rights instead of granting them")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		log.Fatal("Usage: admin grant-admin [-revoke] <email>")
+	}
+
+	db := openDatabase()
+	defer db.Close()
+
+	models := database.NewModels(db)
+	if err := models.Users.SetAdmin(cliActor("grant-admin"), fs.Arg(0), !*revoke); err != nil {
+		log.Fatal(fmt.Sprintf("Failed to update %s: ", fs.Arg(0)), err)
+	}
+	log.Println("Updated admin rights for", fs.Arg(0))
+}
+
+func purge(args []string) {
+	fs := flag.NewFlagSet("purge", flag.ExitOnError)
+	retention := fs.Duration("retention", env.GetEnvDuration("SOFT_DELETE_RETENTION", 30*24*time.Hour), "how long soft-deleted rows are kept")
+	fs.Parse(args)
+
+	db := openDatabase()
+	defer db.Close()
+
+	models := database.NewModels(db)
+	cutoff := time.Now().Add(-*retention)
+	actor := cliActor("purge")
+	events, err := models.Events.Purge(actor, cutoff)
+	if err != nil {
+		log.Fatal("Failed to purge events: ", err)
+	}
+	users, err := models.Users.Purge(actor, cutoff)
+	if err != nil {
+		log.Fatal("Failed to purge users: ", err)
+	}
+	log.Printf("Purged %d events and %d users deleted before %s.", events, users, cutoff.Format(time.RFC3339))
+}
+
+func pay(args []string) {
+	fs := flag.NewFlagSet("pay", flag.ExitOnError)
+	url := fs.String("url", "http://localhost:8080/api/v1/payments/webhook", "payment webhook endpoint of the API")
+	secret := fs.String("secret", env.GetEnvString("PAYMENT_WEBHOOK_SECRET", "some_webhook_secret_123"), "webhook signing secret")
+	fail := fs.Bool("fail", false, "report the payment as failed instead of succeeded")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		log.Fatal("Usage: admin pay [-fail] [-url url] <provider reference>")
+	}
+
+	event := payment.WebhookEvent{Type: payment.EventSucceeded, Reference: fs.Arg(0)}
+	if *fail {
+		event.Type = payment.EventFailed
+	}
+	body, signature, err := payment.LocalProvider{Secret: *secret}.SignWebhook(event, time.Now())
+	if err != nil {
+		log.Fatal("Failed to sign webhook: ", err)
+	}
+	req, err := http.NewRequest(http.MethodPost, *url, bytes.NewReader(body))
+	if err != nil {
+		log.Fatal("Failed to build webhook request: ", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(payment.SignatureHeader, signature)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		log.Fatal("Failed to send webhook: ", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		log.Fatal("Webhook was rejected: ", resp.Status)
+	}
+	log.Printf("Sent %s for %s.", event.Type, event.Reference)
+}
+
+// cliActor is who the changes made by command are audited against: no
+// user, but the command and the account running it.
+func cliActor(command string) database.Actor {
+	requestID := "admin " + command
+	if name := os.Getenv("USER"); name != "" {
+		requestID += " by " + name
+	}
+	return database.Actor{RequestID: requestID}
+}
+
+func openDatabase() *database.DB {
+	db, err := database.Open(database.ConfigFromEnv())
+	if err != nil {
+		log.Fatal("Failed to connect to the database: ", err)
+	}
+	return db
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/Yiheyistm/go-restful-api/internal/database"
+	"github.com/gin-gonic/gin"
+)
+
+// DownloadBackup streams a consistent snapshot of the database
+//
+//	@Summary		Streams a database snapshot
+//	@Description	Takes a VACUUM INTO snapshot of the live database and streams it as a file download
+//	@Tags			admin
+//	@Produce		application/octet-stream
+//	@Success		200
+//	@Router			/api/v1/admin/backup [get]
+//	@Security		BearerAuth
+func (app *application) downloadBackup(c *gin.Context) {
+	dir, err := os.MkdirTemp("", "snapshot")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to prepare snapshot"})
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	name := database.BackupFileName("snapshot", time.Now())
+	path := filepath.Join(dir, name)
+	if err := app.DB.VacuumInto(c.Request.Context(), path); err != nil {
+		log.Println("Snapshot error:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create snapshot"})
+		return
+	}
+
+	// Large databases can take longer than the server's WriteTimeout to send.
+	if err := http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{}); err != nil {
+		log.Println("Snapshot deadline error:", err)
+	}
+	c.FileAttachment(path, name)
+}
+
+// DeleteUser soft-deletes a user
+//
+//	@Summary		Soft-deletes a user
+//	@Description	Hides a user, their logins and their attendances until restored or purged
+//	@Tags			admin
+//	@Param			id	path	int	true	"User ID"
+//	@Success		204
+//	@Router			/api/v1/admin/users/{id} [delete]
+//	@Security		BearerAuth
+func (app *application) deleteUser(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+	if _, err := app.Model.Users.Get(id); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if err := app.Model.Users.Delete(app.actor(c), id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete user"})
+		return
+	}
+	c.JSON(http.StatusNoContent, nil)
+}
+
+// RestoreUser restores a soft-deleted user
+//
+//	@Summary		Restores a soft-deleted user
+//	@Description	Restores a user deleted within the grace period
+//	@Tags			admin
+//	@Produce		json
+//	@Param			id	path		int	true	"User ID"
+//	@Success		200	{object}	database.User
+//	@Failure		404
+//	@Router			/api/v1/admin/users/{id}/restore [post]
+//	@Security		BearerAuth
+func (app *application) restoreUser(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+	err = app.Model.Users.Restore(app.actor(c), id, time.Now().Add(-app.RestoreGracePeriod))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "No user deleted within the grace period has this ID"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore user"})
+		return
+	}
+	user, err := app.Model.Users.Get(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve user"})
+		return
+	}
+	c.JSON(http.StatusOK, user)
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Yiheyistm/go-restful-api/internal/database"
+	"github.com/Yiheyistm/go-restful-api/internal/markdown"
+	"github.com/Yiheyistm/go-restful-api/internal/notify"
+	"github.com/gin-gonic/gin"
+)
+
+type createAnnouncementRequest struct {
+	Subject string `json:"subject" binding:"required,max=200"`
+	// Body is Markdown.
+	Body string `json:"body" binding:"required,max=20000"`
+}
+
+// CreateAnnouncement posts an announcement to an event's attendees
+//
+//	@Summary		Posts an announcement to an event's attendees
+//	@Description	Stores a message and sends it to everyone attending any occurrence of the event, in their inbox and by email, as their notification preferences allow. The body is Markdown and is also returned as sanitized HTML. An event may post a limited number of announcements in a rolling window; past it the request is answered 429 with Retry-After.
+//	@Tags			events
+//	@Accept			json
+//	@Produce		json
+//	@Param			id				path		int							true	"Event ID"
+//	@Param			announcement	body		createAnnouncementRequest	true	"Announcement"
+//	@Success		201				{object}	database.Announcement
+//	@Failure		409
+//	@Failure		429
+//	@Router			/api/v1/events/{id}/announcements [post]
+//	@Security		BearerAuth
+func (app *application) createAnnouncement(c *gin.Context) {
+	event, ok := app.managedEvent(c, database.PermissionEdit)
+	if !ok {
+		return
+	}
+	if event.Status == database.EventStatusDraft {
+		c.JSON(http.StatusConflict, gin.H{"error": "Announcements can only be posted once the event is published"})
+		return
+	}
+	var request createAnnouncementRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	html, err := markdown.Render(request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to render announcement"})
+		return
+	}
+
+	user := app.GetUserFromContext(c)
+	announcement := &database.Announcement{
+		EventID:  event.ID,
+		AuthorID: &user.ID,
+		Subject:  request.Subject,
+		Body:     request.Body,
+		BodyHTML: html,
+	}
+	retryAt, err := app.Model.Announcements.Insert(announcement, app.AnnouncementLimit, app.AnnouncementWindow)
+	if errors.Is(err, database.ErrRateLimited) {
+		wait := math.Ceil(time.Until(retryAt).Seconds())
+		c.Header("Retry-After", strconv.Itoa(max(int(wait), 1)))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": fmt.Sprintf("An event can post %d announcements every %s", app.AnnouncementLimit, app.AnnouncementWindow)})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to post announcement"})
+		return
+	}
+	c.JSON(http.StatusCreated, announcement)
+}
+
+// GetAnnouncements lists the announcements of an event
+//
+//	@Summary		Lists the announcements of an event
+//	@Description	Lists the announcements posted to an event's attendees, newest first. Only its attendees and organizers can read them.
+//	@Tags			events
+//	@Produce		json
+//	@Param			id		path	int	true	"Event ID"
+//	@Param			limit	query	int	false	"Maximum number of announcements (default 20, at most 100)"
+//	@Param			offset	query	int	false	"Number of announcements to skip"
+//	@Success		200		{array}	database.Announcement
+//	@Router			/api/v1/events/{id}/announcements [get]
+//	@Security		BearerAuth
+func (app *application) getAnnouncements(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		return
+	}
+	event, err := app.Model.Events.GetByID(id)
+	if err != nil || !app.canViewEvent(c, event) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+	if app.eventRole(c, event) == "" {
+		attending, err := app.Model.Attendees.IsAttending(event.ID, app.GetUserFromContext(c).ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check attendance"})
+			return
+		}
+		if !attending {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Only attendees can read the announcements of this event"})
+			return
+		}
+	}
+
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	offset, _ := strconv.Atoi(c.Query("offset"))
+	announcements, err := app.Model.Announcements.GetByEvent(event.ID, limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve announcements"})
+		return
+	}
+	c.JSON(http.StatusOK, announcements)
+}
+
+// emailAnnouncement is the JobAnnouncementEmail handler. It queues an
+// email of the announcement to each attendee who wants email, all at
+// once, so a retry never sends one twice.
+func (app *application) emailAnnouncement(ctx context.Context, job *database.Job) error {
+	var payload database.AnnouncementJob
+	if err := json.Unmarshal(job.Payload, &payload); err != nil {
+		return err
+	}
+	announcement, err := app.Model.Announcements.Get(payload.AnnouncementID)
+	if err != nil {
+		return err
+	}
+	if announcement == nil {
+		return nil
+	}
+	event, err := app.Model.Events.GetByID(announcement.EventID)
+	if err != nil {
+		return err
+	}
+	recipients, err := app.Model.Announcements.EmailRecipients(announcement)
+	if err != nil {
+		return err
+	}
+
+	jobs := make([]*database.Job, 0, len(recipients))
+	for _, recipient := range recipients {
+		job, err := database.NewJob(jobNotify, notify.Message{
+			UserID:  recipient.UserID,
+			Email:   recipient.Email,
+			EventID: event.ID,
+			Subject: fmt.Sprintf("%s: %s", event.Name, announcement.Subject),
+			Body:    announcement.Body,
+		})
+		if err != nil {
+			return err
+		}
+		jobs = append(jobs, job)
+	}
+	if err := app.Model.Announcements.QueueEmails(announcement, jobs); err != nil {
+		return err
+	}
+	if len(jobs) > 0 {
+		log.Printf("Queued announcement %d to %d attendees of event %d", announcement.ID, len(jobs), event.ID)
+	}
+	return nil
+}
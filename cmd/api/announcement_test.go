@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Yiheyistm/go-restful-api/internal/database"
+)
+
+// insertConference inserts a published event of owner.
+func (app *application) insertConference(t *testing.T, owner *database.User) *database.Event {
+	t.Helper()
+	event := &database.Event{
+		OwnerId:     owner.ID,
+		Name:        "Coffee conference",
+		Description: "Growers, roasters and a cupping contest",
+		Date:        "2026-12-03",
+		Location:    "Jimma",
+		Status:      database.EventStatusPublished,
+	}
+	if err := app.Model.Events.Insert(database.Actor{}, event); err != nil {
+		t.Fatal(err)
+	}
+	return event
+}
+
+func TestAnnouncementRateLimit(t *testing.T) {
+	app := newTestApp(t)
+	handler := app.routes()
+	owner, token := app.signUp(t, "convener")
+	event := app.insertConference(t, owner)
+	other := app.insertConference(t, owner)
+
+	post := func(event *database.Event, subject string) *http.Response {
+		t.Helper()
+		body := fmt.Sprintf(`{"subject": %q, "body": "See you there"}`, subject)
+		return serve(t, handler, http.MethodPost, fmt.Sprintf("/api/v1/events/%d/announcements", event.ID), token, body).Result()
+	}
+
+	for i := range app.AnnouncementLimit {
+		if res := post(event, fmt.Sprintf("Update %d", i+1)); res.StatusCode != http.StatusCreated {
+			t.Fatalf("announcement %d = %d, want 201", i+1, res.StatusCode)
+		}
+	}
+	res := post(event, "One too many")
+	if res.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("announcement past the limit = %d, want 429", res.StatusCode)
+	}
+	retryAfter, err := strconv.Atoi(res.Header.Get("Retry-After"))
+	if err != nil || retryAfter <= 0 || retryAfter > int(app.AnnouncementWindow.Seconds()) {
+		t.Errorf("Retry-After = %q, want seconds within the window", res.Header.Get("Retry-After"))
+	}
+	if res := post(other, "Different event"); res.StatusCode != http.StatusCreated {
+		t.Errorf("announcement of another event = %d, want 201", res.StatusCode)
+	}
+
+	// Once the oldest one leaves the window, there is room for one more.
+	_, err = app.Model.Announcements.DB.Exec(`UPDATE announcements SET created_at = ? WHERE event_id = ? AND subject = 'Update 1'`,
+		time.Now().UTC().Add(-app.AnnouncementWindow-time.Minute), event.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res := post(event, "Room again"); res.StatusCode != http.StatusCreated {
+		t.Errorf("announcement after the window moved = %d, want 201", res.StatusCode)
+	}
+	if res := post(event, "Full again"); res.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("announcement past the limit again = %d, want 429", res.StatusCode)
+	}
+}
+
+func TestAnnouncementBodyIsSanitized(t *testing.T) {
+	app := newTestApp(t)
+	handler := app.routes()
+	owner, token := app.signUp(t, "convener")
+	event := app.insertConference(t, owner)
+
+	body := `{"subject": "Schedule", "body": "**Cupping** at ten <script>steal()</script> [map](javascript:steal())"}`
+	w := serve(t, handler, http.MethodPost, fmt.Sprintf("/api/v1/events/%d/announcements", event.ID), token, body)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("post announcement = %d: %s", w.Code, w.Body)
+	}
+	var announcement database.Announcement
+	if err := json.Unmarshal(w.Body.Bytes(), &announcement); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(announcement.BodyHTML, "<strong>Cupping</strong>") {
+		t.Errorf("body_html = %q, want the Markdown rendered", announcement.BodyHTML)
+	}
+	if strings.Contains(announcement.BodyHTML, "<script") || strings.Contains(announcement.BodyHTML, "javascript:") {
+		t.Errorf("body_html = %q, want scripts and unsafe links dropped", announcement.BodyHTML)
+	}
+	if !strings.Contains(announcement.Body, "<script>") {
+		t.Errorf("body = %q, want the source kept as written", announcement.Body)
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Yiheyistm/go-restful-api/internal/database"
+	"github.com/gin-gonic/gin"
+)
+
+// actor is who the changes made by the request are audited against.
+func (app *application) actor(c *gin.Context) database.Actor {
+	return database.Actor{
+		UserID:    app.GetUserFromContext(c).ID,
+		RequestID: app.GetRequestIDFromContext(c),
+	}
+}
+
+// GetEventHistory returns the audit trail of an event
+//
+//	@Summary		Returns the audit trail of an event
+//	@Description	Returns every recorded change to an event and its attendees, newest first. Only its owner, co-hosts or an admin may read it.
+//	@Tags			events
+//	@Produce		json
+//	@Param			id		path		int	true	"Event ID"
+//	@Param			limit	query		int	false	"Maximum number of entries (default 100)"
+//	@Param			offset	query		int	false	"Number of entries to skip"
+//	@Success		200		{array}		database.AuditEntry
+//	@Router			/api/v1/events/{id}/history [get]
+//	@Security		BearerAuth
+func (app *application) getEventHistory(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		return
+	}
+	user := app.GetUserFromContext(c)
+	event, err := app.Model.Events.GetByID(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+	if !app.canManageEvent(c, event, database.PermissionEdit) && !user.IsAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You do not have permission to view this event's history"})
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	offset, _ := strconv.Atoi(c.Query("offset"))
+	entries, err := app.Model.Audit.Find(database.AuditFilter{EventID: id, Limit: limit, Offset: offset})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve history"})
+		return
+	}
+	c.JSON(http.StatusOK, entries)
+}
+
+// GetAuditLog searches the audit log
+//
+//	@Summary		Searches the audit log
+//	@Description	Returns audit entries matching the given filters, newest first
+//	@Tags			admin
+//	@Produce		json
+//	@Param			entity_type	query		string	false	"user, event or attendee"
+//	@Param			entity_id	query		int		false	"Entity ID"
+//	@Param			event_id	query		int		false	"Event the change belongs to"
+//	@Param			actor_id	query		int		false	"User who made the change"
+//	@Param			action		query		string	false	"create, update or delete"
+//	@Param			request_id	query		string	false	"Request ID"
+//	@Param			since		query		string	false	"RFC 3339 lower bound (inclusive)"
+//	@Param			until		query		string	false	"RFC 3339 upper bound (exclusive)"
+//	@Param			limit		query		int		false	"Maximum number of entries (default 100)"
+//	@Param			offset		query		int		false	"Number of entries to skip"
+//	@Success		200			{array}		database.AuditEntry
+//	@Router			/api/v1/admin/audit [get]
+//	@Security		BearerAuth
+func (app *application) getAuditLog(c *gin.Context) {
+	filter := database.AuditFilter{
+		EntityType: c.Query("entity_type"),
+		Action:     c.Query("action"),
+		RequestID:  c.Query("request_id"),
+	}
+	for name, target := range map[string]*int{
+		"entity_id": &filter.EntityID,
+		"event_id":  &filter.EventID,
+		"actor_id":  &filter.ActorID,
+		"limit":     &filter.Limit,
+		"offset":    &filter.Offset,
+	} {
+		if value := c.Query(name); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + name})
+				return
+			}
+			*target = n
+		}
+	}
+	for name, target := range map[string]*time.Time{
+		"since": &filter.Since,
+		"until": &filter.Until,
+	} {
+		if value := c.Query(name); value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + name + ", expected RFC 3339"})
+				return
+			}
+			*target = t
+		}
+	}
+
+	entries, err := app.Model.Audit.Find(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve audit log"})
+		return
+	}
+	c.JSON(http.StatusOK, entries)
+}
@@ -98,7 +98,7 @@ func (app *application) registerUser(c *gin.Context) {
 		Password: registerPassword,
 		Email:    register.Email,
 	}
-	err = app.Model.Users.Insert(&user)
+	err = app.Model.Users.Insert(app.actor(c), &user)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"Error": "Couldn't create user"})
 		return
@@ -0,0 +1,393 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Yiheyistm/go-restful-api/internal/database"
+	"github.com/gin-gonic/gin"
+)
+
+// maxMentions caps how many users one comment can notify.
+const maxMentions = 10
+
+// mentionPattern matches "@name" at the start of a comment or after
+// whitespace, so e-mail addresses are not taken for mentions.
+var mentionPattern = regexp.MustCompile(`(?:^|\s)@([\pL\pN_.-]+)`)
+
+type createCommentRequest struct {
+	Body string `json:"body" binding:"required,max=5000"`
+	// ParentID is the comment this one replies to.
+	ParentID *int `json:"parent_id"`
+}
+
+type updateCommentRequest struct {
+	Body string `json:"body" binding:"required,max=5000"`
+}
+
+type reportCommentRequest struct {
+	Reason string `json:"reason" binding:"required,max=1000"`
+}
+
+// mentionedNames returns the distinct names mentioned in body, at most
+// maxMentions of them.
+func mentionedNames(body string) []string {
+	seen := map[string]bool{}
+	var names []string
+	for _, match := range mentionPattern.FindAllStringSubmatch(body, -1) {
+		name := strings.TrimRight(match[1], ".-")
+		if len(name) < 2 || seen[strings.ToLower(name)] {
+			continue
+		}
+		seen[strings.ToLower(name)] = true
+		names = append(names, name)
+		if len(names) == maxMentions {
+			break
+		}
+	}
+	return names
+}
+
+// notifyMentions tells the participants of the event mentioned in
+// comment, other than its author, in their inbox. A user is told about a
+// comment once, however often it is edited.
+func (app *application) notifyMentions(event *database.Event, comment *database.Comment, author *database.User) {
+	ids, err := app.Model.Comments.Participants(event.ID, mentionedNames(comment.Body))
+	if err != nil {
+		log.Printf("Failed to resolve mentions in comment %d: %v", comment.ID, err)
+		return
+	}
+	for _, id := range ids {
+		if id == author.ID {
+			continue
+		}
+		err := app.Model.Notifications.Insert(&database.Notification{
+			UserID:  id,
+			Type:    database.NotificationMention,
+			EventID: &event.ID,
+			Title:   fmt.Sprintf("%s mentioned you on %s", author.Username, event.Name),
+			Body:    comment.Body,
+			Key:     fmt.Sprintf("%s:%d", database.NotificationMention, comment.ID),
+		})
+		if err != nil {
+			log.Printf("Failed to notify user %d of comment %d: %v", id, comment.ID, err)
+		}
+	}
+}
+
+// canModerate reports whether the caller may remove others' comments on
+// event and deal with reports: its owner and co-hosts, and admins.
+func (app *application) canModerate(c *gin.Context, event *database.Event) bool {
+	return app.GetUserFromContext(c).IsAdmin || app.canManageEvent(c, event, database.PermissionEdit)
+}
+
+// viewableEvent loads the event named by the id parameter when the caller
+// can see it, answering the request itself when not.
+func (app *application) viewableEvent(c *gin.Context) (*database.Event, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		return nil, false
+	}
+	event, err := app.Model.Events.GetByID(id)
+	if err != nil || !app.canViewEvent(c, event) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return nil, false
+	}
+	return event, true
+}
+
+// eventComment loads the comment named by the commentId parameter on
+// event, answering the request itself when there is none.
+func (app *application) eventComment(c *gin.Context, event *database.Event) (*database.Comment, bool) {
+	id, err := strconv.Atoi(c.Param("commentId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid comment ID"})
+		return nil, false
+	}
+	comment, err := app.Model.Comments.Get(event.ID, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve comment"})
+		return nil, false
+	}
+	if comment == nil || comment.DeletedAt != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Comment not found"})
+		return nil, false
+	}
+	return comment, true
+}
+
+// GetComments lists the discussion of an event
+//
+//	@Summary		Lists the discussion of an event
+//	@Description	Lists threads of comments on an event, newest thread first, each with its replies nested under the comment they answer, oldest first. Deleted comments keep their place without their body or author.
+//	@Tags			comments
+//	@Produce		json
+//	@Param			id		path	int		true	"Event ID"
+//	@Param			limit	query	int		false	"Maximum number of threads (default 20, at most 100)"
+//	@Param			offset	query	int		false	"Number of threads to skip"
+//	@Param			invite	query	string	false	"Invite token for a private event"
+//	@Success		200		{array}	database.Comment
+//	@Router			/api/v1/events/{id}/comments [get]
+func (app *application) getComments(c *gin.Context) {
+	event, ok := app.viewableEvent(c)
+	if !ok {
+		return
+	}
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	offset, _ := strconv.Atoi(c.Query("offset"))
+	threads, err := app.Model.Comments.GetThreads(event.ID, limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve comments"})
+		return
+	}
+	c.JSON(http.StatusOK, threads)
+}
+
+// CreateComment posts a comment on an event
+//
+//	@Summary		Posts a comment on an event
+//	@Description	Posts a comment, or with parent_id a reply to one. Only attendees and organizers can comment on private events. Attendees and organizers mentioned as @name are notified.
+//	@Tags			comments
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int						true	"Event ID"
+//	@Param			comment	body		createCommentRequest	true	"Comment"
+//	@Success		201		{object}	database.Comment
+//	@Router			/api/v1/events/{id}/comments [post]
+//	@Security		BearerAuth
+func (app *application) createComment(c *gin.Context) {
+	event, ok := app.viewableEvent(c)
+	if !ok {
+		return
+	}
+	user := app.GetUserFromContext(c)
+	if event.Visibility == database.EventVisibilityPrivate && app.eventRole(c, event) == "" {
+		attending, err := app.Model.Attendees.IsAttending(event.ID, user.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check attendance"})
+			return
+		}
+		if !attending {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Only attendees can comment on this event"})
+			return
+		}
+	}
+	var request createCommentRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if strings.TrimSpace(request.Body) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Comment cannot be empty"})
+		return
+	}
+
+	comment := &database.Comment{
+		EventID:  event.ID,
+		UserID:   &user.ID,
+		Username: user.Username,
+		ParentID: request.ParentID,
+		Body:     request.Body,
+	}
+	if err := app.Model.Comments.Insert(comment); err != nil {
+		if errors.Is(err, database.ErrInvalidParent) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Parent comment not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to post comment"})
+		return
+	}
+	app.notifyMentions(event, comment, user)
+	c.JSON(http.StatusCreated, comment)
+}
+
+// UpdateComment edits a comment
+//
+//	@Summary		Edits a comment
+//	@Description	Replaces the body of one of the caller's comments. Comments can only be edited for a while after they are posted. Users newly mentioned are notified.
+//	@Tags			comments
+//	@Accept			json
+//	@Produce		json
+//	@Param			id			path		int						true	"Event ID"
+//	@Param			commentId	path		int						true	"Comment ID"
+//	@Param			comment		body		updateCommentRequest	true	"New body"
+//	@Success		200			{object}	database.Comment
+//	@Failure		409
+//	@Router			/api/v1/events/{id}/comments/{commentId} [patch]
+//	@Security		BearerAuth
+func (app *application) updateComment(c *gin.Context) {
+	event, ok := app.viewableEvent(c)
+	if !ok {
+		return
+	}
+	comment, ok := app.eventComment(c, event)
+	if !ok {
+		return
+	}
+	user := app.GetUserFromContext(c)
+	if !comment.IsBy(user.ID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can only edit your own comments"})
+		return
+	}
+	var request updateCommentRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if strings.TrimSpace(request.Body) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Comment cannot be empty"})
+		return
+	}
+
+	err := app.Model.Comments.UpdateBody(app.actor(c), comment, request.Body, time.Now().Add(-app.CommentEditWindow))
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Comments can only be edited within %s of posting", app.CommentEditWindow)})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update comment"})
+		return
+	}
+	app.notifyMentions(event, comment, user)
+	c.JSON(http.StatusOK, comment)
+}
+
+// DeleteComment removes a comment
+//
+//	@Summary		Removes a comment
+//	@Description	Removes a comment, leaving its replies in place. Authors can remove their own comments; the event's owner and co-hosts and admins can remove any, which also resolves the reports against it.
+//	@Tags			comments
+//	@Param			id			path	int	true	"Event ID"
+//	@Param			commentId	path	int	true	"Comment ID"
+//	@Success		204
+//	@Router			/api/v1/events/{id}/comments/{commentId} [delete]
+//	@Security		BearerAuth
+func (app *application) deleteComment(c *gin.Context) {
+	event, ok := app.viewableEvent(c)
+	if !ok {
+		return
+	}
+	comment, ok := app.eventComment(c, event)
+	if !ok {
+		return
+	}
+	user := app.GetUserFromContext(c)
+	if !comment.IsBy(user.ID) && !app.canModerate(c, event) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You do not have permission to remove this comment"})
+		return
+	}
+	if err := app.Model.Comments.Delete(app.actor(c), comment); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Comment not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove comment"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// ReportComment reports a comment to the event's moderators
+//
+//	@Summary		Reports a comment to the event's moderators
+//	@Description	Flags a comment as abusive for the event's owner, co-hosts and admins to review. Reporting the same comment again replaces the reason.
+//	@Tags			comments
+//	@Accept			json
+//	@Produce		json
+//	@Param			id			path		int						true	"Event ID"
+//	@Param			commentId	path		int						true	"Comment ID"
+//	@Param			report		body		reportCommentRequest	true	"Reason"
+//	@Success		201			{object}	database.CommentReport
+//	@Router			/api/v1/events/{id}/comments/{commentId}/reports [post]
+//	@Security		BearerAuth
+func (app *application) reportComment(c *gin.Context) {
+	event, ok := app.viewableEvent(c)
+	if !ok {
+		return
+	}
+	comment, ok := app.eventComment(c, event)
+	if !ok {
+		return
+	}
+	user := app.GetUserFromContext(c)
+	if comment.IsBy(user.ID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot report your own comment"})
+		return
+	}
+	var request reportCommentRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	report := &database.CommentReport{CommentID: comment.ID, UserID: user.ID, Reason: request.Reason}
+	if err := app.Model.Comments.Report(report); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to report comment"})
+		return
+	}
+	c.JSON(http.StatusCreated, report)
+}
+
+// GetCommentReports lists the reported comments of an event
+//
+//	@Summary		Lists the reported comments of an event
+//	@Description	Lists the comments with open reports, most reported first, for the event's owner, co-hosts and admins
+//	@Tags			comments
+//	@Produce		json
+//	@Param			id	path	int	true	"Event ID"
+//	@Success		200	{array}	database.ReportedComment
+//	@Router			/api/v1/events/{id}/comments/reports [get]
+//	@Security		BearerAuth
+func (app *application) getCommentReports(c *gin.Context) {
+	event, ok := app.viewableEvent(c)
+	if !ok {
+		return
+	}
+	if !app.canModerate(c, event) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You do not have permission to moderate this event"})
+		return
+	}
+	reported, err := app.Model.Comments.GetReported(event.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve reports"})
+		return
+	}
+	c.JSON(http.StatusOK, reported)
+}
+
+// DismissCommentReports dismisses the reports against a comment
+//
+//	@Summary		Dismisses the reports against a comment
+//	@Description	Resolves the open reports against a comment and leaves it in place
+//	@Tags			comments
+//	@Param			id			path	int	true	"Event ID"
+//	@Param			commentId	path	int	true	"Comment ID"
+//	@Success		204
+//	@Router			/api/v1/events/{id}/comments/{commentId}/reports [delete]
+//	@Security		BearerAuth
+func (app *application) dismissCommentReports(c *gin.Context) {
+	event, ok := app.viewableEvent(c)
+	if !ok {
+		return
+	}
+	if !app.canModerate(c, event) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You do not have permission to moderate this event"})
+		return
+	}
+	comment, ok := app.eventComment(c, event)
+	if !ok {
+		return
+	}
+	if err := app.Model.Comments.DismissReports(comment.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to dismiss reports"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
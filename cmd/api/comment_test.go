@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Yiheyistm/go-restful-api/internal/database"
+)
+
+// insertBookClub inserts a published public event of owner.
+func (app *application) insertBookClub(t *testing.T, owner *database.User) *database.Event {
+	t.Helper()
+	event := &database.Event{
+		OwnerId:     owner.ID,
+		Name:        "Book club",
+		Description: "This month: Fikir Eske Mekabir",
+		Date:        "2026-11-26",
+		Location:    "Debre Markos",
+		Status:      database.EventStatusPublished,
+	}
+	if err := app.Model.Events.Insert(database.Actor{}, event); err != nil {
+		t.Fatal(err)
+	}
+	return event
+}
+
+// postComment posts body on event, as a reply to parent when it is not 0.
+func postComment(t *testing.T, handler http.Handler, event *database.Event, token, body string, parent int) *database.Comment {
+	t.Helper()
+	request := fmt.Sprintf(`{"body": %q}`, body)
+	if parent != 0 {
+		request = fmt.Sprintf(`{"body": %q, "parent_id": %d}`, body, parent)
+	}
+	w := serve(t, handler, http.MethodPost, fmt.Sprintf("/api/v1/events/%d/comments", event.ID), token, request)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("post comment = %d: %s", w.Code, w.Body)
+	}
+	var comment database.Comment
+	if err := json.Unmarshal(w.Body.Bytes(), &comment); err != nil {
+		t.Fatal(err)
+	}
+	return &comment
+}
+
+func TestCommentEditWindow(t *testing.T) {
+	app := newTestApp(t)
+	handler := app.routes()
+	owner, _ := app.signUp(t, "librarian")
+	_, readerToken := app.signUp(t, "reader")
+	_, otherToken := app.signUp(t, "other")
+	event := app.insertBookClub(t, owner)
+	comment := postComment(t, handler, event, readerToken, "Loved the first chapter", 0)
+
+	edit := func(token, body string) int {
+		t.Helper()
+		path := fmt.Sprintf("/api/v1/events/%d/comments/%d", event.ID, comment.ID)
+		return serve(t, handler, http.MethodPatch, path, token, fmt.Sprintf(`{"body": %q}`, body)).Code
+	}
+
+	if code := edit(otherToken, "Hijacked"); code != http.StatusForbidden {
+		t.Errorf("edit by someone else = %d, want 403", code)
+	}
+	if code := edit(readerToken, "Loved the first two chapters"); code != http.StatusOK {
+		t.Fatalf("edit within the window = %d, want 200", code)
+	}
+	edited, err := app.Model.Comments.Get(event.ID, comment.ID)
+	if err != nil || edited.Body != "Loved the first two chapters" || edited.EditedAt == nil {
+		t.Fatalf("comment after the edit = %+v, %v", edited, err)
+	}
+
+	_, err = app.Model.Comments.DB.Exec(`UPDATE comments SET created_at = ? WHERE id = ?`,
+		time.Now().UTC().Add(-app.CommentEditWindow-time.Minute), comment.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code := edit(readerToken, "Too late"); code != http.StatusConflict {
+		t.Errorf("edit after the window = %d, want 409", code)
+	}
+	if late, err := app.Model.Comments.Get(event.ID, comment.ID); err != nil || late.Body != "Loved the first two chapters" {
+		t.Errorf("comment after a late edit = %+v, %v; want it unchanged", late, err)
+	}
+}
+
+func TestPurgeKeepsRepliesOfAPurgedAuthor(t *testing.T) {
+	app := newTestApp(t)
+	handler := app.routes()
+	owner, ownerToken := app.signUp(t, "librarian")
+	leaver, leaverToken := app.signUp(t, "leaver")
+	_, stayerToken := app.signUp(t, "stayer")
+	event := app.insertBookClub(t, owner)
+
+	root := postComment(t, handler, event, ownerToken, "What did everyone think?", 0)
+	reply := postComment(t, handler, event, leaverToken, "Too long for me", root.ID)
+	answer := postComment(t, handler, event, stayerToken, "The ending made up for it", reply.ID)
+
+	if err := app.Model.Users.Delete(database.Actor{}, leaver.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := app.Model.Users.Purge(database.Actor{}, time.Now().Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+
+	w := serve(t, handler, http.MethodGet, fmt.Sprintf("/api/v1/events/%d/comments", event.ID), "", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET comments = %d: %s", w.Code, w.Body)
+	}
+	var threads []*database.Comment
+	if err := json.Unmarshal(w.Body.Bytes(), &threads); err != nil {
+		t.Fatal(err)
+	}
+	if len(threads) != 1 || len(threads[0].Replies) != 1 {
+		t.Fatalf("threads = %+v, want the thread with the purged reply in place", threads)
+	}
+	purged := threads[0].Replies[0]
+	if purged.ID != reply.ID || purged.DeletedAt == nil || purged.UserID != nil || purged.Body != "" {
+		t.Errorf("purged reply = %+v, want it deleted without author or body", purged)
+	}
+	if len(purged.Replies) != 1 || purged.Replies[0].ID != answer.ID || purged.Replies[0].Body != answer.Body {
+		t.Errorf("replies to the purged reply = %+v, want the answer kept", purged.Replies)
+	}
+
+	var stored int
+	if err := app.Model.Comments.DB.QueryRow(`SELECT COUNT(*) FROM comments WHERE user_id IS NULL`).Scan(&stored); err != nil || stored != 1 {
+		t.Errorf("comments without an author = %d (%v), want the purged reply", stored, err)
+	}
+}
@@ -16,3 +16,7 @@ func (app *application) GetUserFromContext(c *gin.Context) *database.User {
 	}
 	return user
 }
+
+func (app *application) GetRequestIDFromContext(c *gin.Context) string {
+	return c.GetString("requestId")
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Yiheyistm/go-restful-api/internal/database"
+	"github.com/gin-gonic/gin"
+)
+
+// eventETag identifies one revision of an event. The version column is
+// bumped by every successful EventModel.Update. Reviews change the rating
+// without touching the event, so the rating is part of the tag too.
+func eventETag(event *database.Event) string {
+	if event.Rating != nil {
+		return fmt.Sprintf(`"%d.%d.%d-%g"`, event.ID, event.Version, event.Rating.Count, event.Rating.Average)
+	}
+	return fmt.Sprintf(`"%d.%d"`, event.ID, event.Version)
+}
+
+// etagMatches reports whether a If-Match / If-None-Match header value
+// names etag. If-None-Match uses the weak comparison, so weak validators
+// are compared by their opaque part; If-Match needs a strong one, so a
+// weak validator never matches (RFC 7232, section 3.1).
+func etagMatches(header, etag string, weak bool) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if weak {
+			candidate = strings.TrimPrefix(candidate, "W/")
+		}
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// checkIfMatch answers 412 Precondition Failed and returns false when the
+// request carries an If-Match header that does not match etag.
+func checkIfMatch(c *gin.Context, etag string) bool {
+	ifMatch := c.GetHeader("If-Match")
+	if ifMatch == "" || etagMatches(ifMatch, etag, false) {
+		return true
+	}
+	c.Header("ETag", etag)
+	c.JSON(http.StatusPreconditionFailed, gin.H{"error": "Event has been modified since it was retrieved"})
+	return false
+}
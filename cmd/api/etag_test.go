@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/Yiheyistm/go-restful-api/internal/database"
+)
+
+func TestEtagMatches(t *testing.T) {
+	tests := []struct {
+		header string
+		weak   bool
+		want   bool
+	}{
+		{`"3.2"`, false, true},
+		{`"3.1"`, false, false},
+		{`"3.1", "3.2"`, false, true},
+		{`*`, false, true},
+		{`W/"3.2"`, false, false},
+		{`W/"3.2"`, true, true},
+		{`W/"3.1", W/"3.2"`, true, true},
+		{`3.2`, false, false},
+	}
+	for _, tt := range tests {
+		if got := etagMatches(tt.header, `"3.2"`, tt.weak); got != tt.want {
+			t.Errorf("etagMatches(%s, weak=%v) = %v, want %v", tt.header, tt.weak, got, tt.want)
+		}
+	}
+}
+
+func TestEventETagIncludesRating(t *testing.T) {
+	event := &database.Event{ID: 3, Version: 2}
+	plain := eventETag(event)
+	event.Rating = &database.RatingSummary{Count: 1, Average: 4}
+	rated := eventETag(event)
+	event.Rating = &database.RatingSummary{Count: 2, Average: 4.5}
+	if plain == rated || rated == eventETag(event) {
+		t.Errorf("ETags %s, %s and %s do not change with the rating", plain, rated, eventETag(event))
+	}
+}
+
+func TestEventPreconditions(t *testing.T) {
+	app := newTestApp(t)
+	routes := app.routes()
+	_, token := app.signUp(t, "planner")
+
+	rec := serve(t, routes, http.MethodPost, "/api/v1/events", token,
+		`{"name":"Board games","description":"Monthly board game night","date":"2026-11-05","location":"Library"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create = %d %s", rec.Code, rec.Body)
+	}
+	var event database.Event
+	if err := json.Unmarshal(rec.Body.Bytes(), &event); err != nil {
+		t.Fatal(err)
+	}
+	etag := rec.Header().Get("ETag")
+	path := fmt.Sprintf("/api/v1/events/%d", event.ID)
+
+	if rec := serve(t, routes, http.MethodGet, path, token, "", "If-None-Match", "W/"+etag); rec.Code != http.StatusNotModified {
+		t.Errorf("GET with a weak If-None-Match of the current tag = %d, want 304", rec.Code)
+	}
+
+	update := `{"name":"Board games","description":"Monthly board game night","date":"2026-11-06","location":"Library"}`
+	if rec := serve(t, routes, http.MethodPut, path, token, update, "If-Match", "W/"+etag); rec.Code != http.StatusPreconditionFailed {
+		t.Errorf("PUT with a weak If-Match = %d, want 412", rec.Code)
+	}
+	rec = serve(t, routes, http.MethodPut, path, token, update, "If-Match", etag)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("PUT with the current ETag = %d %s", rec.Code, rec.Body)
+	}
+	next := rec.Header().Get("ETag")
+	if next == etag {
+		t.Error("PUT did not change the ETag")
+	}
+	if rec := serve(t, routes, http.MethodPut, path, token, update, "If-Match", etag); rec.Code != http.StatusPreconditionFailed {
+		t.Errorf("PUT with a stale ETag = %d, want 412", rec.Code)
+	}
+	if rec := serve(t, routes, http.MethodDelete, path, token, "", "If-Match", etag); rec.Code != http.StatusPreconditionFailed {
+		t.Errorf("DELETE with a stale ETag = %d, want 412", rec.Code)
+	}
+	if rec := serve(t, routes, http.MethodDelete, path, token, "", "If-Match", next); rec.Code != http.StatusNoContent {
+		t.Errorf("DELETE with the current ETag = %d %s, want 204", rec.Code, rec.Body)
+	}
+}
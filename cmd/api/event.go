@@ -1,41 +1,93 @@
 package main
 
 import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
 	"log"
 	"net/http"
+	"reflect"
+	"slices"
 	"strconv"
+	"time"
 
 	"github.com/Yiheyistm/go-restful-api/internal/database"
+	jsonpatch "github.com/evanphx/json-patch/v5"
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 )
 
 // GetEvents returns all events
 //
 //	@Summary		Returns all events
-//	@Description	Returns all events
+//	@Description	Returns all events. With from and to, recurring events are expanded and every occurrence in the range is returned instead. format=geojson returns the events with coordinates as a FeatureCollection.
 //	@Tags			events
 //	@Accept			json
 //	@Produce		json
+//	@Param			from	query		string	false	"First day of the range (YYYY-MM-DD)"
+//	@Param			to		query		string	false	"Last day of the range (YYYY-MM-DD)"
+//	@Param			tag		query		[]string	false	"Only events carrying all of these tags"	collectionFormat(multi)
+//	@Param			status	query		string		false	"Only events in this status (draft, published, cancelled or completed)"
+//	@Param			format	query		string		false	"json (default) or geojson"
 //	@Success		200		{object}	[]database.Event
+//	@Success		200		{object}	[]database.Occurrence
 //	@Router			/api/v1/events [get]
 func (app *application) getAllEvents(c *gin.Context) {
-	events, err := app.Model.Events.GetAll()
+	filter, ok := app.eventFilterFromQuery(c)
+	if !ok {
+		return
+	}
+	if c.Query("from") != "" || c.Query("to") != "" {
+		app.getEventOccurrences(c, filter)
+		return
+	}
+	events, err := app.Model.Events.GetAll(filter)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve events"})
 		return
 	}
+	if wantsGeoJSON(c) {
+		c.JSON(http.StatusOK, eventsGeoJSON(events, func(e *database.Event) *database.Event { return e }))
+		return
+	}
 	c.JSON(http.StatusOK, events)
 }
 
+// eventFilterFromQuery builds the listing filter from the query string and
+// the caller, answering 400 itself when a parameter is invalid.
+func (app *application) eventFilterFromQuery(c *gin.Context) (database.EventFilter, bool) {
+	filter := database.EventFilter{ViewerID: app.GetUserFromContext(c).ID}
+	switch status := c.Query("status"); status {
+	case "", database.EventStatusDraft, database.EventStatusPublished, database.EventStatusCancelled, database.EventStatusCompleted:
+		filter.Status = status
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status"})
+		return filter, false
+	}
+	if tags := c.QueryArray("tag"); len(tags) > 0 {
+		normalized, err := database.NormalizeTags(tags)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return filter, false
+		}
+		filter.Tags = normalized
+	}
+	return filter, true
+}
+
 // GetEvent returns a single event
 //
 //	@Summary		Returns a single event
-//	@Description	Returns a single event
+//	@Description	Returns a single event. Private events need an invite token unless the caller owns or attends them.
 //	@Tags			events
 //	@Accept			json
 //	@Produce		json
-//	@Param			id	path		int	true	"Event ID"
-//	@Success		200	{object}	database.Event
+//	@Param			id				path		int		true	"Event ID"
+//	@Param			invite			query		string	false	"Invite token for a private event"
+//	@Param			If-None-Match	header		string	false	"ETag from a previous response"
+//	@Success		200				{object}	database.Event
+//	@Success		304
 //	@Router			/api/v1/events/{id} [get]
 func (app *application) getEventByID(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
@@ -44,17 +96,23 @@ func (app *application) getEventByID(c *gin.Context) {
 		return
 	}
 	event, err := app.Model.Events.GetByID(id)
-	if err != nil {
+	if err != nil || !app.canViewEvent(c, event) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Failed to retrieve event"})
 		return
 	}
+	etag := eventETag(event)
+	c.Header("ETag", etag)
+	if ifNoneMatch := c.GetHeader("If-None-Match"); ifNoneMatch != "" && etagMatches(ifNoneMatch, etag, true) {
+		c.Status(http.StatusNotModified)
+		return
+	}
 	c.JSON(http.StatusOK, event)
 }
 
 // CreateEvent creates a new event
 //
 //	@Summary		Creates a new event
-//	@Description	Creates a new event
+//	@Description	Creates a new event as a draft; publish it to make it visible to others
 //	@Tags			events
 //	@Accept			json
 //	@Produce		json
@@ -70,12 +128,14 @@ func (app *application) createEvent(c *gin.Context) {
 	}
 	user := app.GetUserFromContext(c)
 	newEvent.OwnerId = user.ID
-	err := app.Model.Events.Insert(&newEvent)
+	newEvent.Status = database.EventStatusDraft
+	err := app.Model.Events.Insert(app.actor(c), &newEvent)
 	if err != nil {
 		log.Println("Insert error:", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create event"})
 		return
 	}
+	c.Header("ETag", eventETag(&newEvent))
 	c.JSON(http.StatusCreated, newEvent)
 }
 
@@ -86,9 +146,11 @@ func (app *application) createEvent(c *gin.Context) {
 //	@Tags			events
 //	@Accept			json
 //	@Produce		json
-//	@Param			id	path		int	true	"Event ID"
-//	@Param			event	body		database.Event	true	"Event"
-//	@Success		200	{object}	database.Event
+//	@Param			id			path		int				true	"Event ID"
+//	@Param			If-Match	header		string			false	"ETag the update is based on"
+//	@Param			event		body		database.Event	true	"Event"
+//	@Success		200			{object}	database.Event
+//	@Failure		412
 //	@Router			/api/v1/events/{id} [put]
 //	@Security		BearerAuth
 func (app *application) updateEvent(c *gin.Context) {
@@ -98,30 +160,148 @@ func (app *application) updateEvent(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
 		return
 	}
-	user := app.GetUserFromContext(c)
 	existedEvent, err := app.Model.Events.GetByID(id)
 	if err != nil || existedEvent == nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
 		return
 	}
 
-	if existedEvent.OwnerId != user.ID {
+	if !app.canManageEvent(c, existedEvent, database.PermissionEdit) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "You do not have permission to update this event"})
 		return
 	}
+	if !eventEditable(c, existedEvent) || !checkIfMatch(c, eventETag(existedEvent)) {
+		return
+	}
 	updatedEvent := &database.Event{}
-	updatedEvent.ID = id
-	updatedEvent.OwnerId = user.ID
 	if err := c.ShouldBindJSON(updatedEvent); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	updatedEvent.ID = id
+	updatedEvent.OwnerId = existedEvent.OwnerId
+	updatedEvent.Version = existedEvent.Version
+	updatedEvent.Tags = existedEvent.Tags
+	updatedEvent.Rating = existedEvent.Rating
+	updatedEvent.Status = existedEvent.Status
+	if updatedEvent.Visibility == "" {
+		updatedEvent.Visibility = existedEvent.Visibility
+	}
+
+	err = app.Model.Events.Update(app.actor(c), updatedEvent)
+	if err != nil {
+		if errors.Is(err, database.ErrEditConflict) {
+			c.JSON(http.StatusPreconditionFailed, gin.H{"error": "Event has been modified since it was retrieved"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update event"})
+		return
+	}
+	c.Header("ETag", eventETag(updatedEvent))
+	c.JSON(http.StatusOK, updatedEvent)
+}
+
+// PatchEvent partially updates an existing event
+//
+//	@Summary		Partially updates an existing event
+//	@Description	Applies a JSON Merge Patch (RFC 7396) or JSON Patch (RFC 6902) document to an event. Only changed columns are written.
+//	@Tags			events
+//	@Accept			application/merge-patch+json
+//	@Accept			application/json-patch+json
+//	@Produce		json
+//	@Param			id			path		int		true	"Event ID"
+//	@Param			If-Match	header		string	false	"ETag the patch is based on"
+//	@Param			patch		body		object	true	"Patch document"
+//	@Success		200			{object}	database.Event
+//	@Failure		412
+//	@Failure		415
+//	@Failure		422
+//	@Router			/api/v1/events/{id} [patch]
+//	@Security		BearerAuth
+func (app *application) patchEvent(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		return
+	}
+	existedEvent, err := app.Model.Events.GetByID(id)
+	if err != nil || existedEvent == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+	if !app.canManageEvent(c, existedEvent, database.PermissionEdit) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You do not have permission to update this event"})
+		return
+	}
+	if !eventEditable(c, existedEvent) || !checkIfMatch(c, eventETag(existedEvent)) {
+		return
+	}
+
+	patch, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+		return
+	}
+	original, err := json.Marshal(existedEvent)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update event"})
+		return
+	}
+
+	var patched []byte
+	switch c.ContentType() {
+	case "application/merge-patch+json":
+		patched, err = jsonpatch.MergePatch(original, patch)
+	case "application/json-patch+json":
+		var operations jsonpatch.Patch
+		operations, err = jsonpatch.DecodePatch(patch)
+		if err == nil {
+			patched, err = operations.Apply(original)
+		}
+	default:
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Use application/merge-patch+json or application/json-patch+json"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid patch: " + err.Error()})
+		return
+	}
+
+	updatedEvent := &database.Event{}
+	if err := json.Unmarshal(patched, updatedEvent); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid patch: " + err.Error()})
+		return
+	}
+	if updatedEvent.Visibility == "" {
+		updatedEvent.Visibility = existedEvent.Visibility
+	}
+	if updatedEvent.ID != existedEvent.ID || updatedEvent.OwnerId != existedEvent.OwnerId ||
+		updatedEvent.Version != existedEvent.Version || updatedEvent.Status != existedEvent.Status {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "id, owner_id, version and status cannot be patched"})
+		return
+	}
+	// Tags, ratings and deletion have their own endpoints; silently
+	// dropping a patch of them would look like it was applied.
+	if !slices.Equal(updatedEvent.Tags, existedEvent.Tags) || !reflect.DeepEqual(updatedEvent.Rating, existedEvent.Rating) ||
+		!reflect.DeepEqual(updatedEvent.DeletedAt, existedEvent.DeletedAt) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "tags, rating and deleted_at are read-only here; set tags with PUT /events/{id}/tags"})
+		return
+	}
+	if err := binding.Validator.ValidateStruct(updatedEvent); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-	err = app.Model.Events.Update(updatedEvent)
+	err = app.Model.Events.UpdateFields(app.actor(c), updatedEvent, database.ChangedEventColumns(existedEvent, updatedEvent))
 	if err != nil {
+		if errors.Is(err, database.ErrEditConflict) {
+			c.JSON(http.StatusPreconditionFailed, gin.H{"error": "Event has been modified since it was retrieved"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update event"})
 		return
 	}
+	c.Header("ETag", eventETag(updatedEvent))
 	c.JSON(http.StatusOK, updatedEvent)
 }
 
@@ -132,8 +312,10 @@ func (app *application) updateEvent(c *gin.Context) {
 //	@Tags			events
 //	@Accept			json
 //	@Produce		json
-//	@Param			id	path		int	true	"Event ID"
+//	@Param			id			path	int		true	"Event ID"
+//	@Param			If-Match	header	string	false	"ETag the delete is based on"
 //	@Success		204
+//	@Failure		412
 //	@Router			/api/v1/events/{id} [delete]
 //	@Security		BearerAuth
 func (app *application) deleteEvent(c *gin.Context) {
@@ -142,34 +324,88 @@ func (app *application) deleteEvent(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
 		return
 	}
-	user := app.GetUserFromContext(c)
 	existedEvent, err := app.Model.Events.GetByID(id)
 	if err != nil {
 		c.JSON(http.StatusForbidden, gin.H{"error": "Failed to retrieve event"})
 		return
 	}
-	if existedEvent.OwnerId != user.ID {
+	if !app.canManageEvent(c, existedEvent, database.PermissionOwn) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "You do not have permission to delete this event"})
 		return
 	}
-	err = app.Model.Events.Delete(id)
+	if !checkIfMatch(c, eventETag(existedEvent)) {
+		return
+	}
+	err = app.Model.Events.Delete(app.actor(c), id, existedEvent.Version)
 	if err != nil {
+		if errors.Is(err, database.ErrEditConflict) {
+			c.JSON(http.StatusPreconditionFailed, gin.H{"error": "Event has been modified since it was retrieved"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete event"})
 		return
 	}
 	c.JSON(http.StatusNoContent, nil)
 }
 
+// RestoreEvent restores a soft-deleted event
+//
+//	@Summary		Restores a soft-deleted event
+//	@Description	Restores an event deleted within the grace period. Only the owner or an admin may restore it.
+//	@Tags			events
+//	@Produce		json
+//	@Param			id	path		int	true	"Event ID"
+//	@Success		200	{object}	database.Event
+//	@Failure		410
+//	@Router			/api/v1/events/{id}/restore [post]
+//	@Security		BearerAuth
+func (app *application) restoreEvent(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		return
+	}
+	user := app.GetUserFromContext(c)
+	deletedEvent, err := app.Model.Events.GetDeletedByID(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Deleted event not found"})
+		return
+	}
+	if deletedEvent.OwnerId != user.ID && !user.IsAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You do not have permission to restore this event"})
+		return
+	}
+
+	err = app.Model.Events.Restore(app.actor(c), id, time.Now().Add(-app.RestoreGracePeriod))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusGone, gin.H{"error": "The restore grace period for this event has passed"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore event"})
+		return
+	}
+	event, err := app.Model.Events.GetByID(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve event"})
+		return
+	}
+	c.Header("ETag", eventETag(event))
+	c.JSON(http.StatusOK, event)
+}
+
 // AddAttendeeToEvent adds an attendee to an event
 //
 //	@Summary		Adds an attendee to an event
-//	@Description	Adds an attendee
+//	@Description	Adds an attendee. The response carries their signed ticket code, as an RSVP does.
 //	@Tags			events
 //	@Accept			json
 //	@Produce		json
-//	@Param			id		path		int	true	"Event ID"
-//	@Param			userId	path		int	true	"User ID"
-//	@Success		200		{object}	database.Attendee
+//	@Param			id			path		int		true	"Event ID"
+//	@Param			userId		path		int		true	"User ID"
+//	@Param			occurrence	query		string				false	"Occurrence date (YYYY-MM-DD), required for recurring events"
+//	@Param			answers		body		registrationRequest	false	"Answers to the event's registration form"
+//	@Success		200			{object}	database.Attendee
 //	@Router			/api/v1/events/{id}/attendees/{userId} [post]
 //	@Security		BearerAuth
 func (app *application) addAttendeeToEvent(c *gin.Context) {
@@ -183,24 +419,31 @@ func (app *application) addAttendeeToEvent(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
 		return
 	}
-	user := app.GetUserFromContext(c)
+	caller := app.GetUserFromContext(c)
 	event, err := app.Model.Events.GetByID(eventID)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && event == nil) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve event"})
 		return
 	}
+	occurrence, ok := occurrenceParam(c, event)
+	if !ok {
+		return
+	}
 
-	if user.ID != event.OwnerId {
+	if !app.canManageEvent(c, event, database.PermissionManageAttendees) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "You do not have permission to add attendees to this event"})
 		return
 	}
-
-	if event == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+	if event.Status != database.EventStatusPublished {
+		c.JSON(http.StatusConflict, gin.H{"error": "Attendees can only be added to published events"})
 		return
 	}
 
-	user, err = app.Model.Users.Get(userID)
+	user, err := app.Model.Users.Get(userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve user"})
 		return
@@ -210,7 +453,7 @@ func (app *application) addAttendeeToEvent(c *gin.Context) {
 		return
 	}
 
-	existedAttendee, err := app.Model.Attendees.GetByEventAndUserId(event.ID, user.ID)
+	existedAttendee, err := app.Model.Attendees.GetByEventAndUserId(event.ID, user.ID, occurrence)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check attendee"})
 		return
@@ -219,27 +462,41 @@ func (app *application) addAttendeeToEvent(c *gin.Context) {
 		c.JSON(http.StatusConflict, gin.H{"error": "User is already an attendee"})
 		return
 	}
+	answers, ok := app.bindAnswers(c, event)
+	if !ok {
+		return
+	}
 	attendee := &database.Attendee{
-		EventID: event.ID,
-		UserID:  user.ID,
+		EventID:        event.ID,
+		UserID:         user.ID,
+		OccurrenceDate: occurrence,
+		Answers:        answers,
 	}
-	err = app.Model.Attendees.Insert(attendee)
+	err = app.Model.Attendees.Insert(app.actor(c), attendee)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add attendee"})
 		return
 	}
+	if user.ID != caller.ID {
+		app.notifyAdded(event, attendee)
+	}
+	attendee.Ticket = app.ticketCode(attendee)
 	c.JSON(http.StatusOK, gin.H{"message": "Attendee added successfully", "attendee": attendee})
 }
 
 // GetAttendeesForEvent retrieves all attendees for a specific event
 //
 //	@Summary		Retrieves all attendees for a specific event
-//	@Description	Retrieves all attendees for a specific event
+//	@Description	Retrieves all attendees for a specific event. Organizers can add answers=true or format=csv to export registration answers.
 //	@Tags			events
 //	@Accept			json
 //	@Produce		json
-//	@Param			id	path		int	true	"Event ID"
-//	@Success		200	{array}	database.Attendee
+//	@Param			id			path	int		true	"Event ID"
+//	@Param			occurrence	query	string	false	"Only attendees of this occurrence (YYYY-MM-DD)"
+//	@Param			invite		query	string	false	"Invite token for a private event"
+//	@Param			answers		query	bool	false	"Organizers only: list every attendance with its registration answers"
+//	@Param			format		query	string	false	"Organizers only: csv exports the attendances and answers as CSV"
+//	@Success		200			{array}	database.Attendee
 //	@Router			/api/v1/events/{id}/attendees [get]
 func (app *application) getAttendeesForEvent(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
@@ -247,7 +504,20 @@ func (app *application) getAttendeesForEvent(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
 		return
 	}
-	attendees, err := app.Model.Attendees.GetAttendeesByEvent(id)
+	event, err := app.Model.Events.GetByID(id)
+	if err != nil || !app.canViewEvent(c, event) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+	if c.Query("answers") == "true" || c.Query("format") == "csv" {
+		if !app.canManageEvent(c, event, database.PermissionManageAttendees) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Only organizers can export registration answers"})
+			return
+		}
+		app.exportRegistrations(c, event)
+		return
+	}
+	attendees, err := app.Model.Attendees.GetAttendeesByEvent(id, c.Query("occurrence"))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve attendees"})
 		return
@@ -262,8 +532,9 @@ func (app *application) getAttendeesForEvent(c *gin.Context) {
 //	@Tags			events
 //	@Accept			json
 //	@Produce		json
-//	@Param			id		path		int	true	"Event ID"
-//	@Param			userId	path		int	true	"User ID"
+//	@Param			id			path	int		true	"Event ID"
+//	@Param			userId		path	int		true	"User ID"
+//	@Param			occurrence	query	string	false	"Occurrence date (YYYY-MM-DD), required for recurring events"
 //	@Success		204
 //	@Router			/api/v1/events/{id}/attendees/{userId} [delete]
 //	@Security		BearerAuth
@@ -278,19 +549,22 @@ func (app *application) deleteAttendeeFromEvent(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
 		return
 	}
-	user := app.GetUserFromContext(c)
 	existedEvent, err := app.Model.Events.GetByID(eventID)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Failed to retrieve Event"})
 		return
 	}
 
-	if user.ID != existedEvent.ID {
-		c.JSON(http.StatusForbidden, gin.H{"error": "You do not have permission to delete this event"})
+	if !app.canManageEvent(c, existedEvent, database.PermissionManageAttendees) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You do not have permission to remove attendees from this event"})
 		return
 	}
 
-	existedAttendee, err := app.Model.Attendees.GetByEventAndUserId(eventID, userID)
+	occurrence, ok := occurrenceParam(c, existedEvent)
+	if !ok {
+		return
+	}
+	existedAttendee, err := app.Model.Attendees.GetByEventAndUserId(eventID, userID, occurrence)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check attendee"})
 		return
@@ -300,7 +574,7 @@ func (app *application) deleteAttendeeFromEvent(c *gin.Context) {
 		return
 	}
 
-	err = app.Model.Attendees.Delete(existedAttendee.ID)
+	err = app.Model.Attendees.Delete(app.actor(c), existedAttendee.ID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete attendee"})
 		return
@@ -339,5 +613,11 @@ func (app *application) getEventsByAttendee(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve events for attendee"})
 		return
 	}
-	c.JSON(http.StatusOK, events)
+	visible := []*database.Event{}
+	for _, event := range events {
+		if app.canViewEvent(c, event) {
+			visible = append(visible, event)
+		}
+	}
+	c.JSON(http.StatusOK, visible)
 }
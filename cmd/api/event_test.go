@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/Yiheyistm/go-restful-api/internal/database"
+)
+
+func TestPatchEvent(t *testing.T) {
+	app := newTestApp(t)
+	routes := app.routes()
+	_, token := app.signUp(t, "host")
+
+	rec := serve(t, routes, http.MethodPost, "/api/v1/events", token,
+		`{"name":"Photo walk","description":"Walk and shoot the old town","date":"2026-11-08","location":"Old town"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create = %d %s", rec.Code, rec.Body)
+	}
+	var event database.Event
+	if err := json.Unmarshal(rec.Body.Bytes(), &event); err != nil {
+		t.Fatal(err)
+	}
+	path := fmt.Sprintf("/api/v1/events/%d", event.ID)
+	if rec := serve(t, routes, http.MethodPut, path+"/tags", token, `{"tags":["photo"]}`); rec.Code != http.StatusOK {
+		t.Fatalf("set tags = %d %s", rec.Code, rec.Body)
+	}
+
+	const merge, jsonPatch = "application/merge-patch+json", "application/json-patch+json"
+	tests := []struct {
+		name        string
+		contentType string
+		patch       string
+		want        int
+	}{
+		{"tags by merge patch", merge, `{"tags":["other"]}`, http.StatusUnprocessableEntity},
+		{"tags by JSON Patch", jsonPatch, `[{"op":"add","path":"/tags/-","value":"other"}]`, http.StatusUnprocessableEntity},
+		{"rating", merge, `{"rating":{"count":1,"average":5}}`, http.StatusUnprocessableEntity},
+		{"deleted_at", merge, `{"deleted_at":"2026-01-01T00:00:00Z"}`, http.StatusUnprocessableEntity},
+		{"id", merge, `{"id":999}`, http.StatusBadRequest},
+		{"status", merge, `{"status":"published"}`, http.StatusBadRequest},
+		{"invalid field", merge, `{"date":"next week"}`, http.StatusBadRequest},
+		{"failing test op", jsonPatch, `[{"op":"test","path":"/name","value":"Other"}]`, http.StatusBadRequest},
+		{"plain JSON", "application/json", `{"name":"Photo stroll"}`, http.StatusUnsupportedMediaType},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(t, routes, http.MethodPatch, path, token, tt.patch, "Content-Type", tt.contentType)
+			if rec.Code != tt.want {
+				t.Errorf("PATCH %s = %d %s, want %d", tt.patch, rec.Code, rec.Body, tt.want)
+			}
+		})
+	}
+
+	rec = serve(t, routes, http.MethodPatch, path, token, `{"name":"Photo stroll","tags":["photo"]}`, "Content-Type", merge)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("merge patch of the name = %d %s", rec.Code, rec.Body)
+	}
+	rec = serve(t, routes, http.MethodPatch, path, token, `[{"op":"replace","path":"/location","value":"Harbour"}]`, "Content-Type", jsonPatch)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("JSON Patch of the location = %d %s", rec.Code, rec.Body)
+	}
+	stored, err := app.Model.Events.GetByID(event.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Name != "Photo stroll" || stored.Location != "Harbour" || stored.Description != event.Description || len(stored.Tags) != 1 {
+		t.Errorf("stored event = %+v, want only the name and location patched", stored)
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Yiheyistm/go-restful-api/internal/database"
+	"github.com/gin-gonic/gin"
+)
+
+type setFormRequest struct {
+	Questions []database.Question `json:"questions" binding:"dive"`
+}
+
+// registrationRequest is the optional body of the requests that add an
+// attendee.
+type registrationRequest struct {
+	Answers database.Answers `json:"answers"`
+}
+
+// bindAnswers reads the optional registration answers from the request
+// body and validates them against the event's form, answering the request
+// itself when they are invalid.
+func (app *application) bindAnswers(c *gin.Context, event *database.Event) (database.Answers, bool) {
+	var request registrationRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return nil, false
+		}
+	}
+	return app.validateAnswers(c, event, request.Answers)
+}
+
+// validateAnswers checks answers against the event's registration form,
+// answering the request itself when they are invalid.
+func (app *application) validateAnswers(c *gin.Context, event *database.Event, answers database.Answers) (database.Answers, bool) {
+	questions, err := app.Model.Forms.Get(event.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve registration form"})
+		return nil, false
+	}
+	cleaned, err := database.ValidateAnswers(questions, answers)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return cleaned, true
+}
+
+// GetEventForm returns the registration form of an event
+//
+//	@Summary		Returns the registration form of an event
+//	@Description	Returns the questions attendees answer when they sign up
+//	@Tags			events
+//	@Produce		json
+//	@Param			id		path	int		true	"Event ID"
+//	@Param			invite	query	string	false	"Invite token for a private event"
+//	@Success		200		{array}	database.Question
+//	@Router			/api/v1/events/{id}/form [get]
+func (app *application) getEventForm(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		return
+	}
+	event, err := app.Model.Events.GetByID(id)
+	if err != nil || !app.canViewEvent(c, event) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+	questions, err := app.Model.Forms.Get(event.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve registration form"})
+		return
+	}
+	c.JSON(http.StatusOK, questions)
+}
+
+// SetEventForm replaces the registration form of an event
+//
+//	@Summary		Replaces the registration form of an event
+//	@Description	Replaces the questions attendees answer when they sign up. Text, select and checkbox questions can be required or optional; text answers can be limited in length and matched against a pattern. Answers already given are kept.
+//	@Tags			events
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path	int				true	"Event ID"
+//	@Param			form	body	setFormRequest	true	"Questions"
+//	@Success		200		{array}	database.Question
+//	@Router			/api/v1/events/{id}/form [put]
+//	@Security		BearerAuth
+func (app *application) setEventForm(c *gin.Context) {
+	event, ok := app.managedEvent(c, database.PermissionEdit)
+	if !ok || !eventEditable(c, event) {
+		return
+	}
+	var request setFormRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if request.Questions == nil {
+		request.Questions = []database.Question{}
+	}
+	if err := database.ValidateQuestions(request.Questions); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := app.Model.Forms.Set(app.actor(c), event.ID, request.Questions); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update registration form"})
+		return
+	}
+	c.JSON(http.StatusOK, request.Questions)
+}
+
+// exportRegistrations answers with every attendance of event and its
+// registration answers, as JSON or, with format=csv, as a CSV file with
+// one column per question.
+func (app *application) exportRegistrations(c *gin.Context, event *database.Event) {
+	registrations, err := app.Model.Attendees.GetRegistrations(event.ID, c.Query("occurrence"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve attendees"})
+		return
+	}
+	if c.Query("format") != "csv" {
+		c.JSON(http.StatusOK, registrations)
+		return
+	}
+	questions, err := app.Model.Forms.Get(event.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve registration form"})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="event-%d-attendees.csv"`, event.ID))
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Status(http.StatusOK)
+	w := csv.NewWriter(c.Writer)
+	header := []string{"attendee_id", "user_id", "username", "email", "occurrence_date", "checked_in_at"}
+	for _, q := range questions {
+		header = append(header, q.Key)
+	}
+	w.Write(header)
+	for _, r := range registrations {
+		checkedIn := ""
+		if r.CheckedInAt != nil {
+			checkedIn = r.CheckedInAt.Format(time.RFC3339)
+		}
+		record := []string{strconv.Itoa(r.ID), strconv.Itoa(r.UserID), csvCell(r.Username), r.Email, r.OccurrenceDate, checkedIn}
+		for _, q := range questions {
+			value := ""
+			if answer, ok := r.Answers[q.Key]; ok {
+				value = csvCell(fmt.Sprint(answer))
+			}
+			record = append(record, value)
+		}
+		w.Write(record)
+	}
+	w.Flush()
+}
+
+// csvCell keeps spreadsheet apps from reading attendee-supplied text as a
+// formula.
+func csvCell(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
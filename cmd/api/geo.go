@@ -0,0 +1,102 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/Yiheyistm/go-restful-api/internal/database"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultNearbyRadiusKm = 10
+	maxNearbyRadiusKm     = 500
+)
+
+type geoJSONFeatureCollection struct {
+	Type     string           `json:"type"`
+	Features []geoJSONFeature `json:"features"`
+}
+
+type geoJSONFeature struct {
+	Type       string       `json:"type"`
+	ID         int          `json:"id"`
+	Geometry   geoJSONPoint `json:"geometry"`
+	Properties any          `json:"properties"`
+}
+
+type geoJSONPoint struct {
+	Type string `json:"type"`
+	// Coordinates are longitude first, as GeoJSON requires.
+	Coordinates [2]float64 `json:"coordinates"`
+}
+
+// wantsGeoJSON reports whether the client asked for format=geojson.
+func wantsGeoJSON(c *gin.Context) bool {
+	return c.Query("format") == "geojson"
+}
+
+// eventsGeoJSON builds a FeatureCollection with one point per event that
+// has coordinates; events without them are left out.
+func eventsGeoJSON[T any](events []T, event func(T) *database.Event) geoJSONFeatureCollection {
+	collection := geoJSONFeatureCollection{Type: "FeatureCollection", Features: []geoJSONFeature{}}
+	for _, item := range events {
+		e := event(item)
+		if e.Latitude == nil || e.Longitude == nil {
+			continue
+		}
+		collection.Features = append(collection.Features, geoJSONFeature{
+			Type:       "Feature",
+			ID:         e.ID,
+			Geometry:   geoJSONPoint{Type: "Point", Coordinates: [2]float64{*e.Longitude, *e.Latitude}},
+			Properties: item,
+		})
+	}
+	return collection
+}
+
+// GetNearbyEvents returns events within a radius of a point
+//
+//	@Summary		Returns events near a point
+//	@Description	Returns events with coordinates within radius_km of lat/lng, nearest first, with their distance. format=geojson returns a FeatureCollection.
+//	@Tags			events
+//	@Produce		json
+//	@Param			lat			query		number		true	"Latitude"
+//	@Param			lng			query		number		true	"Longitude"
+//	@Param			radius_km	query		number		false	"Search radius in kilometres (default 10, max 500)"
+//	@Param			tag			query		[]string	false	"Only events carrying all of these tags"	collectionFormat(multi)
+//	@Param			format		query		string		false	"json (default) or geojson"
+//	@Success		200			{object}	[]database.NearbyEvent
+//	@Router			/api/v1/events/nearby [get]
+func (app *application) getNearbyEvents(c *gin.Context) {
+	lat, errLat := strconv.ParseFloat(c.Query("lat"), 64)
+	lng, errLng := strconv.ParseFloat(c.Query("lng"), 64)
+	if errLat != nil || errLng != nil || lat < -90 || lat > 90 || lng < -180 || lng > 180 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "lat and lng must be valid coordinates"})
+		return
+	}
+	radius := float64(defaultNearbyRadiusKm)
+	if value := c.Query("radius_km"); value != "" {
+		var err error
+		radius, err = strconv.ParseFloat(value, 64)
+		if err != nil || radius <= 0 || radius > maxNearbyRadiusKm {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "radius_km must be greater than 0 and at most 500"})
+			return
+		}
+	}
+	filter, ok := app.eventFilterFromQuery(c)
+	if !ok {
+		return
+	}
+
+	events, err := app.Model.Events.GetNearby(lat, lng, radius, filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve events"})
+		return
+	}
+	if wantsGeoJSON(c) {
+		c.JSON(http.StatusOK, eventsGeoJSON(events, func(e *database.NearbyEvent) *database.Event { return &e.Event }))
+		return
+	}
+	c.JSON(http.StatusOK, events)
+}
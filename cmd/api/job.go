@@ -0,0 +1,145 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Yiheyistm/go-restful-api/internal/database"
+	"github.com/Yiheyistm/go-restful-api/internal/queue"
+	"github.com/gin-gonic/gin"
+)
+
+// Job types handled by the API's workers.
+const (
+	jobPurge              = "purge"
+	jobNotify             = "notify.send"
+	jobNotifyCancellation = "notify.cancellation"
+)
+
+// newQueue returns the job queue with a handler for every job type the
+// API enqueues.
+func (app *application) newQueue(workers int, pollInterval, lease, purgeInterval, reminderInterval, waitlistInterval time.Duration) *queue.Queue {
+	q := &queue.Queue{
+		Jobs:         &app.Model.Jobs,
+		Workers:      workers,
+		PollInterval: pollInterval,
+		Lease:        lease,
+	}
+	q.Every(jobPurge, purgeInterval, app.purgeDeleted)
+	q.Every(database.JobReminderScan, reminderInterval, app.sendReminders)
+	q.Every(database.JobWaitlistPromote, waitlistInterval, app.promoteWaitlist)
+	q.Register(jobNotify, app.sendNotification)
+	q.Register(jobNotifyCancellation, app.notifyCancellation)
+	q.Register(database.JobWebhookDelivery, app.deliverWebhook)
+	q.Register(database.JobAnnouncementEmail, app.emailAnnouncement)
+	return q
+}
+
+// enqueueJob queues a job of jobType to run as soon as a worker is free.
+func (app *application) enqueueJob(jobType string, payload any) error {
+	job, err := database.NewJob(jobType, payload)
+	if err != nil {
+		return err
+	}
+	return app.Model.Jobs.Enqueue(job)
+}
+
+// GetJobs lists background jobs
+//
+//	@Summary		Lists background jobs
+//	@Description	Lists background jobs, newest first, optionally only those with a status (queued, running, succeeded, dead) or type. Dead jobs ran out of attempts and wait to be retried.
+//	@Tags			admin
+//	@Produce		json
+//	@Param			status	query	string	false	"Job status"
+//	@Param			type	query	string	false	"Job type"
+//	@Param			limit	query	int		false	"Maximum number of jobs (default 100)"
+//	@Param			offset	query	int		false	"Number of jobs to skip"
+//	@Success		200		{array}	database.Job
+//	@Router			/api/v1/admin/jobs [get]
+//	@Security		BearerAuth
+func (app *application) getJobs(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	offset, _ := strconv.Atoi(c.Query("offset"))
+	jobs, err := app.Model.Jobs.Find(database.JobFilter{
+		Status: c.Query("status"),
+		Type:   c.Query("type"),
+		Limit:  limit,
+		Offset: offset,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve jobs"})
+		return
+	}
+	c.JSON(http.StatusOK, jobs)
+}
+
+// GetJob returns a background job
+//
+//	@Summary		Returns a background job
+//	@Description	Returns a background job with its payload and the error of its last failed attempt
+//	@Tags			admin
+//	@Produce		json
+//	@Param			id	path		int	true	"Job ID"
+//	@Success		200	{object}	database.Job
+//	@Failure		404
+//	@Router			/api/v1/admin/jobs/{id} [get]
+//	@Security		BearerAuth
+func (app *application) getJob(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid job ID"})
+		return
+	}
+	job, err := app.Model.Jobs.Get(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve job"})
+		return
+	}
+	if job == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
+
+// RetryJob runs a background job again
+//
+//	@Summary		Runs a background job again
+//	@Description	Queues a dead or queued job to run right away with a fresh set of attempts. Running and succeeded jobs cannot be retried.
+//	@Tags			admin
+//	@Produce		json
+//	@Param			id	path		int	true	"Job ID"
+//	@Success		200	{object}	database.Job
+//	@Failure		404
+//	@Failure		409
+//	@Router			/api/v1/admin/jobs/{id}/retry [post]
+//	@Security		BearerAuth
+func (app *application) retryJob(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid job ID"})
+		return
+	}
+	job, err := app.Model.Jobs.Retry(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		existing, err := app.Model.Jobs.Get(id)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve job"})
+			return
+		}
+		if existing == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+			return
+		}
+		c.JSON(http.StatusConflict, gin.H{"error": "A " + existing.Status + " job cannot be retried"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retry job"})
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
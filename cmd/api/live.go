@@ -0,0 +1,217 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Yiheyistm/go-restful-api/internal/database"
+	"github.com/Yiheyistm/go-restful-api/internal/stream"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// liveWriteWait bounds how long one message may take to send.
+	liveWriteWait = 10 * time.Second
+	// livePongWait is how long the connection may stay silent; pings go
+	// out often enough to keep a healthy client well inside it.
+	livePongWait   = 60 * time.Second
+	livePingPeriod = livePongWait * 9 / 10
+	liveMaxMessage = 4096
+	// liveMaxEvents caps how many events one connection follows.
+	liveMaxEvents = 50
+	// liveSubprotocol lets browsers, which cannot set headers on a
+	// WebSocket, send their token as the second protocol: "bearer, <token>".
+	liveSubprotocol = "bearer"
+)
+
+var liveUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	Subprotocols:    []string{liveSubprotocol},
+}
+
+// liveCommand is a message from the client.
+type liveCommand struct {
+	Action   string `json:"action"`
+	EventIDs []int  `json:"event_ids"`
+}
+
+// liveMessage is a message to the client. Type is "subscribed",
+// "unsubscribed" or "error" in reply to a command, or the type of a
+// change: attendee.added for an RSVP, attendee.removed for a
+// cancellation, attendee.checked_in for a check-in and waitlist.promoted,
+// with Promotion set, when a freed ticket is held for someone waiting.
+type liveMessage struct {
+	Type      string                  `json:"type"`
+	ID        int                     `json:"id,omitempty"`
+	EventID   int                     `json:"event_id,omitempty"`
+	Attendee  *database.Attendee      `json:"attendee,omitempty"`
+	Promotion *database.WaitlistEntry `json:"promotion,omitempty"`
+	Counts    *database.CheckInCounts `json:"counts,omitempty"`
+	Error     string                  `json:"error,omitempty"`
+}
+
+// bearerFromSubprotocol moves a token sent as a WebSocket subprotocol
+// into the Authorization header, so authMiddleware validates it like any
+// other.
+func bearerFromSubprotocol() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetHeader("Authorization") == "" {
+			protocols := websocket.Subprotocols(c.Request)
+			if len(protocols) == 2 && protocols[0] == liveSubprotocol {
+				c.Request.Header.Set("Authorization", "Bearer "+protocols[1])
+			}
+		}
+		c.Next()
+	}
+}
+
+// LiveDashboard opens a WebSocket of live attendance for door dashboards
+//
+//	@Summary		Opens a WebSocket of live attendance
+//	@Description	Upgrades to a WebSocket that pushes a JSON message, with the event's current counts, whenever someone RSVPs (attendee.added), cancels (attendee.removed), checks in (attendee.checked_in) or is promoted from a sold-out tier's waitlist (waitlist.promoted, with the waitlist entry and the order holding their ticket) at the events followed. Follow events with ?events=1,2 or by sending {"action":"subscribe","event_ids":[1,2]}; stop with "unsubscribe". Only organizers of an event may follow it; each subscription is answered with the current counts. Browsers may send their token as the subprotocols "bearer", "<token>". The server pings every 54 seconds and drops clients that stop answering or fall too far behind.
+//	@Tags			events
+//	@Param			events	query	string	false	"Comma-separated event IDs to follow"
+//	@Success		101
+//	@Router			/api/v1/live [get]
+//	@Security		BearerAuth
+func (app *application) liveDashboard(c *gin.Context) {
+	conn, err := liveUpgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// The upgrader has already answered the request.
+		return
+	}
+	defer conn.Close()
+
+	sub, _, _ := app.Stream.Subscribe(0)
+	defer app.Stream.Unsubscribe(sub)
+
+	// Commands arrive on their own goroutine; this one owns every write.
+	commands := make(chan liveCommand)
+	done := make(chan struct{})
+	quit := make(chan struct{})
+	defer close(quit)
+	go func() {
+		defer close(done)
+		conn.SetReadLimit(liveMaxMessage)
+		conn.SetReadDeadline(time.Now().Add(livePongWait))
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(livePongWait))
+		})
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			var command liveCommand
+			if json.Unmarshal(data, &command) != nil {
+				command = liveCommand{}
+			}
+			select {
+			case commands <- command:
+			case <-quit:
+				return
+			}
+		}
+	}()
+
+	send := func(msg liveMessage) bool {
+		conn.SetWriteDeadline(time.Now().Add(liveWriteWait))
+		return conn.WriteJSON(msg) == nil
+	}
+	following := map[int]bool{}
+	subscribe := func(ids []int) bool {
+		for _, id := range ids {
+			if following[id] {
+				continue
+			}
+			if len(following) >= liveMaxEvents {
+				return send(liveMessage{Type: "error", EventID: id, Error: "Too many events on one connection"})
+			}
+			event, err := app.Model.Events.GetByID(id)
+			if err != nil || !app.canManageEvent(c, event, database.PermissionCheckIn) {
+				if !send(liveMessage{Type: "error", EventID: id, Error: "Event not found or not organized by you"}) {
+					return false
+				}
+				continue
+			}
+			counts, err := app.Model.Attendees.CountCheckIns(id, "")
+			if err != nil {
+				log.Printf("Failed to count attendees of event %d: %v", id, err)
+			}
+			following[id] = true
+			if !send(liveMessage{Type: "subscribed", EventID: id, Counts: counts}) {
+				return false
+			}
+		}
+		return true
+	}
+
+	var initial []int
+	for _, field := range strings.Split(c.Query("events"), ",") {
+		if id, err := strconv.Atoi(strings.TrimSpace(field)); err == nil {
+			initial = append(initial, id)
+		}
+	}
+	if !subscribe(initial) {
+		return
+	}
+
+	ping := time.NewTicker(livePingPeriod)
+	defer ping.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case command := <-commands:
+			switch command.Action {
+			case "subscribe":
+				if !subscribe(command.EventIDs) {
+					return
+				}
+			case "unsubscribe":
+				for _, id := range command.EventIDs {
+					delete(following, id)
+					if !send(liveMessage{Type: "unsubscribed", EventID: id}) {
+						return
+					}
+				}
+			default:
+				if !send(liveMessage{Type: "error", Error: `Commands are {"action":"subscribe"|"unsubscribe","event_ids":[...]}`}) {
+					return
+				}
+			}
+		case msg, ok := <-sub.C:
+			if !ok {
+				// Either the server is shutting down or this client fell
+				// too far behind; in both cases it should reconnect.
+				conn.WriteControl(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "reconnect"), time.Now().Add(liveWriteWait))
+				return
+			}
+			if !app.followsLive(c, following, msg) {
+				continue
+			}
+			if !send(liveMessage{Type: msg.Type, ID: msg.ID, EventID: msg.Event.ID, Attendee: msg.Attendee, Promotion: msg.Promotion, Counts: msg.Counts}) {
+				return
+			}
+		case <-ping.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(liveWriteWait)); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// followsLive reports whether msg is an attendee change or promotion of a
+// followed event whose organizers still include the caller.
+func (app *application) followsLive(c *gin.Context, following map[int]bool, msg stream.Message) bool {
+	if (msg.Attendee == nil && msg.Promotion == nil) || !following[msg.Event.ID] {
+		return false
+	}
+	return app.canManageEvent(c, msg.Event, database.PermissionCheckIn)
+}
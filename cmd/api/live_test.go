@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Yiheyistm/go-restful-api/internal/database"
+)
+
+func TestWaitlistPromotionIsBroadcast(t *testing.T) {
+	app := newTestApp(t)
+	owner, _ := app.signUp(t, "bandleader")
+	waiter, _ := app.signUp(t, "waiter")
+	buyer, _ := app.signUp(t, "buyer")
+	event := &database.Event{
+		OwnerId:     owner.ID,
+		Name:        "Azmari night",
+		Description: "Improvised verses until late",
+		Date:        "2026-12-30",
+		Location:    "Debre Birhan",
+		Status:      database.EventStatusPublished,
+	}
+	if err := app.Model.Events.Insert(database.Actor{}, event); err != nil {
+		t.Fatal(err)
+	}
+	tier := &database.TicketType{EventID: event.ID, Name: "Table", PriceMinor: 3000, Currency: "ETB", Quantity: 1}
+	if err := app.Model.TicketTypes.Insert(tier); err != nil {
+		t.Fatal(err)
+	}
+	order := &database.Order{EventID: event.ID, UserID: buyer.ID, TicketTypeID: tier.ID, AmountMinor: 3000, Currency: "ETB", ExpiresAt: time.Now().Add(time.Hour)}
+	if err := app.Model.Orders.Reserve(order); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.Model.Waitlist.Join(&database.WaitlistEntry{EventID: event.ID, TicketTypeID: tier.ID, UserID: waiter.ID}); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.Model.Orders.MarkFailed(order); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := app.Model.Waitlist.Promote(app.OrderTTL); err != nil {
+		t.Fatal(err)
+	}
+
+	sub, _, _ := app.Stream.Subscribe(0)
+	defer app.Stream.Unsubscribe(sub)
+	changes, err := app.Model.Outbox.Unpublished(0, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, change := range changes {
+		if err := app.broadcastChange(context.Background(), change); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for {
+		select {
+		case msg := <-sub.C:
+			if msg.Type != database.DomainWaitlistPromoted {
+				continue
+			}
+			if msg.Promotion == nil || msg.Promotion.UserID != waiter.ID || msg.Promotion.OrderID == nil {
+				t.Errorf("promotion message = %+v, want the waiter's entry with its order", msg.Promotion)
+			}
+			if msg.Event == nil || msg.Event.ID != event.ID || msg.Counts == nil {
+				t.Errorf("promotion message = %+v, want the event and its counts", msg)
+			}
+			return
+		default:
+			t.Fatal("no waitlist.promoted message was broadcast")
+		}
+	}
+}
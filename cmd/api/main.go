@@ -1,12 +1,22 @@
 package main
 
 import (
-	"database/sql"
+	"context"
 	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
 	_ "github.com/Yiheyistm/go-restful-api/docs"
 	"github.com/Yiheyistm/go-restful-api/internal/database"
 	"github.com/Yiheyistm/go-restful-api/internal/env"
+	"github.com/Yiheyistm/go-restful-api/internal/notify"
+	"github.com/Yiheyistm/go-restful-api/internal/outbox"
+	"github.com/Yiheyistm/go-restful-api/internal/payment"
+	"github.com/Yiheyistm/go-restful-api/internal/stream"
+	"github.com/Yiheyistm/go-restful-api/internal/webhook"
 
 	_ "github.com/joho/godotenv/autoload"
 	_ "github.com/mattn/go-sqlite3"
@@ -31,26 +41,93 @@ import (
 // @name Authorization
 
 type application struct {
-	Port      int
-	JwtSecret string
-	Model     database.Models
+	Port                int
+	JwtSecret           string
+	Model               database.Models
+	DB                  *database.DB
+	RestoreGracePeriod  time.Duration
+	SoftDeleteRetention time.Duration
+	Notifier            notify.Notifier
+	Payments            payment.Provider
+	OrderTTL            time.Duration
+	Webhooks            webhook.Sender
+	Stream              *stream.Broker
+	ReminderOffsets     []time.Duration
+	AnnouncementLimit   int
+	AnnouncementWindow  time.Duration
+	CommentEditWindow   time.Duration
 }
 
 func main() {
-	db, err := sql.Open("sqlite3", "./data.db")
+	db, err := database.Open(database.ConfigFromEnv())
 	if err != nil {
 		log.Fatal("Failed to connect to the database: ", err)
 	}
 	defer db.Close()
 
+	reminderOffsets, err := parseReminderOffsets(env.GetEnvString("REMINDER_OFFSETS", "24h,1h"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	models := database.NewModels(db)
 	app := &application{
-		Port:      env.GetEnvInt("PORT", 8080),
-		JwtSecret: env.GetEnvString("JWT_SECRET", "some_secret_123"),
-		Model:     models,
+		Port:                env.GetEnvInt("PORT", 8080),
+		JwtSecret:           env.GetEnvString("JWT_SECRET", "some_secret_123"),
+		Model:               models,
+		DB:                  db,
+		RestoreGracePeriod:  env.GetEnvDuration("RESTORE_GRACE_PERIOD", 7*24*time.Hour),
+		SoftDeleteRetention: env.GetEnvDuration("SOFT_DELETE_RETENTION", 30*24*time.Hour),
+		Notifier:            notify.LogNotifier{Logger: log.Default()},
+		Payments:            payment.LocalProvider{Secret: env.GetEnvString("PAYMENT_WEBHOOK_SECRET", "some_webhook_secret_123")},
+		OrderTTL:            env.GetEnvDuration("ORDER_TTL", 15*time.Minute),
+		Webhooks:            webhook.Sender{AllowPrivate: env.GetEnvBool("WEBHOOK_ALLOW_PRIVATE", false)},
+		Stream:              &stream.Broker{Capacity: env.GetEnvInt("STREAM_REPLAY_BUFFER", 1000)},
+		ReminderOffsets:     reminderOffsets,
+		AnnouncementLimit:   env.GetEnvInt("ANNOUNCEMENT_LIMIT", 5),
+		AnnouncementWindow:  env.GetEnvDuration("ANNOUNCEMENT_WINDOW", 24*time.Hour),
+		CommentEditWindow:   env.GetEnvDuration("COMMENT_EDIT_WINDOW", 15*time.Minute),
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	jobs := app.newQueue(
+		env.GetEnvInt("JOB_WORKERS", 4),
+		env.GetEnvDuration("JOB_POLL_INTERVAL", time.Second),
+		env.GetEnvDuration("JOB_LEASE", 5*time.Minute),
+		env.GetEnvDuration("PURGE_INTERVAL", time.Hour),
+		env.GetEnvDuration("REMINDER_INTERVAL", time.Minute),
+		env.GetEnvDuration("WAITLIST_INTERVAL", time.Minute),
+	)
+	sinks, err := outbox.ParseSinks(env.GetEnvString("OUTBOX_SINKS", ""), env.GetEnvString("OUTBOX_WEBHOOK_SECRET", ""))
+	if err != nil {
+		log.Fatal(err)
 	}
+	relay := &outbox.Relay{
+		Outbox:       &app.Model.Outbox,
+		Sinks:        sinks,
+		PollInterval: env.GetEnvDuration("OUTBOX_POLL_INTERVAL", 500*time.Millisecond),
+	}
+	relay.Subscribe("", app.queueWebhooks)
+	relay.Subscribe("", app.broadcastChange)
+	relay.Subscribe("", app.notifyAttendees)
+
+	var workers sync.WaitGroup
+	workers.Add(2)
+	go func() {
+		defer workers.Done()
+		jobs.Run(ctx)
+	}()
+	go func() {
+		defer workers.Done()
+		relay.Run(ctx)
+	}()
 
-	if err := app.server(); err != nil {
+	err = app.server(ctx)
+	stop()
+	workers.Wait()
+	if err != nil {
 		log.Fatal(err)
 	}
 }
@@ -1,66 +1,119 @@
 package main
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"net/http"
 	"strings"
 	"time"
 
+	"github.com/Yiheyistm/go-restful-api/internal/database"
 	"github.com/dgrijalva/jwt-go"
 	"github.com/gin-gonic/gin"
 )
 
 func (app *application) authMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"Error": "Autherization Header is required"})
-			c.Abort()
+		user, failure := app.authenticate(c)
+		if failure != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, failure)
 			return
 		}
+		c.Set("user", user)
+		c.Next()
+	}
+}
 
-		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-		if tokenString == authHeader {
-			c.JSON(http.StatusUnauthorized, gin.H{"Error": "Bearer token is required"})
-			c.Abort()
-			return
-		}
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (any, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, gin.Error{
-					Err:  http.ErrNotSupported,
-					Type: gin.ErrorTypePublic,
-				}
-			}
-			return []byte(app.JwtSecret), nil
-		})
-		if err != nil || !token.Valid {
-			c.JSON(http.StatusUnauthorized, gin.H{"Error": "Invalid token"})
-			c.Abort()
+// optionalAuthMiddleware lets anonymous requests through but still
+// identifies the caller when a token is sent, so public reads can show
+// the caller's own drafts. A token that is sent but invalid is rejected.
+func (app *application) optionalAuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetHeader("Authorization") == "" {
+			c.Next()
 			return
 		}
-		claims, ok := token.Claims.(jwt.MapClaims)
-		if !ok {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"Error": "Invalid token claims"})
+		user, failure := app.authenticate(c)
+		if failure != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, failure)
 			return
 		}
+		c.Set("user", user)
+		c.Next()
+	}
+}
 
-		if exp, ok := claims["exp"].(float64); ok {
-			if time.Unix(int64(exp), 0).Before(time.Now()) {
-				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Token expired"})
-				return
+// authenticate resolves the bearer token of the request to a user. When it
+// cannot, it returns the body to answer 401 with instead.
+func (app *application) authenticate(c *gin.Context) (*database.User, gin.H) {
+	authHeader := c.GetHeader("Authorization")
+	if authHeader == "" {
+		return nil, gin.H{"Error": "Autherization Header is required"}
+	}
+
+	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+	if tokenString == authHeader {
+		return nil, gin.H{"Error": "Bearer token is required"}
+	}
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (any, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, gin.Error{
+				Err:  http.ErrNotSupported,
+				Type: gin.ErrorTypePublic,
 			}
 		}
-		userID := claims["userId"].(float64)
+		return []byte(app.JwtSecret), nil
+	})
+	if err != nil || !token.Valid {
+		return nil, gin.H{"Error": "Invalid token"}
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, gin.H{"Error": "Invalid token claims"}
+	}
+
+	if exp, ok := claims["exp"].(float64); ok {
+		if time.Unix(int64(exp), 0).Before(time.Now()) {
+			return nil, gin.H{"error": "Token expired"}
+		}
+	}
+	userID, ok := claims["userId"].(float64)
+	if !ok {
+		return nil, gin.H{"Error": "Invalid token claims"}
+	}
+
+	user, err := app.Model.Users.Get(int(userID))
+	fmt.Println("User:", user, userID)
+	if err != nil {
+		return nil, gin.H{"Error": "User not found", "user": user}
+	}
+	return user, nil
+}
 
-		user, err := app.Model.Users.Get(int(userID))
-		fmt.Println("User:", user, userID)
-		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"Error": "User not found", "user": user})
-			c.Abort()
+func (app *application) adminMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := app.GetUserFromContext(c)
+		if !user.IsAdmin {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
 			return
 		}
-		c.Set("user", user)
+		c.Next()
+	}
+}
+
+// requestIDMiddleware tags every request with an ID, reusing the caller's
+// X-Request-ID when it sends one, and echoes it back in the response.
+func (app *application) requestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader("X-Request-ID")
+		if requestID == "" || len(requestID) > 128 {
+			buf := make([]byte, 16)
+			rand.Read(buf)
+			requestID = hex.EncodeToString(buf)
+		}
+		c.Set("requestId", requestID)
+		c.Header("X-Request-ID", requestID)
 		c.Next()
 	}
 }
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/Yiheyistm/go-restful-api/internal/database"
+	"github.com/gin-gonic/gin"
+)
+
+// notifyAttendees is the outbox subscriber that tells the attendees of a
+// published event in their inbox when it changes. Cancellations are told
+// by notifyCancellation instead, which knows the reason.
+func (app *application) notifyAttendees(ctx context.Context, event *database.DomainEvent) error {
+	if event.AggregateType != database.AggregateEvent || event.Type != database.DomainEventUpdated {
+		return nil
+	}
+	var changed database.Event
+	if err := json.Unmarshal(event.Payload, &changed); err != nil {
+		return err
+	}
+	if changed.Status != database.EventStatusPublished || changed.DeletedAt != nil {
+		return nil
+	}
+	return app.Model.Notifications.InsertForAttendees(&database.Notification{
+		Type:    database.NotificationEventUpdated,
+		EventID: &changed.ID,
+		Title:   fmt.Sprintf("%s has changed", changed.Name),
+		Body:    fmt.Sprintf("%s is on %s at %s.", changed.Name, changed.Date, changed.Location),
+		Key:     fmt.Sprintf("%s:%d", database.NotificationEventUpdated, event.ID),
+	})
+}
+
+// notifyAdded tells a user in their inbox that someone else added them to
+// an event.
+func (app *application) notifyAdded(event *database.Event, attendee *database.Attendee) {
+	body := fmt.Sprintf("You are attending %s on %s at %s.", event.Name, event.Date, event.Location)
+	if attendee.OccurrenceDate != "" {
+		body = fmt.Sprintf("You are attending %s on %s at %s.", event.Name, attendee.OccurrenceDate, event.Location)
+	}
+	err := app.Model.Notifications.Insert(&database.Notification{
+		UserID:  attendee.UserID,
+		Type:    database.NotificationAttendeeAdded,
+		EventID: &event.ID,
+		Title:   fmt.Sprintf("You were added to %s", event.Name),
+		Body:    body,
+		Key:     fmt.Sprintf("%s:%d", database.NotificationAttendeeAdded, attendee.ID),
+	})
+	if err != nil {
+		log.Printf("Failed to notify user %d of attendee %d: %v", attendee.UserID, attendee.ID, err)
+	}
+}
+
+// GetNotifications lists the caller's notifications
+//
+//	@Summary		Lists the caller's notifications
+//	@Description	Lists the caller's in-app notifications, newest first, along with how many are unread. Pass next_cursor back as cursor for the next page; it is left out on the last one. The inbox is filled when someone else adds the caller to an event, when an event they attend changes or is cancelled, with reminders before it starts, and when a waitlist promotes them to a ticket.
+//	@Tags			notifications
+//	@Produce		json
+//	@Param			cursor	query		int		false	"next_cursor of the previous page"
+//	@Param			limit	query		int		false	"Maximum number of notifications (default 20, at most 100)"
+//	@Param			unread	query		bool	false	"Only unread notifications"
+//	@Success		200		{object}	database.NotificationPage
+//	@Router			/api/v1/me/notifications [get]
+//	@Security		BearerAuth
+func (app *application) getNotifications(c *gin.Context) {
+	cursor, err := strconv.Atoi(c.DefaultQuery("cursor", "0"))
+	if err != nil || cursor < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+		return
+	}
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	unread, _ := strconv.ParseBool(c.Query("unread"))
+	page, err := app.Model.Notifications.List(app.GetUserFromContext(c).ID, cursor, limit, unread)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve notifications"})
+		return
+	}
+	c.JSON(http.StatusOK, page)
+}
+
+// GetUnreadNotificationCount returns how many of the caller's notifications are unread
+//
+//	@Summary		Returns how many of the caller's notifications are unread
+//	@Description	Returns the number of unread notifications, cheap enough to poll for a badge
+//	@Tags			notifications
+//	@Produce		json
+//	@Success		200
+//	@Router			/api/v1/me/notifications/unread-count [get]
+//	@Security		BearerAuth
+func (app *application) getUnreadNotificationCount(c *gin.Context) {
+	app.respondUnreadCount(c, app.GetUserFromContext(c).ID)
+}
+
+// MarkNotificationRead marks one of the caller's notifications as read
+//
+//	@Summary		Marks one of the caller's notifications as read
+//	@Description	Marks a notification as read and returns how many are still unread
+//	@Tags			notifications
+//	@Produce		json
+//	@Param			id	path	int	true	"Notification ID"
+//	@Success		200
+//	@Failure		404
+//	@Router			/api/v1/me/notifications/{id}/read [post]
+//	@Security		BearerAuth
+func (app *application) markNotificationRead(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid notification ID"})
+		return
+	}
+	user := app.GetUserFromContext(c)
+	if err := app.Model.Notifications.MarkRead(user.ID, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Notification not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update notification"})
+		return
+	}
+	app.respondUnreadCount(c, user.ID)
+}
+
+// MarkAllNotificationsRead marks every notification of the caller as read
+//
+//	@Summary		Marks every notification of the caller as read
+//	@Description	Marks every unread notification as read
+//	@Tags			notifications
+//	@Produce		json
+//	@Success		200
+//	@Router			/api/v1/me/notifications/read-all [post]
+//	@Security		BearerAuth
+func (app *application) markAllNotificationsRead(c *gin.Context) {
+	user := app.GetUserFromContext(c)
+	if _, err := app.Model.Notifications.MarkAllRead(user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update notifications"})
+		return
+	}
+	app.respondUnreadCount(c, user.ID)
+}
+
+// respondUnreadCount answers with how many notifications of the user are
+// still unread.
+func (app *application) respondUnreadCount(c *gin.Context, userID int) {
+	count, err := app.Model.Notifications.UnreadCount(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count notifications"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"unread_count": count})
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Yiheyistm/go-restful-api/internal/database"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// maxOccurrenceRange bounds how far a single request may expand
+// unbounded recurrence rules.
+const maxOccurrenceRange = 366 * 24 * time.Hour
+
+type updateOccurrenceRequest struct {
+	Name        *string `json:"name" binding:"omitempty,min=3,max=100"`
+	Description *string `json:"description" binding:"omitempty,min=10,max=500"`
+	Date        *string `json:"date" binding:"omitempty,datetime=2006-01-02"`
+	Location    *string `json:"location" binding:"omitempty,min=3,max=100"`
+	// RRule only applies to scope=following, where it replaces the rule of
+	// the new series.
+	RRule *string `json:"rrule" binding:"omitempty,rrule"`
+}
+
+// occurrenceParam reads the ?occurrence= date an attendee operation
+// targets. Recurring events need one of their occurrence dates; single
+// events take none (or their own date).
+func occurrenceParam(c *gin.Context, event *database.Event) (string, bool) {
+	occurrence := c.Query("occurrence")
+	if !event.IsRecurring() {
+		if occurrence != "" && occurrence != event.Date {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "This event does not recur"})
+			return "", false
+		}
+		return "", true
+	}
+	if occurrence == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "occurrence is required for recurring events"})
+		return "", false
+	}
+	if !event.HasOccurrence(occurrence) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": database.ErrNotAnOccurrence.Error()})
+		return "", false
+	}
+	return occurrence, true
+}
+
+func (app *application) getEventOccurrences(c *gin.Context, filter database.EventFilter) {
+	from, errFrom := time.Parse(time.DateOnly, c.Query("from"))
+	to, errTo := time.Parse(time.DateOnly, c.Query("to"))
+	if errFrom != nil || errTo != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from and to must both be dates (YYYY-MM-DD)"})
+		return
+	}
+	if to.Before(from) || to.Sub(from) > maxOccurrenceRange {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "to must be after from and at most 366 days later"})
+		return
+	}
+
+	occurrences, err := app.Model.Events.GetOccurrences(from, to, filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve events"})
+		return
+	}
+	c.JSON(http.StatusOK, occurrences)
+}
+
+// UpdateOccurrence edits one or more occurrences of a recurring event
+//
+//	@Summary		Edits occurrences of a recurring event
+//	@Description	scope=this edits only the given occurrence; scope=following splits the series and applies the changes to this and every later occurrence. Use PUT /events/{id} to edit all occurrences.
+//	@Tags			events
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int						true	"Event ID"
+//	@Param			date	path		string					true	"Occurrence date (YYYY-MM-DD)"
+//	@Param			scope	query		string					false	"this (default) or following"
+//	@Param			changes	body		updateOccurrenceRequest	true	"Changes"
+//	@Success		200		{object}	database.Occurrence
+//	@Success		201		{object}	database.Event
+//	@Router			/api/v1/events/{id}/occurrences/{date} [put]
+//	@Security		BearerAuth
+func (app *application) updateOccurrence(c *gin.Context) {
+	event, date, ok := app.recurringEventForOwner(c)
+	if !ok {
+		return
+	}
+	var changes updateOccurrenceRequest
+	if err := c.ShouldBindJSON(&changes); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	switch c.DefaultQuery("scope", "this") {
+	case "this":
+		if changes.RRule != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "rrule can only be changed with scope=following"})
+			return
+		}
+		occurrence, err := app.Model.Events.GetOccurrence(event, date)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve occurrence"})
+			return
+		}
+		setIfPresent(&occurrence.Name, changes.Name)
+		setIfPresent(&occurrence.Description, changes.Description)
+		setIfPresent(&occurrence.Date, changes.Date)
+		setIfPresent(&occurrence.Location, changes.Location)
+		if err := app.Model.Events.SaveOccurrence(app.actor(c), event, occurrence); err != nil {
+			if errors.Is(err, database.ErrEditConflict) {
+				c.JSON(http.StatusPreconditionFailed, gin.H{"error": "Event has been modified since it was retrieved"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update occurrence"})
+			return
+		}
+		c.Header("ETag", eventETag(event))
+		c.JSON(http.StatusOK, occurrence)
+
+	case "following":
+		if date == event.Date {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "This is the first occurrence; use PUT /events/{id} to edit the whole series"})
+			return
+		}
+		next := *event
+		next.ID, next.Version, next.ExDates, next.Rating = 0, 0, nil, nil
+		next.Date = date
+		setIfPresent(&next.Name, changes.Name)
+		setIfPresent(&next.Description, changes.Description)
+		setIfPresent(&next.Date, changes.Date)
+		setIfPresent(&next.Location, changes.Location)
+		setIfPresent(&next.RRule, changes.RRule)
+		if err := binding.Validator.ValidateStruct(&next); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		at, _ := time.Parse(time.DateOnly, date)
+		if err := app.Model.Events.SplitSeries(app.actor(c), event, at, &next); err != nil {
+			if errors.Is(err, database.ErrEditConflict) {
+				c.JSON(http.StatusPreconditionFailed, gin.H{"error": "Event has been modified since it was retrieved"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to split event series"})
+			return
+		}
+		c.Header("ETag", eventETag(&next))
+		c.JSON(http.StatusCreated, next)
+
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "scope must be this or following"})
+	}
+}
+
+// DeleteOccurrence cancels one or more occurrences of a recurring event
+//
+//	@Summary		Cancels occurrences of a recurring event
+//	@Description	scope=this removes only the given occurrence; scope=following ends the series before it. Attendees of removed occurrences are dropped.
+//	@Tags			events
+//	@Param			id		path	int		true	"Event ID"
+//	@Param			date	path	string	true	"Occurrence date (YYYY-MM-DD)"
+//	@Param			scope	query	string	false	"this (default) or following"
+//	@Success		204
+//	@Router			/api/v1/events/{id}/occurrences/{date} [delete]
+//	@Security		BearerAuth
+func (app *application) deleteOccurrence(c *gin.Context) {
+	event, date, ok := app.recurringEventForOwner(c)
+	if !ok {
+		return
+	}
+	var err error
+	switch c.DefaultQuery("scope", "this") {
+	case "this":
+		err = app.Model.Events.CancelOccurrence(app.actor(c), event, date)
+	case "following":
+		if date == event.Date {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "This is the first occurrence; use DELETE /events/{id} to remove the whole series"})
+			return
+		}
+		at, _ := time.Parse(time.DateOnly, date)
+		err = app.Model.Events.EndSeries(app.actor(c), event, at)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "scope must be this or following"})
+		return
+	}
+	if err != nil {
+		if errors.Is(err, database.ErrEditConflict) {
+			c.JSON(http.StatusPreconditionFailed, gin.H{"error": "Event has been modified since it was retrieved"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel occurrence"})
+		return
+	}
+	c.JSON(http.StatusNoContent, nil)
+}
+
+// recurringEventForOwner loads the event and occurrence date named in the
+// path and checks that the caller owns it and that the date is valid.
+func (app *application) recurringEventForOwner(c *gin.Context) (*database.Event, string, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		return nil, "", false
+	}
+	event, err := app.Model.Events.GetByID(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return nil, "", false
+	}
+	if !app.canManageEvent(c, event, database.PermissionEdit) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You do not have permission to update this event"})
+		return nil, "", false
+	}
+	if !eventEditable(c, event) || !checkIfMatch(c, eventETag(event)) {
+		return nil, "", false
+	}
+	if !event.IsRecurring() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "This event does not recur"})
+		return nil, "", false
+	}
+	date := c.Param("date")
+	if !event.HasOccurrence(date) {
+		c.JSON(http.StatusNotFound, gin.H{"error": database.ErrNotAnOccurrence.Error()})
+		return nil, "", false
+	}
+	return event, date, true
+}
+
+func setIfPresent(field *string, value *string) {
+	if value != nil {
+		*field = *value
+	}
+}
@@ -0,0 +1,441 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Yiheyistm/go-restful-api/internal/database"
+	"github.com/Yiheyistm/go-restful-api/internal/payment"
+	"github.com/gin-gonic/gin"
+)
+
+type checkoutRequest struct {
+	TicketTypeID int              `json:"ticket_type_id" binding:"required,min=1"`
+	PromoCode    string           `json:"promo_code" binding:"max=32"`
+	Answers      database.Answers `json:"answers"`
+}
+
+// GetTicketTypes lists the ticket tiers of an event
+//
+//	@Summary		Lists the ticket tiers of an event
+//	@Description	Lists the ticket tiers of an event with how many tickets each has left
+//	@Tags			ticketing
+//	@Produce		json
+//	@Param			id		path	int		true	"Event ID"
+//	@Param			invite	query	string	false	"Invite token for a private event"
+//	@Success		200		{array}	database.TicketType
+//	@Router			/api/v1/events/{id}/ticket-types [get]
+func (app *application) getTicketTypes(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		return
+	}
+	event, err := app.Model.Events.GetByID(id)
+	if err != nil || !app.canViewEvent(c, event) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+	ticketTypes, err := app.Model.TicketTypes.GetByEvent(event.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve ticket types"})
+		return
+	}
+	c.JSON(http.StatusOK, ticketTypes)
+}
+
+// CreateTicketType adds a ticket tier to an event
+//
+//	@Summary		Adds a ticket tier to an event
+//	@Description	Adds a ticket tier with a price in minor units, an ISO 4217 currency, a quantity and an optional sales window. Once an event has tiers, attendees join through checkout instead of RSVP.
+//	@Tags			ticketing
+//	@Accept			json
+//	@Produce		json
+//	@Param			id			path		int					true	"Event ID"
+//	@Param			ticketType	body		database.TicketType	true	"Ticket type"
+//	@Success		201			{object}	database.TicketType
+//	@Router			/api/v1/events/{id}/ticket-types [post]
+//	@Security		BearerAuth
+func (app *application) createTicketType(c *gin.Context) {
+	event, ok := app.managedEvent(c, database.PermissionEdit)
+	if !ok || !eventEditable(c, event) {
+		return
+	}
+	var ticketType database.TicketType
+	if err := c.ShouldBindJSON(&ticketType); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if ticketType.SalesStart != nil && ticketType.SalesEnd != nil && !ticketType.SalesEnd.After(*ticketType.SalesStart) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "sales_end must be after sales_start"})
+		return
+	}
+	ticketType.EventID = event.ID
+	if err := app.Model.TicketTypes.Insert(&ticketType); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create ticket type"})
+		return
+	}
+	c.JSON(http.StatusCreated, ticketType)
+}
+
+// DeleteTicketType removes a ticket tier from an event
+//
+//	@Summary		Removes a ticket tier from an event
+//	@Description	Removes a ticket tier that has never been ordered
+//	@Tags			ticketing
+//	@Param			id				path	int	true	"Event ID"
+//	@Param			ticketTypeId	path	int	true	"Ticket type ID"
+//	@Success		204
+//	@Router			/api/v1/events/{id}/ticket-types/{ticketTypeId} [delete]
+//	@Security		BearerAuth
+func (app *application) deleteTicketType(c *gin.Context) {
+	event, ok := app.managedEvent(c, database.PermissionEdit)
+	if !ok {
+		return
+	}
+	ticketTypeID, err := strconv.Atoi(c.Param("ticketTypeId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ticket type ID"})
+		return
+	}
+	if err := app.Model.TicketTypes.Delete(event.ID, ticketTypeID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Ticket type not found or already ordered"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete ticket type"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// GetPromoCodes lists the promo codes of an event
+//
+//	@Summary		Lists the promo codes of an event
+//	@Description	Lists the promo codes of an event with how often each has been used
+//	@Tags			ticketing
+//	@Produce		json
+//	@Param			id	path	int	true	"Event ID"
+//	@Success		200	{array}	database.PromoCode
+//	@Router			/api/v1/events/{id}/promo-codes [get]
+//	@Security		BearerAuth
+func (app *application) getPromoCodes(c *gin.Context) {
+	event, ok := app.managedEvent(c, database.PermissionEdit)
+	if !ok {
+		return
+	}
+	promos, err := app.Model.PromoCodes.GetByEvent(event.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve promo codes"})
+		return
+	}
+	c.JSON(http.StatusOK, promos)
+}
+
+// CreatePromoCode adds a promo code to an event
+//
+//	@Summary		Adds a promo code to an event
+//	@Description	Adds a case-insensitive promo code taking a percentage or a fixed amount off, with an optional usage limit
+//	@Tags			ticketing
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int					true	"Event ID"
+//	@Param			promo	body		database.PromoCode	true	"Promo code"
+//	@Success		201		{object}	database.PromoCode
+//	@Failure		409
+//	@Router			/api/v1/events/{id}/promo-codes [post]
+//	@Security		BearerAuth
+func (app *application) createPromoCode(c *gin.Context) {
+	event, ok := app.managedEvent(c, database.PermissionEdit)
+	if !ok || !eventEditable(c, event) {
+		return
+	}
+	var promo database.PromoCode
+	if err := c.ShouldBindJSON(&promo); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if promo.Kind == database.PromoKindPercent && promo.Amount > 100 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A percent promo code cannot take more than 100 off"})
+		return
+	}
+	existing, err := app.Model.PromoCodes.GetByCode(event.ID, promo.Code)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check promo code"})
+		return
+	}
+	if existing != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Promo code already exists"})
+		return
+	}
+	promo.EventID = event.ID
+	if err := app.Model.PromoCodes.Insert(&promo); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create promo code"})
+		return
+	}
+	c.JSON(http.StatusCreated, promo)
+}
+
+// DeletePromoCode removes a promo code from an event
+//
+//	@Summary		Removes a promo code from an event
+//	@Description	Removes a promo code. Orders that already used it keep their price.
+//	@Tags			ticketing
+//	@Param			id			path	int	true	"Event ID"
+//	@Param			promoCodeId	path	int	true	"Promo code ID"
+//	@Success		204
+//	@Router			/api/v1/events/{id}/promo-codes/{promoCodeId} [delete]
+//	@Security		BearerAuth
+func (app *application) deletePromoCode(c *gin.Context) {
+	event, ok := app.managedEvent(c, database.PermissionEdit)
+	if !ok {
+		return
+	}
+	promoCodeID, err := strconv.Atoi(c.Param("promoCodeId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid promo code ID"})
+		return
+	}
+	if err := app.Model.PromoCodes.Delete(event.ID, promoCodeID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Promo code not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete promo code"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// Checkout orders a ticket for the caller
+//
+//	@Summary		Orders a ticket for the caller
+//	@Description	Reserves one ticket of a tier for the caller until the order expires and starts a payment with the provider. Free orders are settled at once. A ticket the waitlist holds for the caller is taken over, with the expiry of its hold; a sold-out tier can be waited for with POST /events/{id}/waitlist.
+//	@Tags			ticketing
+//	@Accept			json
+//	@Produce		json
+//	@Param			id			path		int				true	"Event ID"
+//	@Param			occurrence	query		string			false	"Occurrence date (YYYY-MM-DD), required for recurring events"
+//	@Param			invite		query		string			false	"Invite token for a private event"
+//	@Param			checkout	body		checkoutRequest	true	"Ticket type, optional promo code and registration answers"
+//	@Success		201			{object}	database.Order
+//	@Failure		409
+//	@Router			/api/v1/events/{id}/checkout [post]
+//	@Security		BearerAuth
+func (app *application) checkout(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		return
+	}
+	user := app.GetUserFromContext(c)
+	event, err := app.Model.Events.GetByID(id)
+	if err != nil || !app.canViewEvent(c, event) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+	if event.Status != database.EventStatusPublished {
+		c.JSON(http.StatusConflict, gin.H{"error": "Only published events sell tickets"})
+		return
+	}
+	occurrence, ok := occurrenceParam(c, event)
+	if !ok {
+		return
+	}
+	var request checkoutRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ticketType, err := app.Model.TicketTypes.Get(event.ID, request.TicketTypeID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve ticket type"})
+		return
+	}
+	if ticketType == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Ticket type not found"})
+		return
+	}
+	if !ticketType.OnSale(time.Now()) {
+		c.JSON(http.StatusConflict, gin.H{"error": "This ticket type is not on sale"})
+		return
+	}
+	existedAttendee, err := app.Model.Attendees.GetByEventAndUserId(event.ID, user.ID, occurrence)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check attendee"})
+		return
+	}
+	if existedAttendee != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "You are already attending this event"})
+		return
+	}
+
+	answers, ok := app.validateAnswers(c, event, request.Answers)
+	if !ok {
+		return
+	}
+
+	order := &database.Order{
+		EventID:        event.ID,
+		UserID:         user.ID,
+		TicketTypeID:   ticketType.ID,
+		OccurrenceDate: occurrence,
+		AmountMinor:    ticketType.PriceMinor,
+		Currency:       ticketType.Currency,
+		ExpiresAt:      time.Now().Add(app.OrderTTL),
+		Answers:        answers,
+	}
+	if request.PromoCode != "" {
+		promo, err := app.Model.PromoCodes.GetByCode(event.ID, request.PromoCode)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check promo code"})
+			return
+		}
+		if promo == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid promo code"})
+			return
+		}
+		order.PromoCodeID = &promo.ID
+		order.AmountMinor = promo.Apply(ticketType.PriceMinor)
+	}
+
+	if err := app.Model.Orders.Reserve(order); err != nil {
+		if errors.Is(err, database.ErrSoldOut) || errors.Is(err, database.ErrPromoExhausted) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reserve ticket"})
+		return
+	}
+
+	if order.AmountMinor == 0 {
+		if err := app.Model.Orders.MarkPaid(app.actor(c), order); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to complete order"})
+			return
+		}
+		c.JSON(http.StatusCreated, gin.H{"order": order})
+		return
+	}
+
+	session, err := app.Payments.CreateCheckout(c.Request.Context(), payment.Checkout{
+		OrderID:     order.ID,
+		AmountMinor: order.AmountMinor,
+		Currency:    order.Currency,
+		Description: fmt.Sprintf("%s: %s", event.Name, ticketType.Name),
+	})
+	if err != nil {
+		log.Printf("Payment checkout for order %d failed: %v", order.ID, err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to start payment"})
+		return
+	}
+	if err := app.Model.Orders.SetProvider(order, app.Payments.Name(), session.Reference); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start payment"})
+		return
+	}
+	response := gin.H{"order": order}
+	if session.URL != "" {
+		response["payment_url"] = session.URL
+	}
+	c.JSON(http.StatusCreated, response)
+}
+
+// GetOrder returns one of the caller's orders
+//
+//	@Summary		Returns one of the caller's orders
+//	@Description	Returns an order of the caller, to follow its payment. Once paid, attendee_id names the ticket to show at check-in.
+//	@Tags			ticketing
+//	@Produce		json
+//	@Param			id	path		int	true	"Order ID"
+//	@Success		200	{object}	database.Order
+//	@Router			/api/v1/me/orders/{id} [get]
+//	@Security		BearerAuth
+func (app *application) getOrder(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+	order, err := app.Model.Orders.Get(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve order"})
+		return
+	}
+	if order == nil || order.UserID != app.GetUserFromContext(c).ID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return
+	}
+	c.JSON(http.StatusOK, order)
+}
+
+// PaymentWebhook receives payment outcomes from the provider
+//
+//	@Summary		Receives payment outcomes from the provider
+//	@Description	Verifies the provider's signature and settles or fails the order the payment belongs to. Repeated deliveries are harmless.
+//	@Tags			ticketing
+//	@Accept			json
+//	@Param			Payment-Signature	header	string	true	"t=<unix seconds>,v1=<hex HMAC-SHA256>"
+//	@Success		204
+//	@Failure		401
+//	@Router			/api/v1/payments/webhook [post]
+func (app *application) paymentWebhook(c *gin.Context) {
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, 1<<20))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+		return
+	}
+	event, err := app.Payments.ParseWebhook(c.Request.Header, body)
+	if err != nil {
+		if errors.Is(err, payment.ErrInvalidSignature) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	order, err := app.Model.Orders.GetByProviderRef(app.Payments.Name(), event.Reference)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve order"})
+		return
+	}
+	if order == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return
+	}
+
+	switch event.Type {
+	case payment.EventSucceeded:
+		if err := app.Model.Orders.MarkPaid(app.actor(c), order); err != nil {
+			if errors.Is(err, database.ErrSoldOut) {
+				// The reservation lapsed and the tier sold out meanwhile;
+				// the payment has to be refunded at the provider.
+				log.Printf("Order %d was paid after its tickets sold out", order.ID)
+				c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to complete order"})
+			return
+		}
+	case payment.EventFailed:
+		if err := app.Model.Orders.MarkFailed(order); err != nil && !errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update order"})
+			return
+		}
+		// The released ticket goes to the waitlist now rather than on the
+		// next scan; if this fails, the scan picks it up.
+		if _, err := app.Model.Waitlist.Promote(app.OrderTTL); err != nil {
+			log.Printf("Failed to promote from the waitlist after order %d failed: %v", order.ID, err)
+		}
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown webhook event type"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/Yiheyistm/go-restful-api/internal/database"
+	"github.com/gin-gonic/gin"
+)
+
+type setOrganizerRequest struct {
+	Role string `json:"role" binding:"required,oneof=co-host checkin"`
+}
+
+type transferOwnershipRequest struct {
+	UserID int `json:"user_id" binding:"required,min=1"`
+}
+
+// eventRole returns the caller's organizer role on event, or "" when they
+// are not one of its organizers.
+func (app *application) eventRole(c *gin.Context, event *database.Event) string {
+	user := app.GetUserFromContext(c)
+	if user.ID == 0 {
+		return ""
+	}
+	if user.ID == event.OwnerId {
+		return database.OrganizerRoleOwner
+	}
+	role, err := app.Model.Organizers.GetRole(event.ID, user.ID)
+	if err != nil {
+		log.Printf("Failed to check organizer role for event %d: %v", event.ID, err)
+	}
+	return role
+}
+
+// canManageEvent reports whether the caller's organizer role on event
+// grants permission.
+func (app *application) canManageEvent(c *gin.Context, event *database.Event, permission string) bool {
+	return database.RoleAllows(app.eventRole(c, event), permission)
+}
+
+// managedEvent loads the event named by the id parameter and checks that
+// the caller's organizer role grants permission, answering the request
+// itself when not.
+func (app *application) managedEvent(c *gin.Context, permission string) (*database.Event, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		return nil, false
+	}
+	event, err := app.Model.Events.GetByID(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return nil, false
+	}
+	if !app.canManageEvent(c, event, permission) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You do not have permission to manage this event"})
+		return nil, false
+	}
+	return event, true
+}
+
+// GetEventOrganizers lists the organizers of an event
+//
+//	@Summary		Lists the organizers of an event
+//	@Description	Lists the owner, co-hosts and check-in staff of an event. Only its organizers may read it.
+//	@Tags			organizers
+//	@Produce		json
+//	@Param			id	path	int	true	"Event ID"
+//	@Success		200	{array}	database.Organizer
+//	@Router			/api/v1/events/{id}/organizers [get]
+//	@Security		BearerAuth
+func (app *application) getEventOrganizers(c *gin.Context) {
+	event, ok := app.managedEvent(c, database.PermissionCheckIn)
+	if !ok {
+		return
+	}
+	organizers, err := app.Model.Organizers.GetByEvent(event.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve organizers"})
+		return
+	}
+	c.JSON(http.StatusOK, organizers)
+}
+
+// SetEventOrganizer adds an organizer to an event or changes their role
+//
+//	@Summary		Adds an organizer to an event or changes their role
+//	@Description	Makes a user a co-host or check-in staff of an event. Only the owner may do this; the owner's own role changes through a transfer.
+//	@Tags			organizers
+//	@Accept			json
+//	@Produce		json
+//	@Param			id			path	int					true	"Event ID"
+//	@Param			userId		path	int					true	"User ID"
+//	@Param			organizer	body	setOrganizerRequest	true	"Role (co-host or checkin)"
+//	@Success		200			{array}	database.Organizer
+//	@Failure		409
+//	@Router			/api/v1/events/{id}/organizers/{userId} [put]
+//	@Security		BearerAuth
+func (app *application) setEventOrganizer(c *gin.Context) {
+	event, ok := app.managedEvent(c, database.PermissionOwn)
+	if !ok {
+		return
+	}
+	userID, err := strconv.Atoi(c.Param("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+	var request setOrganizerRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if _, err := app.Model.Users.Get(userID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if err := app.Model.Organizers.Set(app.actor(c), event.ID, userID, request.Role); err != nil {
+		if errors.Is(err, database.ErrOwnerRole) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set organizer"})
+		return
+	}
+	organizers, err := app.Model.Organizers.GetByEvent(event.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve organizers"})
+		return
+	}
+	c.JSON(http.StatusOK, organizers)
+}
+
+// RemoveEventOrganizer removes an organizer from an event
+//
+//	@Summary		Removes an organizer from an event
+//	@Description	Removes a co-host or check-in staff member. The owner may remove anyone but themselves; other organizers may step down.
+//	@Tags			organizers
+//	@Param			id		path	int	true	"Event ID"
+//	@Param			userId	path	int	true	"User ID"
+//	@Success		204
+//	@Failure		409
+//	@Router			/api/v1/events/{id}/organizers/{userId} [delete]
+//	@Security		BearerAuth
+func (app *application) removeEventOrganizer(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		return
+	}
+	userID, err := strconv.Atoi(c.Param("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+	event, err := app.Model.Events.GetByID(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+	if userID != app.GetUserFromContext(c).ID && !app.canManageEvent(c, event, database.PermissionOwn) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You do not have permission to manage this event"})
+		return
+	}
+
+	if err := app.Model.Organizers.Remove(app.actor(c), event.ID, userID); err != nil {
+		if errors.Is(err, database.ErrOwnerRole) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Organizer not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove organizer"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// TransferEventOwnership hands an event over to another user
+//
+//	@Summary		Hands an event over to another user
+//	@Description	Makes another user the owner of an event. The previous owner stays on as a co-host.
+//	@Tags			organizers
+//	@Accept			json
+//	@Produce		json
+//	@Param			id			path		int							true	"Event ID"
+//	@Param			If-Match	header		string						false	"ETag the transfer is based on"
+//	@Param			transfer	body		transferOwnershipRequest	true	"New owner"
+//	@Success		200			{object}	database.Event
+//	@Failure		412
+//	@Router			/api/v1/events/{id}/transfer [post]
+//	@Security		BearerAuth
+func (app *application) transferEventOwnership(c *gin.Context) {
+	event, ok := app.managedEvent(c, database.PermissionOwn)
+	if !ok {
+		return
+	}
+	if !checkIfMatch(c, eventETag(event)) {
+		return
+	}
+	var request transferOwnershipRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if request.UserID == event.OwnerId {
+		c.JSON(http.StatusConflict, gin.H{"error": "User already owns this event"})
+		return
+	}
+	if _, err := app.Model.Users.Get(request.UserID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if err := app.Model.Organizers.TransferOwnership(app.actor(c), event, request.UserID); err != nil {
+		if errors.Is(err, database.ErrEditConflict) {
+			c.JSON(http.StatusPreconditionFailed, gin.H{"error": "Event has been modified since it was retrieved"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to transfer ownership"})
+		return
+	}
+	c.Header("ETag", eventETag(event))
+	c.JSON(http.StatusOK, event)
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Yiheyistm/go-restful-api/internal/database"
+)
+
+// purgeDeleted is the recurring purge job. It hard-deletes soft-deleted
+// events and users, succeeded jobs, published domain events, read
+// notifications and the record of past reminders once they are older than
+// the retention period.
+func (app *application) purgeDeleted(ctx context.Context, job *database.Job) error {
+	cutoff := time.Now().Add(-app.SoftDeleteRetention)
+	actor := database.Actor{RequestID: fmt.Sprintf("job-%d", job.ID)}
+
+	events, err := app.Model.Events.Purge(actor, cutoff)
+	if err != nil {
+		return err
+	}
+	users, err := app.Model.Users.Purge(actor, cutoff)
+	if err != nil {
+		return err
+	}
+	jobs, err := app.Model.Jobs.Purge(cutoff)
+	if err != nil {
+		return err
+	}
+	published, err := app.Model.Outbox.Purge(cutoff)
+	if err != nil {
+		return err
+	}
+	reminders, err := app.Model.Reminders.Purge(cutoff)
+	if err != nil {
+		return err
+	}
+	notifications, err := app.Model.Notifications.Purge(cutoff)
+	if err != nil {
+		return err
+	}
+	if events > 0 || users > 0 || jobs > 0 || published > 0 || reminders > 0 || notifications > 0 {
+		log.Printf("Purged %d events and %d users deleted, %d jobs finished, %d domain events published, %d reminders for occurrences started and %d notifications read before %s",
+			events, users, jobs, published, reminders, notifications, cutoff.Format(time.RFC3339))
+	}
+	return nil
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Yiheyistm/go-restful-api/internal/database"
+	"github.com/Yiheyistm/go-restful-api/internal/notify"
+	"github.com/Yiheyistm/go-restful-api/internal/webhook"
+	"github.com/gin-gonic/gin"
+)
+
+// setPreferencesRequest changes the fields that are present and keeps the
+// others.
+type setPreferencesRequest struct {
+	Reminders *bool `json:"reminders"`
+	Email     *bool `json:"email"`
+	Webhook   *bool `json:"webhook"`
+	InApp     *bool `json:"in_app"`
+}
+
+// reminderData is the data of an event.reminder webhook delivery.
+type reminderData struct {
+	EventID        int    `json:"event_id"`
+	OccurrenceDate string `json:"occurrence_date,omitempty"`
+	Name           string `json:"name"`
+	Location       string `json:"location"`
+	StartsOn       string `json:"starts_on"`
+}
+
+// parseReminderOffsets parses a comma-separated list of durations before
+// an event's start at which to remind its attendees, such as "24h,1h".
+func parseReminderOffsets(value string) ([]time.Duration, error) {
+	var offsets []time.Duration
+	for _, field := range strings.Split(value, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		offset, err := time.ParseDuration(field)
+		if err != nil || offset <= 0 {
+			return nil, fmt.Errorf("invalid reminder offset %q", field)
+		}
+		offsets = append(offsets, offset)
+	}
+	return offsets, nil
+}
+
+// sendReminders is the recurring JobReminderScan job. It sends every
+// reminder that is due through the channels its attendee chose.
+func (app *application) sendReminders(ctx context.Context, job *database.Job) error {
+	reminders, err := app.Model.Reminders.Due(time.Now(), app.ReminderOffsets)
+	if err != nil {
+		return err
+	}
+	sent := 0
+	for _, reminder := range reminders {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		deliveries, err := reminderDeliveries(reminder)
+		if err != nil {
+			return err
+		}
+		ok, err := app.Model.Reminders.Send(reminder, deliveries)
+		if err != nil {
+			return err
+		}
+		if ok {
+			sent++
+		}
+	}
+	if sent > 0 {
+		log.Printf("Sent %d event reminders", sent)
+	}
+	return nil
+}
+
+// reminderDeliveries builds what a reminder sends through the channels
+// its attendee has on: a notification job for email, an entry in the
+// in-app inbox and webhook deliveries.
+func reminderDeliveries(reminder *database.Reminder) (database.ReminderDeliveries, error) {
+	var deliveries database.ReminderDeliveries
+	subject := fmt.Sprintf("Reminder: %s on %s", reminder.Name, reminder.StartsOn)
+	body := fmt.Sprintf("%s starts on %s at %s.", reminder.Name, reminder.StartsOn, reminder.Location)
+	if reminder.Preferences.Email {
+		job, err := database.NewJob(jobNotify, notify.Message{
+			UserID:  reminder.UserID,
+			Email:   reminder.Email,
+			EventID: reminder.EventID,
+			Subject: subject,
+			Body:    body,
+		})
+		if err != nil {
+			return deliveries, err
+		}
+		deliveries.Jobs = append(deliveries.Jobs, job)
+	}
+	if reminder.Preferences.InApp {
+		deliveries.Notification = &database.Notification{
+			Type:    database.NotificationEventReminder,
+			EventID: &reminder.EventID,
+			Title:   subject,
+			Body:    body,
+			Key:     fmt.Sprintf("%s:%d:%s:%d", database.NotificationEventReminder, reminder.EventID, reminder.StartsOn, int64(reminder.Offset/time.Second)),
+		}
+	}
+	if reminder.Preferences.Webhook {
+		data, err := json.Marshal(reminderData{
+			EventID:        reminder.EventID,
+			OccurrenceDate: reminder.OccurrenceDate,
+			Name:           reminder.Name,
+			Location:       reminder.Location,
+			StartsOn:       reminder.StartsOn,
+		})
+		if err != nil {
+			return deliveries, err
+		}
+		deliveries.WebhookType = webhook.EventReminder
+		deliveries.WebhookPayload, err = json.Marshal(webhookEnvelope{
+			ID:        fmt.Sprintf("rem_%d_%d_%s_%d", reminder.EventID, reminder.UserID, reminder.StartsOn, int64(reminder.Offset/time.Second)),
+			Type:      webhook.EventReminder,
+			CreatedAt: time.Now().UTC(),
+			Data:      data,
+		})
+		if err != nil {
+			return deliveries, err
+		}
+	}
+	return deliveries, nil
+}
+
+// GetNotificationPreferences returns the caller's notification preferences
+//
+//	@Summary		Returns the caller's notification preferences
+//	@Description	Returns whether the caller gets event reminders and through which channels (email, webhook, in-app) notifications reach them. Everything is on until changed.
+//	@Tags			notifications
+//	@Produce		json
+//	@Success		200	{object}	database.NotificationPreferences
+//	@Router			/api/v1/me/notification-preferences [get]
+//	@Security		BearerAuth
+func (app *application) getNotificationPreferences(c *gin.Context) {
+	preferences, err := app.Model.Users.GetPreferences(app.GetUserFromContext(c).ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve notification preferences"})
+		return
+	}
+	c.JSON(http.StatusOK, preferences)
+}
+
+// SetNotificationPreferences changes the caller's notification preferences
+//
+//	@Summary		Changes the caller's notification preferences
+//	@Description	Changes the preferences present in the body and keeps the others. reminders=false opts out of event reminders; email, webhook and in_app turn each channel on or off. Webhook reminders go to the caller's webhooks subscribed to event.reminder.
+//	@Tags			notifications
+//	@Accept			json
+//	@Produce		json
+//	@Param			preferences	body		setPreferencesRequest	true	"Preferences to change"
+//	@Success		200			{object}	database.NotificationPreferences
+//	@Router			/api/v1/me/notification-preferences [patch]
+//	@Security		BearerAuth
+func (app *application) setNotificationPreferences(c *gin.Context) {
+	var request setPreferencesRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	user := app.GetUserFromContext(c)
+	preferences, err := app.Model.Users.GetPreferences(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve notification preferences"})
+		return
+	}
+	if request.Reminders != nil {
+		preferences.Reminders = *request.Reminders
+	}
+	if request.Email != nil {
+		preferences.Email = *request.Email
+	}
+	if request.Webhook != nil {
+		preferences.Webhook = *request.Webhook
+	}
+	if request.InApp != nil {
+		preferences.InApp = *request.InApp
+	}
+	if err := app.Model.Users.SetPreferences(user.ID, preferences); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update notification preferences"})
+		return
+	}
+	c.JSON(http.StatusOK, preferences)
+}
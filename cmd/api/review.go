@@ -0,0 +1,173 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Yiheyistm/go-restful-api/internal/database"
+	"github.com/gin-gonic/gin"
+)
+
+type createReviewRequest struct {
+	Rating int    `json:"rating" binding:"required,min=1,max=5"`
+	Body   string `json:"body" binding:"max=5000"`
+}
+
+type replyReviewRequest struct {
+	Reply string `json:"reply" binding:"required,max=5000"`
+}
+
+// GetReviews lists the reviews of an event
+//
+//	@Summary		Lists the reviews of an event
+//	@Description	Lists the ratings and reviews attendees left on an event, newest first, with the organizers' replies. The event's average rating is part of the event itself.
+//	@Tags			reviews
+//	@Produce		json
+//	@Param			id		path	int		true	"Event ID"
+//	@Param			limit	query	int		false	"Maximum number of reviews (default 20, at most 100)"
+//	@Param			offset	query	int		false	"Number of reviews to skip"
+//	@Param			invite	query	string	false	"Invite token for a private event"
+//	@Success		200		{array}	database.Review
+//	@Router			/api/v1/events/{id}/reviews [get]
+func (app *application) getReviews(c *gin.Context) {
+	event, ok := app.viewableEvent(c)
+	if !ok {
+		return
+	}
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	offset, _ := strconv.Atoi(c.Query("offset"))
+	reviews, err := app.Model.Reviews.GetByEvent(event.ID, limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve reviews"})
+		return
+	}
+	c.JSON(http.StatusOK, reviews)
+}
+
+// CreateReview rates and reviews an event
+//
+//	@Summary		Rates and reviews an event
+//	@Description	Leaves a 1 to 5 star rating, with an optional review, on an event the caller checked in to once the day it took place is over. Each attendee can review an event once; its organizers cannot review it.
+//	@Tags			reviews
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int					true	"Event ID"
+//	@Param			review	body		createReviewRequest	true	"Review"
+//	@Success		201		{object}	database.Review
+//	@Failure		403
+//	@Failure		409
+//	@Router			/api/v1/events/{id}/reviews [post]
+//	@Security		BearerAuth
+func (app *application) createReview(c *gin.Context) {
+	event, ok := app.viewableEvent(c)
+	if !ok {
+		return
+	}
+	var request createReviewRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user := app.GetUserFromContext(c)
+	review := &database.Review{
+		EventID:  event.ID,
+		UserID:   user.ID,
+		Username: user.Username,
+		Rating:   request.Rating,
+		Body:     strings.TrimSpace(request.Body),
+	}
+	if err := app.Model.Reviews.Insert(review); err != nil {
+		switch {
+		case errors.Is(err, database.ErrNotReviewable):
+			c.JSON(http.StatusForbidden, gin.H{"error": "Only attendees who checked in can review an event, once it is over"})
+		case errors.Is(err, database.ErrOwnEvent):
+			c.JSON(http.StatusForbidden, gin.H{"error": "Organizers cannot review their own event"})
+		case errors.Is(err, database.ErrDuplicateReview):
+			c.JSON(http.StatusConflict, gin.H{"error": "You have already reviewed this event"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save review"})
+		}
+		return
+	}
+	c.JSON(http.StatusCreated, review)
+}
+
+// ReplyToReview answers a review of an event
+//
+//	@Summary		Answers a review of an event
+//	@Description	Sets the organizers' public reply to a review, replacing any earlier one. Only the event's owner and co-hosts may reply.
+//	@Tags			reviews
+//	@Accept			json
+//	@Produce		json
+//	@Param			id			path		int					true	"Event ID"
+//	@Param			reviewId	path		int					true	"Review ID"
+//	@Param			reply		body		replyReviewRequest	true	"Reply"
+//	@Success		200			{object}	database.Review
+//	@Router			/api/v1/events/{id}/reviews/{reviewId}/reply [put]
+//	@Security		BearerAuth
+func (app *application) replyToReview(c *gin.Context) {
+	event, ok := app.managedEvent(c, database.PermissionEdit)
+	if !ok {
+		return
+	}
+	id, err := strconv.Atoi(c.Param("reviewId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid review ID"})
+		return
+	}
+	var request replyReviewRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	reply := strings.TrimSpace(request.Reply)
+	if reply == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Reply cannot be empty"})
+		return
+	}
+
+	review, err := app.Model.Reviews.Get(event.ID, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve review"})
+		return
+	}
+	if review == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Review not found"})
+		return
+	}
+	if err := app.Model.Reviews.SetReply(app.actor(c), review, reply); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save reply"})
+		return
+	}
+	c.JSON(http.StatusOK, review)
+}
+
+// GetUserProfile returns a user's public profile
+//
+//	@Summary		Returns a user's public profile
+//	@Description	Returns a user's name and, once events they own have been reviewed, their reputation as an organizer. The score is the average rating weighted towards 3 stars while there are few reviews.
+//	@Tags			users
+//	@Produce		json
+//	@Param			id	path		int	true	"User ID"
+//	@Success		200	{object}	database.Profile
+//	@Router			/api/v1/users/{id} [get]
+func (app *application) getUserProfile(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+	profile, err := app.Model.Reviews.GetProfile(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve user"})
+		return
+	}
+	if profile == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	c.JSON(http.StatusOK, profile)
+}
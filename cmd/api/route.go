@@ -9,26 +9,109 @@ import (
 )
 
 func (app *application) routes() http.Handler {
+	registerValidators()
+
 	g := gin.Default()
+	g.Use(app.requestIDMiddleware())
 	v1 := g.Group("/api/v1")
 	{
-		v1.GET("/events", app.getAllEvents)
-		v1.GET("/events/:id", app.getEventByID)
-		v1.GET("/events/:id/attendees", app.getAttendeesForEvent)
-		v1.GET("/attendees/:id/events", app.getEventsByAttendee)
-
 		v1.POST("/auth/register", app.registerUser)
 		v1.POST("/auth/login", app.loginUser)
+		v1.POST("/payments/webhook", app.paymentWebhook)
+		v1.GET("/live", bearerFromSubprotocol(), app.authMiddleware(), app.liveDashboard)
+	}
+
+	publicGroup := v1.Group("/")
+	publicGroup.Use(app.optionalAuthMiddleware())
+	{
+		publicGroup.GET("/events", app.getAllEvents)
+		publicGroup.GET("/events/nearby", app.getNearbyEvents)
+		publicGroup.GET("/events/:id", app.getEventByID)
+		publicGroup.GET("/events/:id/attendees", app.getAttendeesForEvent)
+		publicGroup.GET("/attendees/:id/events", app.getEventsByAttendee)
+		publicGroup.GET("/events/:id/ticket-types", app.getTicketTypes)
+		publicGroup.GET("/events/:id/form", app.getEventForm)
+		publicGroup.GET("/events/:id/comments", app.getComments)
+		publicGroup.GET("/events/:id/reviews", app.getReviews)
+		publicGroup.GET("/users/:id", app.getUserProfile)
+		publicGroup.GET("/events/:id/stream", app.getEventStream)
+		publicGroup.GET("/stream", app.getStream)
+		publicGroup.GET("/tags", app.getTags)
 	}
 
 	authGroup := v1.Group("/")
 	authGroup.Use(app.authMiddleware())
 	{
 		authGroup.PUT("/events/:id", app.updateEvent)
+		authGroup.PATCH("/events/:id", app.patchEvent)
 		authGroup.POST("/events", app.createEvent)
 		authGroup.DELETE("/events/:id", app.deleteEvent)
 		authGroup.POST("/events/:id/attendees/:userId", app.addAttendeeToEvent)
 		authGroup.DELETE("/events/:id/attendees/:userId", app.deleteAttendeeFromEvent)
+		authGroup.GET("/events/:id/history", app.getEventHistory)
+		authGroup.POST("/events/:id/restore", app.restoreEvent)
+		authGroup.PUT("/events/:id/occurrences/:date", app.updateOccurrence)
+		authGroup.DELETE("/events/:id/occurrences/:date", app.deleteOccurrence)
+		authGroup.PUT("/events/:id/tags", app.setEventTags)
+		authGroup.POST("/events/:id/publish", app.publishEvent)
+		authGroup.POST("/events/:id/cancel", app.cancelEvent)
+		authGroup.POST("/events/:id/complete", app.completeEvent)
+		authGroup.POST("/events/:id/rsvp", app.rsvpToEvent)
+		authGroup.DELETE("/events/:id/rsvp", app.cancelRSVP)
+		authGroup.GET("/events/:id/invites", app.getEventInvites)
+		authGroup.POST("/events/:id/invites", app.createEventInvite)
+		authGroup.DELETE("/events/:id/invites/:inviteId", app.revokeEventInvite)
+		authGroup.GET("/events/:id/organizers", app.getEventOrganizers)
+		authGroup.PUT("/events/:id/organizers/:userId", app.setEventOrganizer)
+		authGroup.DELETE("/events/:id/organizers/:userId", app.removeEventOrganizer)
+		authGroup.POST("/events/:id/transfer", app.transferEventOwnership)
+		authGroup.GET("/events/:id/checkin", app.getCheckInCounts)
+		authGroup.GET("/events/:id/announcements", app.getAnnouncements)
+		authGroup.POST("/events/:id/comments", app.createComment)
+		authGroup.GET("/events/:id/comments/reports", app.getCommentReports)
+		authGroup.PATCH("/events/:id/comments/:commentId", app.updateComment)
+		authGroup.DELETE("/events/:id/comments/:commentId", app.deleteComment)
+		authGroup.POST("/events/:id/comments/:commentId/reports", app.reportComment)
+		authGroup.DELETE("/events/:id/comments/:commentId/reports", app.dismissCommentReports)
+		authGroup.POST("/events/:id/announcements", app.createAnnouncement)
+		authGroup.POST("/events/:id/reviews", app.createReview)
+		authGroup.PUT("/events/:id/reviews/:reviewId/reply", app.replyToReview)
+		authGroup.POST("/events/:id/checkin", app.checkInAttendee)
+		authGroup.GET("/me/tickets/:id/qr.png", app.getTicketQR)
+		authGroup.POST("/events/:id/ticket-types", app.createTicketType)
+		authGroup.DELETE("/events/:id/ticket-types/:ticketTypeId", app.deleteTicketType)
+		authGroup.GET("/events/:id/promo-codes", app.getPromoCodes)
+		authGroup.POST("/events/:id/promo-codes", app.createPromoCode)
+		authGroup.DELETE("/events/:id/promo-codes/:promoCodeId", app.deletePromoCode)
+		authGroup.POST("/events/:id/checkout", app.checkout)
+		authGroup.POST("/events/:id/waitlist", app.joinWaitlist)
+		authGroup.DELETE("/events/:id/waitlist/:entryId", app.leaveWaitlist)
+		authGroup.GET("/me/orders/:id", app.getOrder)
+		authGroup.PUT("/events/:id/form", app.setEventForm)
+		authGroup.GET("/me/notifications", app.getNotifications)
+		authGroup.GET("/me/notifications/unread-count", app.getUnreadNotificationCount)
+		authGroup.POST("/me/notifications/read-all", app.markAllNotificationsRead)
+		authGroup.POST("/me/notifications/:id/read", app.markNotificationRead)
+		authGroup.GET("/me/notification-preferences", app.getNotificationPreferences)
+		authGroup.PATCH("/me/notification-preferences", app.setNotificationPreferences)
+		authGroup.GET("/me/webhooks", app.getWebhooks)
+		authGroup.POST("/me/webhooks", app.createWebhook)
+		authGroup.DELETE("/me/webhooks/:id", app.deleteWebhook)
+		authGroup.GET("/me/webhooks/:id/deliveries", app.getWebhookDeliveries)
+		authGroup.GET("/me/webhooks/:id/deliveries/:deliveryId/attempts", app.getWebhookDeliveryAttempts)
+		authGroup.POST("/me/webhooks/:id/deliveries/:deliveryId/redeliver", app.redeliverWebhook)
+	}
+
+	adminGroup := authGroup.Group("/admin")
+	adminGroup.Use(app.adminMiddleware())
+	{
+		adminGroup.GET("/backup", app.downloadBackup)
+		adminGroup.GET("/audit", app.getAuditLog)
+		adminGroup.GET("/jobs", app.getJobs)
+		adminGroup.GET("/jobs/:id", app.getJob)
+		adminGroup.POST("/jobs/:id/retry", app.retryJob)
+		adminGroup.DELETE("/users/:id", app.deleteUser)
+		adminGroup.POST("/users/:id/restore", app.restoreUser)
 	}
 
 	g.GET("/swagger/*any", func(c *gin.Context) {
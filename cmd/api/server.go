@@ -1,13 +1,19 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"time"
 )
 
-func (app *application) server() error {
+const shutdownTimeout = 10 * time.Second
+
+// server serves the API until ctx is cancelled, then waits up to
+// shutdownTimeout for in-flight requests to finish.
+func (app *application) server(ctx context.Context) error {
 	server := &http.Server{
 		Addr:         fmt.Sprintf(":%d", app.Port),
 		Handler:      app.routes(),
@@ -15,7 +21,22 @@ func (app *application) server() error {
 		ReadTimeout:  10 * time.Second,
 		WriteTimeout: 30 * time.Second,
 	}
+	// Live streams never go idle on their own, so end them before waiting
+	// for connections to drain.
+	server.RegisterOnShutdown(app.Stream.Close)
+
+	shutdownErr := make(chan error, 1)
+	go func() {
+		<-ctx.Done()
+		log.Println("Shutting down server")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		shutdownErr <- server.Shutdown(shutdownCtx)
+	}()
 
 	log.Printf("Starting server on %s", server.Addr)
-	return server.ListenAndServe()
+	if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return <-shutdownErr
 }
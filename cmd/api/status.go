@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Yiheyistm/go-restful-api/internal/database"
+	"github.com/Yiheyistm/go-restful-api/internal/notify"
+	"github.com/gin-gonic/gin"
+)
+
+type cancelEventRequest struct {
+	Reason string `json:"reason" binding:"max=500"`
+}
+
+// eventEditable answers 409 itself when event can no longer be changed.
+func eventEditable(c *gin.Context, event *database.Event) bool {
+	if event.Status == database.EventStatusCancelled || event.Status == database.EventStatusCompleted {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("A %s event cannot be changed", event.Status)})
+		return false
+	}
+	return true
+}
+
+// PublishEvent publishes a draft event
+//
+//	@Summary		Publishes a draft event
+//	@Description	Moves a draft to published, making it visible to everyone and open for attendees
+//	@Tags			events
+//	@Produce		json
+//	@Param			id			path		int		true	"Event ID"
+//	@Param			If-Match	header		string	false	"ETag the transition is based on"
+//	@Success		200			{object}	database.Event
+//	@Failure		409
+//	@Router			/api/v1/events/{id}/publish [post]
+//	@Security		BearerAuth
+func (app *application) publishEvent(c *gin.Context) {
+	app.transitionEvent(c, database.EventStatusPublished)
+}
+
+// CancelEvent cancels a published event
+//
+//	@Summary		Cancels a published event
+//	@Description	Moves a published event to cancelled and notifies its attendees. The event and its attendees are kept.
+//	@Tags			events
+//	@Accept			json
+//	@Produce		json
+//	@Param			id			path		int					true	"Event ID"
+//	@Param			If-Match	header		string				false	"ETag the transition is based on"
+//	@Param			reason		body		cancelEventRequest	false	"Reason passed on to attendees"
+//	@Success		200			{object}	database.Event
+//	@Failure		409
+//	@Router			/api/v1/events/{id}/cancel [post]
+//	@Security		BearerAuth
+func (app *application) cancelEvent(c *gin.Context) {
+	app.transitionEvent(c, database.EventStatusCancelled)
+}
+
+// CompleteEvent marks a published event as completed
+//
+//	@Summary		Marks a published event as completed
+//	@Description	Moves a published event that has started to completed
+//	@Tags			events
+//	@Produce		json
+//	@Param			id			path		int		true	"Event ID"
+//	@Param			If-Match	header		string	false	"ETag the transition is based on"
+//	@Success		200			{object}	database.Event
+//	@Failure		409
+//	@Router			/api/v1/events/{id}/complete [post]
+//	@Security		BearerAuth
+func (app *application) completeEvent(c *gin.Context) {
+	app.transitionEvent(c, database.EventStatusCompleted)
+}
+
+func (app *application) transitionEvent(c *gin.Context, status string) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		return
+	}
+	existedEvent, err := app.Model.Events.GetByID(id)
+	if err != nil || !app.canViewEvent(c, existedEvent) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+	if !app.canManageEvent(c, existedEvent, database.PermissionEdit) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You do not have permission to update this event"})
+		return
+	}
+	if !checkIfMatch(c, eventETag(existedEvent)) {
+		return
+	}
+
+	var request cancelEventRequest
+	if status == database.EventStatusCancelled && c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	if !database.CanTransitionEvent(existedEvent.Status, status) {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("A %s event cannot be moved to %s", existedEvent.Status, status)})
+		return
+	}
+	if status == database.EventStatusCompleted && existedEvent.Date > time.Now().Format(time.DateOnly) {
+		c.JSON(http.StatusConflict, gin.H{"error": "An event cannot be completed before it starts"})
+		return
+	}
+
+	event := *existedEvent
+	if err := app.Model.Events.SetStatus(app.actor(c), &event, status); err != nil {
+		if errors.Is(err, database.ErrEditConflict) {
+			c.JSON(http.StatusPreconditionFailed, gin.H{"error": "Event has been modified since it was retrieved"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update event"})
+		return
+	}
+
+	if status == database.EventStatusCancelled {
+		err := app.enqueueJob(jobNotifyCancellation, cancellationJob{EventID: event.ID, Reason: request.Reason})
+		if err != nil {
+			log.Printf("Failed to queue cancellation notices for event %d: %v", event.ID, err)
+		}
+	}
+	c.Header("ETag", eventETag(&event))
+	c.JSON(http.StatusOK, event)
+}
+
+// cancellationJob is the payload of a jobNotifyCancellation job.
+type cancellationJob struct {
+	EventID int    `json:"event_id"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+// notifyCancellation is the jobNotifyCancellation handler. It tells
+// everyone attending any occurrence of the cancelled event in their inbox
+// and queues an email, each retried on its own, to those who want one.
+func (app *application) notifyCancellation(ctx context.Context, job *database.Job) error {
+	var payload cancellationJob
+	if err := json.Unmarshal(job.Payload, &payload); err != nil {
+		return err
+	}
+	event, err := app.Model.Events.GetByID(payload.EventID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	body := fmt.Sprintf("%s on %s at %s has been cancelled.", event.Name, event.Date, event.Location)
+	if payload.Reason != "" {
+		body += "\n\nReason: " + payload.Reason
+	}
+	err = app.Model.Notifications.InsertForAttendees(&database.Notification{
+		Type:    database.NotificationEventCancelled,
+		EventID: &event.ID,
+		Title:   fmt.Sprintf("Cancelled: %s", event.Name),
+		Body:    body,
+		Key:     fmt.Sprintf("%s:%d", database.NotificationEventCancelled, event.ID),
+	})
+	if err != nil {
+		return err
+	}
+
+	recipients, err := app.Model.Events.CancellationRecipients(event.ID)
+	if err != nil {
+		return err
+	}
+	jobs := make([]*database.Job, 0, len(recipients))
+	for _, recipient := range recipients {
+		job, err := database.NewJob(jobNotify, notify.Message{
+			UserID:  recipient.UserID,
+			Email:   recipient.Email,
+			EventID: event.ID,
+			Subject: fmt.Sprintf("Cancelled: %s", event.Name),
+			Body:    body,
+		})
+		if err != nil {
+			return err
+		}
+		jobs = append(jobs, job)
+	}
+	return app.Model.Events.QueueCancellationEmails(event.ID, jobs)
+}
+
+// sendNotification is the jobNotify handler. It delivers one message.
+func (app *application) sendNotification(ctx context.Context, job *database.Job) error {
+	var msg notify.Message
+	if err := json.Unmarshal(job.Payload, &msg); err != nil {
+		return err
+	}
+	return app.Notifier.Notify(ctx, msg)
+}
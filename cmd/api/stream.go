@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Yiheyistm/go-restful-api/internal/database"
+	"github.com/Yiheyistm/go-restful-api/internal/stream"
+	"github.com/gin-gonic/gin"
+)
+
+// streamHeartbeat is how often an idle stream sends a comment, so proxies
+// and clients can tell it is still alive.
+const streamHeartbeat = 15 * time.Second
+
+// broadcastChange is the outbox subscriber that hands event and attendee
+// changes to the live streams.
+func (app *application) broadcastChange(ctx context.Context, event *database.DomainEvent) error {
+	if event.AggregateType != database.AggregateEvent {
+		return nil
+	}
+	msg := stream.Message{ID: event.ID, Type: event.Type}
+	switch event.Type {
+	case database.DomainEventCreated, database.DomainEventUpdated, database.DomainEventDeleted:
+		msg.Event = &database.Event{}
+		if err := json.Unmarshal(event.Payload, msg.Event); err != nil {
+			return err
+		}
+	case database.DomainAttendeeAdded, database.DomainAttendeeRemoved, database.DomainAttendeeCheckedIn, database.DomainWaitlistPromoted:
+		var data any = &msg.Attendee
+		if event.Type == database.DomainWaitlistPromoted {
+			data = &msg.Promotion
+		}
+		if err := json.Unmarshal(event.Payload, data); err != nil {
+			return err
+		}
+		current, err := app.Model.Events.GetByID(event.AggregateID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		msg.Event = current
+		if msg.Counts, err = app.Model.Attendees.CountCheckIns(current.ID, ""); err != nil {
+			return err
+		}
+	default:
+		return nil
+	}
+	app.Stream.Publish(msg)
+	return nil
+}
+
+// canListEvent reports whether the caller may see event in the global
+// stream, which follows the listings: unlisted and private events only
+// show up for their organizers and attendees.
+func (app *application) canListEvent(c *gin.Context, event *database.Event) bool {
+	if app.eventRole(c, event) != "" {
+		return true
+	}
+	if event.Status == database.EventStatusDraft {
+		return false
+	}
+	if event.Visibility == database.EventVisibilityPublic {
+		return true
+	}
+	user := app.GetUserFromContext(c)
+	if user.ID == 0 {
+		return false
+	}
+	attending, err := app.Model.Attendees.IsAttending(event.ID, user.ID)
+	if err != nil {
+		log.Printf("Failed to check attendance for event %d: %v", event.ID, err)
+	}
+	return attending
+}
+
+// GetEventStream streams live changes to an event
+//
+//	@Summary		Streams live changes to an event
+//	@Description	Pushes a text/event-stream message whenever the event changes or an attendee is added or removed. Each message has the change's ID, its type (event.created, event.updated, event.deleted, attendee.added, attendee.removed, attendee.checked_in) and the event or attendee as JSON data. Reconnect with Last-Event-ID to replay what was missed; a "reset" message means the gap was too large and the client should reload. Idle streams get a comment every 15 seconds.
+//	@Tags			events
+//	@Produce		text/event-stream
+//	@Param			id				path	int		true	"Event ID"
+//	@Param			invite			query	string	false	"Invite token for a private event"
+//	@Param			Last-Event-ID	header	string	false	"ID of the last message received"
+//	@Success		200
+//	@Router			/api/v1/events/{id}/stream [get]
+func (app *application) getEventStream(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		return
+	}
+	event, err := app.Model.Events.GetByID(id)
+	if err != nil || !app.canViewEvent(c, event) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+	app.serveStream(c, func(msg stream.Message) bool {
+		return msg.Event.ID == id && app.canViewEvent(c, msg.Event)
+	})
+}
+
+// GetStream streams live changes to every visible event
+//
+//	@Summary		Streams live changes to every visible event
+//	@Description	Like the event stream, for every event the caller would see in the listings: public events, and the unlisted, private and draft events they organize or attend.
+//	@Tags			events
+//	@Produce		text/event-stream
+//	@Param			Last-Event-ID	header	string	false	"ID of the last message received"
+//	@Success		200
+//	@Router			/api/v1/stream [get]
+func (app *application) getStream(c *gin.Context) {
+	app.serveStream(c, func(msg stream.Message) bool {
+		return app.canListEvent(c, msg.Event)
+	})
+}
+
+// serveStream writes the messages visible passes as Server-Sent Events
+// until the client goes away or the server shuts down.
+func (app *application) serveStream(c *gin.Context, visible func(stream.Message) bool) {
+	lastID, _ := strconv.Atoi(c.GetHeader("Last-Event-ID"))
+	if lastID == 0 {
+		lastID, _ = strconv.Atoi(c.Query("last_event_id"))
+	}
+	sub, replay, complete := app.Stream.Subscribe(lastID)
+	defer app.Stream.Unsubscribe(sub)
+
+	// Streams outlive the server's WriteTimeout.
+	if err := http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{}); err != nil {
+		log.Println("Stream deadline error:", err)
+	}
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	fmt.Fprint(c.Writer, "retry: 3000\n\n")
+	if !complete {
+		fmt.Fprint(c.Writer, "event: reset\ndata: {}\n\n")
+	}
+	for _, msg := range replay {
+		if msg.Promotion == nil && visible(msg) {
+			app.writeStreamMessage(c, msg)
+		}
+	}
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case msg, ok := <-sub.C:
+			if !ok {
+				return
+			}
+			// Promotions are only for the organizers' live dashboards.
+			if msg.Promotion == nil && visible(msg) {
+				app.writeStreamMessage(c, msg)
+				c.Writer.Flush()
+			}
+		case <-heartbeat.C:
+			fmt.Fprint(c.Writer, ": ping\n\n")
+			c.Writer.Flush()
+		}
+	}
+}
+
+// writeStreamMessage writes one message. Registration answers are only
+// included for organizers who manage attendees.
+func (app *application) writeStreamMessage(c *gin.Context, msg stream.Message) {
+	var data any = msg.Event
+	if msg.Attendee != nil {
+		attendee := *msg.Attendee
+		if !app.canManageEvent(c, msg.Event, database.PermissionManageAttendees) {
+			attendee.Answers = nil
+		}
+		data = &attendee
+	}
+	payload, err := json.Marshal(data)
+	if err != nil {
+		log.Println("Stream message error:", err)
+		return
+	}
+	fmt.Fprintf(c.Writer, "id: %d\nevent: %s\ndata: %s\n\n", msg.ID, msg.Type, payload)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Yiheyistm/go-restful-api/internal/database"
+	"github.com/gin-gonic/gin"
+)
+
+type setEventTagsRequest struct {
+	Tags []string `json:"tags" binding:"required"`
+}
+
+// GetTags returns every tag with its usage count
+//
+//	@Summary		Returns every tag with its usage count
+//	@Description	Returns the tags used by live events, most used first, for building facet filters
+//	@Tags			tags
+//	@Produce		json
+//	@Success		200	{array}	database.TagCount
+//	@Router			/api/v1/tags [get]
+func (app *application) getTags(c *gin.Context) {
+	tags, err := app.Model.Tags.GetAllWithCounts()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve tags"})
+		return
+	}
+	c.JSON(http.StatusOK, tags)
+}
+
+// SetEventTags replaces the tags of an event
+//
+//	@Summary		Replaces the tags of an event
+//	@Description	Tags are lowercased and slugified; an event can carry at most 10
+//	@Tags			tags
+//	@Accept			json
+//	@Produce		json
+//	@Param			id			path		int					true	"Event ID"
+//	@Param			If-Match	header		string				false	"ETag the change is based on"
+//	@Param			tags		body		setEventTagsRequest	true	"Tags"
+//	@Success		200			{object}	database.Event
+//	@Failure		412
+//	@Router			/api/v1/events/{id}/tags [put]
+//	@Security		BearerAuth
+func (app *application) setEventTags(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		return
+	}
+	existedEvent, err := app.Model.Events.GetByID(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+	if !app.canManageEvent(c, existedEvent, database.PermissionEdit) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You do not have permission to update this event"})
+		return
+	}
+	if !eventEditable(c, existedEvent) || !checkIfMatch(c, eventETag(existedEvent)) {
+		return
+	}
+
+	var request setEventTagsRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	tags, err := database.NormalizeTags(request.Tags)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := app.Model.Tags.SetForEvent(app.actor(c), existedEvent, tags); err != nil {
+		if errors.Is(err, database.ErrEditConflict) {
+			c.JSON(http.StatusPreconditionFailed, gin.H{"error": "Event has been modified since it was retrieved"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update tags"})
+		return
+	}
+	c.Header("ETag", eventETag(existedEvent))
+	c.JSON(http.StatusOK, existedEvent)
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Yiheyistm/go-restful-api/internal/database"
+	"github.com/gin-gonic/gin"
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+const ticketQRSize = 256
+
+type checkInRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// ticketSignature binds a ticket to one attendee row of one event, so a
+// code cannot be replayed at another event or after re-RSVPing.
+func (app *application) ticketSignature(attendeeID, eventID, userID int) []byte {
+	mac := hmac.New(sha256.New, []byte(app.JwtSecret))
+	fmt.Fprintf(mac, "ticket:%d:%d:%d", attendeeID, eventID, userID)
+	return mac.Sum(nil)[:16]
+}
+
+// ticketCode returns the code encoded in an attendee's QR ticket: the
+// attendee ID and a truncated HMAC-SHA256 of the row.
+func (app *application) ticketCode(attendee *database.Attendee) string {
+	signature := app.ticketSignature(attendee.ID, attendee.EventID, attendee.UserID)
+	return fmt.Sprintf("%d.%s", attendee.ID, base64.RawURLEncoding.EncodeToString(signature))
+}
+
+// attendeeFromTicket looks up the attendee a ticket code was issued for.
+// It returns nil when the code is malformed, tampered with or names an
+// attendee that no longer exists.
+func (app *application) attendeeFromTicket(code string) (*database.Attendee, error) {
+	idPart, signaturePart, ok := strings.Cut(strings.TrimSpace(code), ".")
+	if !ok {
+		return nil, nil
+	}
+	id, err := strconv.Atoi(idPart)
+	if err != nil {
+		return nil, nil
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(signaturePart)
+	if err != nil {
+		return nil, nil
+	}
+	attendee, err := app.Model.Attendees.Get(id)
+	if err != nil || attendee == nil {
+		return nil, err
+	}
+	if !hmac.Equal(signature, app.ticketSignature(attendee.ID, attendee.EventID, attendee.UserID)) {
+		return nil, nil
+	}
+	return attendee, nil
+}
+
+// GetTicketQR renders the caller's ticket as a QR code
+//
+//	@Summary		Renders the caller's ticket as a QR code
+//	@Description	Returns a PNG QR code of the signed ticket for one of the caller's attendances, to be scanned at check-in
+//	@Tags			tickets
+//	@Produce		png
+//	@Param			id	path	int	true	"Attendee ID"
+//	@Success		200	{file}	binary
+//	@Router			/api/v1/me/tickets/{id}/qr.png [get]
+//	@Security		BearerAuth
+func (app *application) getTicketQR(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ticket ID"})
+		return
+	}
+	attendee, err := app.Model.Attendees.Get(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve ticket"})
+		return
+	}
+	if attendee == nil || attendee.UserID != app.GetUserFromContext(c).ID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Ticket not found"})
+		return
+	}
+	png, err := qrcode.Encode(app.ticketCode(attendee), qrcode.Medium, ticketQRSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render ticket"})
+		return
+	}
+	c.Header("Cache-Control", "private, no-store")
+	c.Data(http.StatusOK, "image/png", png)
+}
+
+// CheckInAttendee checks an attendee in by their ticket code
+//
+//	@Summary		Checks an attendee in by their ticket code
+//	@Description	Verifies a scanned ticket code and marks its attendee as checked in. Any organizer of the event, including check-in staff, may scan. The response carries the event's check-in counts.
+//	@Tags			tickets
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int				true	"Event ID"
+//	@Param			ticket	body		checkInRequest	true	"Scanned ticket code"
+//	@Success		200		{object}	database.Attendee
+//	@Failure		409
+//	@Failure		422
+//	@Router			/api/v1/events/{id}/checkin [post]
+//	@Security		BearerAuth
+func (app *application) checkInAttendee(c *gin.Context) {
+	event, ok := app.managedEvent(c, database.PermissionCheckIn)
+	if !ok {
+		return
+	}
+	if event.Status != database.EventStatusPublished {
+		c.JSON(http.StatusConflict, gin.H{"error": "Only published events take check-ins"})
+		return
+	}
+	var request checkInRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	attendee, err := app.attendeeFromTicket(request.Code)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify ticket"})
+		return
+	}
+	if attendee == nil || attendee.EventID != event.ID {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Invalid ticket"})
+		return
+	}
+	if attendee.CheckedInAt != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Ticket has already been checked in", "attendee": attendee})
+		return
+	}
+
+	if err := app.Model.Attendees.CheckIn(app.actor(c), attendee); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Ticket has already been checked in"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check in"})
+		return
+	}
+
+	counts, err := app.Model.Attendees.CountCheckIns(event.ID, attendee.OccurrenceDate)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count check-ins"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"attendee": attendee, "counts": counts})
+}
+
+// GetCheckInCounts reports how many attendees have checked in
+//
+//	@Summary		Reports how many attendees have checked in
+//	@Description	Returns the live check-in and attendee counts of an event. Only its organizers may read them.
+//	@Tags			tickets
+//	@Produce		json
+//	@Param			id			path		int		true	"Event ID"
+//	@Param			occurrence	query		string	false	"Only this occurrence (YYYY-MM-DD)"
+//	@Success		200			{object}	database.CheckInCounts
+//	@Router			/api/v1/events/{id}/checkin [get]
+//	@Security		BearerAuth
+func (app *application) getCheckInCounts(c *gin.Context) {
+	event, ok := app.managedEvent(c, database.PermissionCheckIn)
+	if !ok {
+		return
+	}
+	counts, err := app.Model.Attendees.CountCheckIns(event.ID, c.Query("occurrence"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count check-ins"})
+		return
+	}
+	c.JSON(http.StatusOK, counts)
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/Yiheyistm/go-restful-api/internal/database"
+)
+
+// insertRace inserts a published race of owner with one runner signed up,
+// returning the race and the runner's attendance.
+func (app *application) insertRace(t *testing.T, owner *database.User, runner string) (*database.Event, *database.Attendee) {
+	t.Helper()
+	event := &database.Event{
+		OwnerId:     owner.ID,
+		Name:        "City half marathon",
+		Description: "Twenty-one kilometres through the old town",
+		Date:        "2026-11-29",
+		Location:    "Harar",
+		Status:      database.EventStatusPublished,
+	}
+	if err := app.Model.Events.Insert(database.Actor{}, event); err != nil {
+		t.Fatal(err)
+	}
+	user, _ := app.signUp(t, runner)
+	attendee := &database.Attendee{EventID: event.ID, UserID: user.ID}
+	if err := app.Model.Attendees.Insert(database.Actor{}, attendee); err != nil {
+		t.Fatal(err)
+	}
+	return event, attendee
+}
+
+func TestTicketCode(t *testing.T) {
+	app := newTestApp(t)
+	owner, _ := app.signUp(t, "organizer")
+	_, attendee := app.insertRace(t, owner, "runner")
+	_, other := app.insertRace(t, owner, "sprinter")
+
+	code := app.ticketCode(attendee)
+	found, err := app.attendeeFromTicket(code)
+	if err != nil || found == nil || found.ID != attendee.ID {
+		t.Fatalf("attendeeFromTicket(%q) = %+v, %v; want attendee %d", code, found, err, attendee.ID)
+	}
+	if found, err := app.attendeeFromTicket("  " + code + "\n"); err != nil || found == nil {
+		t.Errorf("attendeeFromTicket with surrounding space = %+v, %v; want the attendee", found, err)
+	}
+
+	_, signature, _ := strings.Cut(code, ".")
+	flipped := "A"
+	if signature[0] == 'A' {
+		flipped = "B"
+	}
+	tampered := flipped + signature[1:]
+	otherApp := *app
+	otherApp.JwtSecret = "another_secret"
+	tests := []struct {
+		name string
+		app  *application
+		code string
+	}{
+		{"tampered signature", app, fmt.Sprintf("%d.%s", attendee.ID, tampered)},
+		{"signature of another attendee", app, fmt.Sprintf("%d.%s", other.ID, signature)},
+		{"signed with another secret", &otherApp, code},
+		{"no signature", app, fmt.Sprint(attendee.ID)},
+		{"no ID", app, "." + signature},
+		{"not base64", app, fmt.Sprintf("%d.%s", attendee.ID, "!!!")},
+		{"unknown attendee", app, app.ticketCode(&database.Attendee{ID: 999, EventID: attendee.EventID, UserID: attendee.UserID})},
+		{"empty", app, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if found, err := tt.app.attendeeFromTicket(tt.code); err != nil || found != nil {
+				t.Errorf("attendeeFromTicket(%q) = %+v, %v; want nil", tt.code, found, err)
+			}
+		})
+	}
+}
+
+func TestCheckInAttendee(t *testing.T) {
+	app := newTestApp(t)
+	handler := app.routes()
+	owner, _ := app.signUp(t, "organizer")
+	steward, stewardToken := app.signUp(t, "steward")
+	_, strangerToken := app.signUp(t, "stranger")
+	event, attendee := app.insertRace(t, owner, "runner")
+	_, elsewhere := app.insertRace(t, owner, "sprinter")
+	if err := app.Model.Organizers.Set(database.Actor{}, event.ID, steward.ID, database.OrganizerRoleCheckIn); err != nil {
+		t.Fatal(err)
+	}
+
+	checkIn := func(token, code string) (int, string) {
+		t.Helper()
+		w := serve(t, handler, http.MethodPost, fmt.Sprintf("/api/v1/events/%d/checkin", event.ID), token, fmt.Sprintf(`{"code": %q}`, code))
+		return w.Code, w.Body.String()
+	}
+
+	if code, _ := checkIn(strangerToken, app.ticketCode(attendee)); code != http.StatusForbidden {
+		t.Errorf("check-in by a stranger = %d, want 403", code)
+	}
+	if code, _ := checkIn(stewardToken, app.ticketCode(elsewhere)); code != http.StatusUnprocessableEntity {
+		t.Errorf("check-in with a ticket for another event = %d, want 422", code)
+	}
+
+	code, body := checkIn(stewardToken, app.ticketCode(attendee))
+	if code != http.StatusOK {
+		t.Fatalf("check-in = %d: %s", code, body)
+	}
+	var response struct {
+		Attendee database.Attendee      `json:"attendee"`
+		Counts   database.CheckInCounts `json:"counts"`
+	}
+	if err := json.Unmarshal([]byte(body), &response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Attendee.CheckedInAt == nil || response.Counts != (database.CheckInCounts{CheckedIn: 1, Total: 1}) {
+		t.Errorf("check-in response = %+v", response)
+	}
+
+	if code, body := checkIn(stewardToken, app.ticketCode(attendee)); code != http.StatusConflict {
+		t.Errorf("second check-in = %d: %s; want 409", code, body)
+	}
+	// Two scanners racing past the handler's check still check in once.
+	if err := app.Model.Attendees.CheckIn(database.Actor{}, attendee); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("CheckIn of a checked-in attendee = %v, want sql.ErrNoRows", err)
+	}
+	counts, err := app.Model.Attendees.CountCheckIns(event.ID, "")
+	if err != nil || counts.CheckedIn != 1 {
+		t.Errorf("CountCheckIns after a duplicate scan = %+v, %v; want 1 checked in", counts, err)
+	}
+}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"github.com/Yiheyistm/go-restful-api/internal/database"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// registerValidators adds the custom binding tags used by the request
+// and model structs.
+func registerValidators() {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return
+	}
+	v.RegisterValidation("rrule", func(fl validator.FieldLevel) bool {
+		_, err := database.ParseRRule(fl.Field().String())
+		return err == nil
+	})
+}
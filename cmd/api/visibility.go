@@ -0,0 +1,271 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Yiheyistm/go-restful-api/internal/database"
+	"github.com/gin-gonic/gin"
+)
+
+const defaultInviteLifetimeHours = 7 * 24
+
+type createInviteRequest struct {
+	ExpiresInHours int `json:"expires_in_hours" binding:"omitempty,min=1,max=2160"`
+}
+
+// canViewEvent reports whether the caller may see event. Organizers see
+// everything and drafts nobody else; private events are visible to their
+// attendees and to requests carrying a valid invite token as ?invite=.
+func (app *application) canViewEvent(c *gin.Context, event *database.Event) bool {
+	user := app.GetUserFromContext(c)
+	if app.eventRole(c, event) != "" {
+		return true
+	}
+	if event.Status == database.EventStatusDraft {
+		return false
+	}
+	if event.Visibility != database.EventVisibilityPrivate {
+		return true
+	}
+
+	if token := c.Query("invite"); token != "" {
+		valid, err := app.Model.Invites.IsValid(event.ID, token)
+		if err != nil {
+			log.Printf("Failed to check invite for event %d: %v", event.ID, err)
+		}
+		if valid {
+			return true
+		}
+	}
+	if user.ID != 0 {
+		attending, err := app.Model.Attendees.IsAttending(event.ID, user.ID)
+		if err != nil {
+			log.Printf("Failed to check attendance for event %d: %v", event.ID, err)
+		}
+		return attending
+	}
+	return false
+}
+
+// RSVPToEvent signs the caller up for an event
+//
+//	@Summary		Signs the caller up for an event
+//	@Description	Adds the caller as an attendee of a published, free event they can see and returns their signed ticket code. Private events need an invite token; events with ticket types go through checkout.
+//	@Tags			events
+//	@Produce		json
+//	@Param			id			path		int		true	"Event ID"
+//	@Param			occurrence	query		string	false	"Occurrence date (YYYY-MM-DD), required for recurring events"
+//	@Param			invite		query		string				false	"Invite token for a private event"
+//	@Param			answers		body		registrationRequest	false	"Answers to the event's registration form"
+//	@Success		201			{object}	database.Attendee
+//	@Failure		409
+//	@Router			/api/v1/events/{id}/rsvp [post]
+//	@Security		BearerAuth
+func (app *application) rsvpToEvent(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		return
+	}
+	user := app.GetUserFromContext(c)
+	event, err := app.Model.Events.GetByID(id)
+	if err != nil || !app.canViewEvent(c, event) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+	if event.Status != database.EventStatusPublished {
+		c.JSON(http.StatusConflict, gin.H{"error": "Only published events take RSVPs"})
+		return
+	}
+	sellsTickets, err := app.Model.TicketTypes.HasTicketTypes(event.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check ticket types"})
+		return
+	}
+	if sellsTickets {
+		c.JSON(http.StatusConflict, gin.H{"error": "This event sells tickets; order one through checkout"})
+		return
+	}
+	occurrence, ok := occurrenceParam(c, event)
+	if !ok {
+		return
+	}
+
+	existedAttendee, err := app.Model.Attendees.GetByEventAndUserId(event.ID, user.ID, occurrence)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check attendee"})
+		return
+	}
+	if existedAttendee != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "You are already attending this event"})
+		return
+	}
+	answers, ok := app.bindAnswers(c, event)
+	if !ok {
+		return
+	}
+	attendee := &database.Attendee{
+		EventID:        event.ID,
+		UserID:         user.ID,
+		OccurrenceDate: occurrence,
+		Answers:        answers,
+	}
+	if err := app.Model.Attendees.Insert(app.actor(c), attendee); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add attendee"})
+		return
+	}
+	attendee.Ticket = app.ticketCode(attendee)
+	c.JSON(http.StatusCreated, attendee)
+}
+
+// CancelRSVP withdraws the caller from an event
+//
+//	@Summary		Withdraws the caller from an event
+//	@Description	Removes the caller's own attendance
+//	@Tags			events
+//	@Param			id			path	int		true	"Event ID"
+//	@Param			occurrence	query	string	false	"Occurrence date (YYYY-MM-DD), required for recurring events"
+//	@Success		204
+//	@Router			/api/v1/events/{id}/rsvp [delete]
+//	@Security		BearerAuth
+func (app *application) cancelRSVP(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		return
+	}
+	user := app.GetUserFromContext(c)
+	event, err := app.Model.Events.GetByID(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+	occurrence, ok := occurrenceParam(c, event)
+	if !ok {
+		return
+	}
+
+	existedAttendee, err := app.Model.Attendees.GetByEventAndUserId(event.ID, user.ID, occurrence)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check attendee"})
+		return
+	}
+	if existedAttendee == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "You are not attending this event"})
+		return
+	}
+	if err := app.Model.Attendees.Delete(app.actor(c), existedAttendee.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete attendee"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// GetEventInvites lists the invites of an event
+//
+//	@Summary		Lists the invites of an event
+//	@Description	Lists every invite of an event, including expired and revoked ones. Tokens are not returned.
+//	@Tags			invites
+//	@Produce		json
+//	@Param			id	path	int	true	"Event ID"
+//	@Success		200	{array}	database.Invite
+//	@Router			/api/v1/events/{id}/invites [get]
+//	@Security		BearerAuth
+func (app *application) getEventInvites(c *gin.Context) {
+	event, ok := app.managedEvent(c, database.PermissionEdit)
+	if !ok {
+		return
+	}
+	invites, err := app.Model.Invites.GetByEvent(event.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve invites"})
+		return
+	}
+	c.JSON(http.StatusOK, invites)
+}
+
+// CreateEventInvite creates an invite link for an event
+//
+//	@Summary		Creates an invite link for an event
+//	@Description	Creates an invite whose token lets anyone holding it view the event and RSVP. The token is only returned here.
+//	@Tags			invites
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int					true	"Event ID"
+//	@Param			invite	body		createInviteRequest	false	"Lifetime in hours (default 168)"
+//	@Success		201		{object}	database.Invite
+//	@Router			/api/v1/events/{id}/invites [post]
+//	@Security		BearerAuth
+func (app *application) createEventInvite(c *gin.Context) {
+	event, ok := app.managedEvent(c, database.PermissionEdit)
+	if !ok {
+		return
+	}
+	var request createInviteRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if request.ExpiresInHours == 0 {
+		request.ExpiresInHours = defaultInviteLifetimeHours
+	}
+
+	token, err := database.NewInviteToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create invite"})
+		return
+	}
+	invite := &database.Invite{
+		EventID:   event.ID,
+		CreatedBy: app.GetUserFromContext(c).ID,
+		ExpiresAt: time.Now().UTC().Add(time.Duration(request.ExpiresInHours) * time.Hour),
+		Token:     token,
+	}
+	if err := app.Model.Invites.Insert(invite); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create invite"})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{
+		"invite": invite,
+		"url":    fmt.Sprintf("/api/v1/events/%d?invite=%s", event.ID, token),
+	})
+}
+
+// RevokeEventInvite revokes an invite
+//
+//	@Summary		Revokes an invite
+//	@Description	Revokes an invite so its token no longer grants access
+//	@Tags			invites
+//	@Param			id			path	int	true	"Event ID"
+//	@Param			inviteId	path	int	true	"Invite ID"
+//	@Success		204
+//	@Router			/api/v1/events/{id}/invites/{inviteId} [delete]
+//	@Security		BearerAuth
+func (app *application) revokeEventInvite(c *gin.Context) {
+	event, ok := app.managedEvent(c, database.PermissionEdit)
+	if !ok {
+		return
+	}
+	inviteID, err := strconv.Atoi(c.Param("inviteId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid invite ID"})
+		return
+	}
+	if err := app.Model.Invites.Revoke(event.ID, inviteID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Invite not found or already revoked"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke invite"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
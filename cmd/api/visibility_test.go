@@ -0,0 +1,146 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Yiheyistm/go-restful-api/internal/database"
+)
+
+// insertGala inserts a published event of owner with visibility; status
+// overrides published when given.
+func (app *application) insertGala(t *testing.T, owner *database.User, visibility, status string) *database.Event {
+	t.Helper()
+	if status == "" {
+		status = database.EventStatusPublished
+	}
+	event := &database.Event{
+		OwnerId:     owner.ID,
+		Name:        "Charity gala",
+		Description: "Dinner and an auction for the library fund",
+		Date:        "2026-12-12",
+		Location:    "Sheraton Addis",
+		Status:      status,
+		Visibility:  visibility,
+	}
+	if err := app.Model.Events.Insert(database.Actor{}, event); err != nil {
+		t.Fatal(err)
+	}
+	return event
+}
+
+func TestCanViewEvent(t *testing.T) {
+	app := newTestApp(t)
+	handler := app.routes()
+
+	owner, ownerToken := app.signUp(t, "host")
+	cohost, cohostToken := app.signUp(t, "cohost")
+	guest, guestToken := app.signUp(t, "guest")
+	_, strangerToken := app.signUp(t, "stranger")
+
+	private := app.insertGala(t, owner, database.EventVisibilityPrivate, "")
+	unlisted := app.insertGala(t, owner, database.EventVisibilityUnlisted, "")
+	draft := app.insertGala(t, owner, database.EventVisibilityPublic, database.EventStatusDraft)
+	for _, event := range []*database.Event{private, draft} {
+		if err := app.Model.Organizers.Set(database.Actor{}, event.ID, cohost.ID, database.OrganizerRoleCoHost); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := app.Model.Attendees.Insert(database.Actor{}, &database.Attendee{EventID: private.ID, UserID: guest.ID}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		event *database.Event
+		token string
+		want  int
+	}{
+		{"private to its owner", private, ownerToken, http.StatusOK},
+		{"private to a co-host", private, cohostToken, http.StatusOK},
+		{"private to an attendee", private, guestToken, http.StatusOK},
+		{"private to a stranger", private, strangerToken, http.StatusNotFound},
+		{"private anonymously", private, "", http.StatusNotFound},
+		{"unlisted anonymously", unlisted, "", http.StatusOK},
+		{"draft to a co-host", draft, cohostToken, http.StatusOK},
+		{"draft to a stranger", draft, strangerToken, http.StatusNotFound},
+		{"draft anonymously", draft, "", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(t, handler, http.MethodGet, fmt.Sprintf("/api/v1/events/%d", tt.event.ID), tt.token, "")
+			if w.Code != tt.want {
+				t.Errorf("GET = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestInviteTokens(t *testing.T) {
+	app := newTestApp(t)
+	handler := app.routes()
+
+	owner, ownerToken := app.signUp(t, "host")
+	_, strangerToken := app.signUp(t, "stranger")
+	private := app.insertGala(t, owner, database.EventVisibilityPrivate, "")
+	other := app.insertGala(t, owner, database.EventVisibilityPrivate, "")
+
+	w := serve(t, handler, http.MethodPost, fmt.Sprintf("/api/v1/events/%d/invites", private.ID), ownerToken, `{"expires_in_hours": 2}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create invite = %d: %s", w.Code, w.Body)
+	}
+	var created struct {
+		Invite database.Invite `json:"invite"`
+		URL    string          `json:"url"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+		t.Fatal(err)
+	}
+	token := created.Invite.Token
+	if token == "" || created.URL != fmt.Sprintf("/api/v1/events/%d?invite=%s", private.ID, token) {
+		t.Fatalf("created invite = %+v", created)
+	}
+
+	var stored int
+	err := app.Model.Invites.DB.QueryRow(`SELECT COUNT(*) FROM event_invites WHERE token_hash = ?`, token).Scan(&stored)
+	if err != nil || stored != 0 {
+		t.Errorf("found the plain token stored %d times (%v), want only its hash", stored, err)
+	}
+
+	view := func(event *database.Event, invite string) int {
+		t.Helper()
+		return serve(t, handler, http.MethodGet, fmt.Sprintf("/api/v1/events/%d?invite=%s", event.ID, invite), strangerToken, "").Code
+	}
+	if code := view(private, token); code != http.StatusOK {
+		t.Errorf("GET with the invite = %d, want 200", code)
+	}
+	if code := view(private, token+"x"); code != http.StatusNotFound {
+		t.Errorf("GET with a wrong token = %d, want 404", code)
+	}
+	if code := view(other, token); code != http.StatusNotFound {
+		t.Errorf("GET of another event with the invite = %d, want 404", code)
+	}
+
+	expired := &database.Invite{EventID: private.ID, CreatedBy: owner.ID, Token: "expired-token", ExpiresAt: time.Now().Add(-time.Minute)}
+	if err := app.Model.Invites.Insert(expired); err != nil {
+		t.Fatal(err)
+	}
+	if code := view(private, expired.Token); code != http.StatusNotFound {
+		t.Errorf("GET with an expired invite = %d, want 404", code)
+	}
+
+	w = serve(t, handler, http.MethodDelete, fmt.Sprintf("/api/v1/events/%d/invites/%d", private.ID, created.Invite.ID), strangerToken, "")
+	if w.Code != http.StatusForbidden {
+		t.Errorf("revoke by a stranger = %d, want 403", w.Code)
+	}
+	w = serve(t, handler, http.MethodDelete, fmt.Sprintf("/api/v1/events/%d/invites/%d", private.ID, created.Invite.ID), ownerToken, "")
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("revoke = %d: %s", w.Code, w.Body)
+	}
+	if code := view(private, token); code != http.StatusNotFound {
+		t.Errorf("GET with a revoked invite = %d, want 404", code)
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Yiheyistm/go-restful-api/internal/database"
+	"github.com/gin-gonic/gin"
+)
+
+type joinWaitlistRequest struct {
+	TicketTypeID int `json:"ticket_type_id" binding:"required,min=1"`
+}
+
+// JoinWaitlist puts the caller on the waitlist of a sold-out ticket tier
+//
+//	@Summary		Puts the caller on the waitlist of a sold-out ticket tier
+//	@Description	Queues the caller for a ticket of a tier that has sold out. When a ticket frees up, because a reservation expired or a payment failed, the user who has waited longest gets it held for them as a pending order and a waitlist.promoted notification; checking out the tier before the order expires takes the held ticket.
+//	@Tags			ticketing
+//	@Accept			json
+//	@Produce		json
+//	@Param			id			path		int					true	"Event ID"
+//	@Param			occurrence	query		string				false	"Occurrence date (YYYY-MM-DD), required for recurring events"
+//	@Param			invite		query		string				false	"Invite token for a private event"
+//	@Param			waitlist	body		joinWaitlistRequest	true	"Ticket type"
+//	@Success		201			{object}	database.WaitlistEntry
+//	@Failure		409
+//	@Router			/api/v1/events/{id}/waitlist [post]
+//	@Security		BearerAuth
+func (app *application) joinWaitlist(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		return
+	}
+	user := app.GetUserFromContext(c)
+	event, err := app.Model.Events.GetByID(id)
+	if err != nil || !app.canViewEvent(c, event) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+	if event.Status != database.EventStatusPublished {
+		c.JSON(http.StatusConflict, gin.H{"error": "Only published events sell tickets"})
+		return
+	}
+	occurrence, ok := occurrenceParam(c, event)
+	if !ok {
+		return
+	}
+	var request joinWaitlistRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ticketType, err := app.Model.TicketTypes.Get(event.ID, request.TicketTypeID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve ticket type"})
+		return
+	}
+	if ticketType == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Ticket type not found"})
+		return
+	}
+	if !ticketType.OnSale(time.Now()) {
+		c.JSON(http.StatusConflict, gin.H{"error": "This ticket type is not on sale"})
+		return
+	}
+	existedAttendee, err := app.Model.Attendees.GetByEventAndUserId(event.ID, user.ID, occurrence)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check attendee"})
+		return
+	}
+	if existedAttendee != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "You are already attending this event"})
+		return
+	}
+
+	entry := &database.WaitlistEntry{
+		EventID:        event.ID,
+		TicketTypeID:   ticketType.ID,
+		UserID:         user.ID,
+		OccurrenceDate: occurrence,
+	}
+	if err := app.Model.Waitlist.Join(entry); err != nil {
+		switch {
+		case errors.Is(err, database.ErrTicketsAvailable):
+			c.JSON(http.StatusConflict, gin.H{"error": "This ticket type is not sold out; check out instead"})
+		case errors.Is(err, database.ErrAlreadyWaiting):
+			c.JSON(http.StatusConflict, gin.H{"error": "You are already on the waitlist for this ticket type"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to join waitlist"})
+		}
+		return
+	}
+	c.JSON(http.StatusCreated, entry)
+}
+
+// LeaveWaitlist takes the caller off a waitlist
+//
+//	@Summary		Takes the caller off a waitlist
+//	@Description	Removes one of the caller's waitlist entries that has not been promoted yet. A promoted entry is given up by letting its order expire.
+//	@Tags			ticketing
+//	@Param			id		path	int	true	"Event ID"
+//	@Param			entryId	path	int	true	"Waitlist entry ID"
+//	@Success		204
+//	@Failure		404
+//	@Router			/api/v1/events/{id}/waitlist/{entryId} [delete]
+//	@Security		BearerAuth
+func (app *application) leaveWaitlist(c *gin.Context) {
+	eventID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		return
+	}
+	entryID, err := strconv.Atoi(c.Param("entryId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid waitlist entry ID"})
+		return
+	}
+	if err := app.Model.Waitlist.Leave(eventID, app.GetUserFromContext(c).ID, entryID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Waitlist entry not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to leave waitlist"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// promoteWaitlist is the recurring JobWaitlistPromote job. It hands the
+// tickets freed by expired reservations to the users waiting for them;
+// tickets freed by failed payments are handed out by the webhook at once.
+func (app *application) promoteWaitlist(ctx context.Context, job *database.Job) error {
+	promoted, err := app.Model.Waitlist.Promote(app.OrderTTL)
+	if err != nil {
+		return err
+	}
+	if len(promoted) > 0 {
+		log.Printf("Promoted %d users from waitlists", len(promoted))
+	}
+	return nil
+}
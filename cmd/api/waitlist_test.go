@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Yiheyistm/go-restful-api/internal/database"
+	"github.com/Yiheyistm/go-restful-api/internal/payment"
+)
+
+// insertRecital inserts a published recital of owner selling a single
+// ticket.
+func (app *application) insertRecital(t *testing.T, owner *database.User) (*database.Event, *database.TicketType) {
+	t.Helper()
+	event := &database.Event{
+		OwnerId:     owner.ID,
+		Name:        "Krar recital",
+		Description: "An evening of lyre music in a small room",
+		Date:        "2026-12-22",
+		Location:    "Axum",
+		Status:      database.EventStatusPublished,
+	}
+	if err := app.Model.Events.Insert(database.Actor{}, event); err != nil {
+		t.Fatal(err)
+	}
+	tier := &database.TicketType{EventID: event.ID, Name: "Seat", PriceMinor: 2000, Currency: "ETB", Quantity: 1}
+	if err := app.Model.TicketTypes.Insert(tier); err != nil {
+		t.Fatal(err)
+	}
+	return event, tier
+}
+
+func TestWaitlistPromotionOnFailedPayment(t *testing.T) {
+	app := newTestApp(t)
+	handler := app.routes()
+	owner, _ := app.signUp(t, "musician")
+	_, buyerToken := app.signUp(t, "buyer")
+	waiter, waiterToken := app.signUp(t, "waiter")
+	event, tier := app.insertRecital(t, owner)
+
+	ticket := fmt.Sprintf(`{"ticket_type_id": %d}`, tier.ID)
+	checkout := func(token string) (int, database.Order) {
+		t.Helper()
+		w := serve(t, handler, http.MethodPost, fmt.Sprintf("/api/v1/events/%d/checkout", event.ID), token, ticket)
+		var response struct {
+			Order database.Order `json:"order"`
+		}
+		if w.Code == http.StatusCreated {
+			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return w.Code, response.Order
+	}
+	join := func() int {
+		t.Helper()
+		return serve(t, handler, http.MethodPost, fmt.Sprintf("/api/v1/events/%d/waitlist", event.ID), waiterToken, ticket).Code
+	}
+
+	if code := join(); code != http.StatusConflict {
+		t.Errorf("join before the tier sold out = %d, want 409", code)
+	}
+	code, order := checkout(buyerToken)
+	if code != http.StatusCreated {
+		t.Fatalf("checkout = %d", code)
+	}
+	if code := join(); code != http.StatusCreated {
+		t.Fatalf("join once sold out = %d, want 201", code)
+	}
+	if code, _ := checkout(waiterToken); code != http.StatusConflict {
+		t.Errorf("checkout while sold out = %d, want 409", code)
+	}
+
+	body, signature, err := app.Payments.(payment.LocalProvider).SignWebhook(payment.WebhookEvent{Type: payment.EventFailed, Reference: order.ProviderRef}, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := serve(t, handler, http.MethodPost, "/api/v1/payments/webhook", "", string(body), payment.SignatureHeader, signature)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("failed payment webhook = %d: %s", w.Code, w.Body)
+	}
+
+	page, err := app.Model.Notifications.List(waiter.ID, 0, 10, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Notifications) != 1 || page.Notifications[0].Type != database.NotificationWaitlistPromoted {
+		t.Fatalf("waiter's inbox = %+v, want a waitlist.promoted notification", page.Notifications)
+	}
+	code, held := checkout(waiterToken)
+	if code != http.StatusCreated || held.ID == order.ID || held.Status != database.OrderStatusPending {
+		t.Errorf("checkout after the promotion = %d, %+v; want the held order", code, held)
+	}
+}
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/Yiheyistm/go-restful-api/internal/database"
+	"github.com/Yiheyistm/go-restful-api/internal/queue"
+	"github.com/Yiheyistm/go-restful-api/internal/webhook"
+	"github.com/gin-gonic/gin"
+)
+
+type createWebhookRequest struct {
+	URL    string   `json:"url" binding:"required,url,max=2000"`
+	Events []string `json:"events" binding:"required,min=1,dive,oneof=event.created event.updated event.deleted attendee.added attendee.removed event.reminder"`
+	// Secret signs the deliveries. A random one is generated when it is
+	// left empty.
+	Secret string `json:"secret" binding:"omitempty,min=16,max=200"`
+}
+
+// webhookEnvelope is the body of every delivery. ID names the domain
+// event, so it is the same in every delivery of it.
+type webhookEnvelope struct {
+	ID        string          `json:"id"`
+	Type      string          `json:"type"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data" swaggertype:"object"`
+}
+
+// queueWebhooks is the outbox subscriber that queues a delivery of a
+// domain event to every webhook subscribed to it.
+func (app *application) queueWebhooks(ctx context.Context, event *database.DomainEvent) error {
+	if event.AggregateType != database.AggregateEvent || !slices.Contains(webhook.EventTypes, event.Type) {
+		return nil
+	}
+	payload, err := json.Marshal(webhookEnvelope{
+		ID:        fmt.Sprintf("evt_%d", event.ID),
+		Type:      event.Type,
+		CreatedAt: event.CreatedAt,
+		Data:      event.Payload,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = app.Model.Webhooks.Enqueue(event.ID, event.AggregateID, event.Type, payload)
+	return err
+}
+
+// deliverWebhook is the JobWebhookDelivery handler. It makes one attempt
+// of a delivery and fails the job, to be retried with backoff, when the
+// endpoint does not accept it.
+func (app *application) deliverWebhook(ctx context.Context, job *database.Job) error {
+	var payload database.DeliveryJob
+	if err := json.Unmarshal(job.Payload, &payload); err != nil {
+		return err
+	}
+	delivery, err := app.Model.Webhooks.GetPending(payload.DeliveryID)
+	if err != nil {
+		return err
+	}
+	if delivery == nil {
+		return nil
+	}
+
+	attemptedAt := time.Now()
+	result := app.Webhooks.Send(ctx, webhook.Request{
+		URL:        delivery.URL,
+		Secret:     delivery.Secret,
+		DeliveryID: delivery.ID,
+		EventType:  delivery.EventType,
+		Body:       []byte(delivery.Payload),
+	})
+
+	attempt := &database.WebhookAttempt{
+		DeliveryID:  delivery.ID,
+		AttemptedAt: attemptedAt,
+		StatusCode:  result.StatusCode,
+		DurationMs:  result.Duration.Milliseconds(),
+	}
+	status := database.DeliveryStatusSucceeded
+	next := attemptedAt
+	if result.Err != nil {
+		attempt.Error = result.Err.Error()
+		status = database.DeliveryStatusPending
+		next = attemptedAt.Add(queue.Backoff(job.Attempts))
+		if job.Attempts >= job.MaxAttempts {
+			status = database.DeliveryStatusFailed
+		}
+	}
+	err = app.Model.Webhooks.RecordAttempt(attempt, status, next)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	return result.Err
+}
+
+// webhookOfCaller resolves the :id parameter to one of the caller's
+// webhooks, answering the request itself when there is none.
+func (app *application) webhookOfCaller(c *gin.Context) (*database.Webhook, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid webhook ID"})
+		return nil, false
+	}
+	hook, err := app.Model.Webhooks.Get(app.GetUserFromContext(c).ID, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve webhook"})
+		return nil, false
+	}
+	if hook == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return nil, false
+	}
+	return hook, true
+}
+
+// deliveryOfWebhook resolves the :deliveryId parameter to a delivery of
+// hook, answering the request itself when there is none.
+func (app *application) deliveryOfWebhook(c *gin.Context, hook *database.Webhook) (*database.WebhookDelivery, bool) {
+	id, err := strconv.Atoi(c.Param("deliveryId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid delivery ID"})
+		return nil, false
+	}
+	delivery, err := app.Model.Webhooks.GetDelivery(hook.ID, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve delivery"})
+		return nil, false
+	}
+	if delivery == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Delivery not found"})
+		return nil, false
+	}
+	return delivery, true
+}
+
+// GetWebhooks lists the caller's webhooks
+//
+//	@Summary		Lists the caller's webhooks
+//	@Description	Lists the endpoints the caller registered. Secrets are not included.
+//	@Tags			webhooks
+//	@Produce		json
+//	@Success		200	{array}	database.Webhook
+//	@Router			/api/v1/me/webhooks [get]
+//	@Security		BearerAuth
+func (app *application) getWebhooks(c *gin.Context) {
+	webhooks, err := app.Model.Webhooks.GetByUser(app.GetUserFromContext(c).ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve webhooks"})
+		return
+	}
+	c.JSON(http.StatusOK, webhooks)
+}
+
+// CreateWebhook registers a webhook
+//
+//	@Summary		Registers a webhook
+//	@Description	Registers an endpoint to receive the chosen event types (event.created, event.updated, event.deleted, attendee.added, attendee.removed) for every event the caller owns or co-hosts, and event.reminder ahead of the events the caller attends. Each delivery is a JSON POST signed in the Webhook-Signature header as "t=<unix seconds>,v1=<hex HMAC-SHA256 of "<t>.<body>">" with the webhook's secret, which is only returned here. Failed deliveries are retried with exponential backoff. The URL must resolve to a public address, and redirects are not followed.
+//	@Tags			webhooks
+//	@Accept			json
+//	@Produce		json
+//	@Param			webhook	body		createWebhookRequest	true	"Webhook"
+//	@Success		201		{object}	database.Webhook
+//	@Router			/api/v1/me/webhooks [post]
+//	@Security		BearerAuth
+func (app *application) createWebhook(c *gin.Context) {
+	var request createWebhookRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := app.Webhooks.CheckURL(c.Request.Context(), request.URL); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if request.Secret == "" {
+		secret, err := webhook.NewSecret()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create webhook"})
+			return
+		}
+		request.Secret = secret
+	}
+	events := []string{}
+	for _, eventType := range webhook.EventTypes {
+		if slices.Contains(request.Events, eventType) {
+			events = append(events, eventType)
+		}
+	}
+
+	hook := &database.Webhook{
+		UserID: app.GetUserFromContext(c).ID,
+		URL:    request.URL,
+		Secret: request.Secret,
+		Events: events,
+	}
+	if err := app.Model.Webhooks.Insert(hook); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create webhook"})
+		return
+	}
+	c.JSON(http.StatusCreated, hook)
+}
+
+// DeleteWebhook removes a webhook
+//
+//	@Summary		Removes a webhook
+//	@Description	Removes one of the caller's webhooks along with its delivery log. Pending deliveries are dropped.
+//	@Tags			webhooks
+//	@Param			id	path	int	true	"Webhook ID"
+//	@Success		204
+//	@Router			/api/v1/me/webhooks/{id} [delete]
+//	@Security		BearerAuth
+func (app *application) deleteWebhook(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid webhook ID"})
+		return
+	}
+	err = app.Model.Webhooks.Delete(app.GetUserFromContext(c).ID, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete webhook"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// GetWebhookDeliveries lists the deliveries of a webhook
+//
+//	@Summary		Lists the deliveries of a webhook
+//	@Description	Lists what was sent to one of the caller's webhooks, newest first, with the status of each delivery.
+//	@Tags			webhooks
+//	@Produce		json
+//	@Param			id		path	int	true	"Webhook ID"
+//	@Param			limit	query	int	false	"Maximum number of deliveries (default 100)"
+//	@Param			offset	query	int	false	"Number of deliveries to skip"
+//	@Success		200		{array}	database.WebhookDelivery
+//	@Router			/api/v1/me/webhooks/{id}/deliveries [get]
+//	@Security		BearerAuth
+func (app *application) getWebhookDeliveries(c *gin.Context) {
+	hook, ok := app.webhookOfCaller(c)
+	if !ok {
+		return
+	}
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	offset, _ := strconv.Atoi(c.Query("offset"))
+	deliveries, err := app.Model.Webhooks.GetDeliveries(hook.ID, limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve deliveries"})
+		return
+	}
+	c.JSON(http.StatusOK, deliveries)
+}
+
+// GetWebhookDeliveryAttempts lists the attempts of a delivery
+//
+//	@Summary		Lists the attempts of a delivery
+//	@Description	Lists every attempt to send a delivery, oldest first, with the status code the endpoint answered or the error that prevented it.
+//	@Tags			webhooks
+//	@Produce		json
+//	@Param			id			path	int	true	"Webhook ID"
+//	@Param			deliveryId	path	int	true	"Delivery ID"
+//	@Success		200			{array}	database.WebhookAttempt
+//	@Router			/api/v1/me/webhooks/{id}/deliveries/{deliveryId}/attempts [get]
+//	@Security		BearerAuth
+func (app *application) getWebhookDeliveryAttempts(c *gin.Context) {
+	hook, ok := app.webhookOfCaller(c)
+	if !ok {
+		return
+	}
+	delivery, ok := app.deliveryOfWebhook(c, hook)
+	if !ok {
+		return
+	}
+	attempts, err := app.Model.Webhooks.GetAttempts(delivery.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve attempts"})
+		return
+	}
+	c.JSON(http.StatusOK, attempts)
+}
+
+// RedeliverWebhook sends a delivery again
+//
+//	@Summary		Sends a delivery again
+//	@Description	Queues a delivery that succeeded or failed to be sent again right away with a fresh set of retries. It keeps its Webhook-Id so receivers can recognise it.
+//	@Tags			webhooks
+//	@Produce		json
+//	@Param			id			path		int	true	"Webhook ID"
+//	@Param			deliveryId	path		int	true	"Delivery ID"
+//	@Success		202			{object}	database.WebhookDelivery
+//	@Failure		409
+//	@Router			/api/v1/me/webhooks/{id}/deliveries/{deliveryId}/redeliver [post]
+//	@Security		BearerAuth
+func (app *application) redeliverWebhook(c *gin.Context) {
+	hook, ok := app.webhookOfCaller(c)
+	if !ok {
+		return
+	}
+	delivery, ok := app.deliveryOfWebhook(c, hook)
+	if !ok {
+		return
+	}
+	if err := app.Model.Webhooks.Redeliver(delivery); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Delivery is still pending"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to queue delivery"})
+		return
+	}
+	c.JSON(http.StatusAccepted, delivery)
+}
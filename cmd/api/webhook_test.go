@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Yiheyistm/go-restful-api/internal/database"
+	"github.com/Yiheyistm/go-restful-api/internal/queue"
+	"github.com/Yiheyistm/go-restful-api/internal/webhook"
+)
+
+// insertIntegration inserts an organizer running an integration against
+// the API and a draft event of theirs for it to be notified about.
+func (app *application) insertIntegration(t *testing.T) (*database.User, *database.Event) {
+	t.Helper()
+	owner, _ := app.signUp(t, "integrator")
+	event := &database.Event{OwnerId: owner.ID, Name: "Release party", Description: "Shipping the public API together", Date: "2026-11-28", Location: "Dire Dawa"}
+	if err := app.Model.Events.Insert(database.Actor{}, event); err != nil {
+		t.Fatal(err)
+	}
+	return owner, event
+}
+
+func TestDeliverWebhookRetriesUntilAccepted(t *testing.T) {
+	app := newTestApp(t)
+
+	var calls atomic.Int32
+	var verified atomic.Bool
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		verified.Store(webhook.Verify("whsec_test", r.Header.Get(webhook.SignatureHeader), body) == nil)
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer receiver.Close()
+
+	owner, event := app.insertIntegration(t)
+	hook := &database.Webhook{UserID: owner.ID, URL: receiver.URL, Secret: "whsec_test", Events: []string{webhook.EventCreated}}
+	if err := app.Model.Webhooks.Insert(hook); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.queueWebhooks(context.Background(), &database.DomainEvent{
+		ID: 1, AggregateType: database.AggregateEvent, AggregateID: event.ID, Type: webhook.EventCreated, Payload: []byte(`{}`),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	job, err := app.Model.Jobs.Claim(time.Minute)
+	if err != nil || job == nil || job.Type != database.JobWebhookDelivery {
+		t.Fatalf("Claim = %+v, %v; want the delivery job", job, err)
+	}
+	if err := app.deliverWebhook(context.Background(), job); err == nil {
+		t.Fatal("deliverWebhook succeeded although the endpoint answered 503")
+	}
+	deliveries, err := app.Model.Webhooks.GetDeliveries(hook.ID, 0, 0)
+	if err != nil || len(deliveries) != 1 {
+		t.Fatalf("GetDeliveries = %d, %v", len(deliveries), err)
+	}
+	delivery := deliveries[0]
+	if delivery.Status != database.DeliveryStatusPending || delivery.Attempts != 1 {
+		t.Errorf("after a failed attempt the delivery is %s with %d attempts, want pending with 1", delivery.Status, delivery.Attempts)
+	}
+	if wait := time.Until(delivery.NextAttemptAt); wait < queue.Backoff(1)-5*time.Second || wait > queue.Backoff(1) {
+		t.Errorf("next attempt in %s, want about %s", wait, queue.Backoff(1))
+	}
+
+	// The queue retries the job; a second claim stands in for it.
+	job.Attempts++
+	if err := app.deliverWebhook(context.Background(), job); err != nil {
+		t.Fatalf("deliverWebhook = %v, want the endpoint to accept it", err)
+	}
+	delivery, err = app.Model.Webhooks.GetDelivery(hook.ID, delivery.ID)
+	if err != nil || delivery.Status != database.DeliveryStatusSucceeded || delivery.Attempts != 2 {
+		t.Errorf("delivery = %+v, %v; want succeeded after 2 attempts", delivery, err)
+	}
+	attempts, err := app.Model.Webhooks.GetAttempts(delivery.ID)
+	if err != nil || len(attempts) != 2 {
+		t.Fatalf("GetAttempts = %d, %v; want 2", len(attempts), err)
+	}
+	if attempts[0].StatusCode != http.StatusServiceUnavailable || attempts[0].Error == "" || attempts[1].StatusCode != http.StatusOK {
+		t.Errorf("attempt log = %+v, %+v", attempts[0], attempts[1])
+	}
+	if !verified.Load() {
+		t.Error("the receiver could not verify the delivery's signature")
+	}
+
+	// Once succeeded, a leftover job sends nothing.
+	if err := app.deliverWebhook(context.Background(), job); err != nil || calls.Load() != 2 {
+		t.Errorf("deliverWebhook of a finished delivery = %v after %d calls, want nil after 2", err, calls.Load())
+	}
+}
+
+func TestDeliverWebhookGivesUp(t *testing.T) {
+	app := newTestApp(t)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer receiver.Close()
+
+	owner, event := app.insertIntegration(t)
+	hook := &database.Webhook{UserID: owner.ID, URL: receiver.URL, Secret: "whsec_test", Events: []string{webhook.EventCreated}}
+	if err := app.Model.Webhooks.Insert(hook); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := app.Model.Webhooks.Enqueue(1, event.ID, webhook.EventCreated, []byte(`{}`)); err != nil {
+		t.Fatal(err)
+	}
+	job, err := app.Model.Jobs.Claim(time.Minute)
+	if err != nil || job == nil {
+		t.Fatalf("Claim = %v, %v", job, err)
+	}
+	job.Attempts = job.MaxAttempts
+	if err := app.deliverWebhook(context.Background(), job); err == nil {
+		t.Fatal("deliverWebhook succeeded although the endpoint answered 500")
+	}
+	deliveries, err := app.Model.Webhooks.GetDeliveries(hook.ID, 0, 0)
+	if err != nil || len(deliveries) != 1 || deliveries[0].Status != database.DeliveryStatusFailed {
+		t.Errorf("GetDeliveries = %+v, %v; want one failed delivery", deliveries, err)
+	}
+}
@@ -1,13 +1,11 @@
 package main
 
 import (
-	"database/sql"
 	"log"
 	"os"
 
+	"github.com/Yiheyistm/go-restful-api/internal/database"
 	"github.com/golang-migrate/migrate"
-	"github.com/golang-migrate/migrate/database/sqlite3"
-	"github.com/golang-migrate/migrate/source/file"
 )
 
 func main() {
@@ -17,29 +15,13 @@ func main() {
 
 	direction := os.Args[1]
 
-	db, err := sql.Open("sqlite3", "./data.db")
+	db, err := database.Open(database.ConfigFromEnv())
 	if err != nil {
 		log.Fatal("Failed to connect to the database:", err)
 	}
 	defer db.Close()
-	
 
-	instance, err := sqlite3.WithInstance(db, &sqlite3.Config{})
-	if err != nil {
-		log.Fatal("Failed to create migration instance:", err)
-	}
-
-	fSrc, err := (&file.File{}).Open("cmd/migrate/migrations")
-	if err != nil {
-		log.Fatal("Failed to open migration files:", err)
-	}
-
-	m, err := migrate.NewWithInstance(
-		"file",
-		fSrc,
-		"sqlite3",
-		instance,
-	)
+	m, err := db.Migrator("cmd/migrate/migrations")
 	if err != nil {
 		log.Fatal("Failed to create migration instance:", err)
 	}
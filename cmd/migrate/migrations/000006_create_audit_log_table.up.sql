@@ -0,0 +1,18 @@
+CREATE TABLE
+    IF NOT EXISTS audit_log (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        actor_id INTEGER,
+        request_id TEXT NOT NULL DEFAULT '',
+        entity_type TEXT NOT NULL,
+        entity_id INTEGER NOT NULL,
+        event_id INTEGER,
+        action TEXT NOT NULL,
+        diff TEXT NOT NULL,
+        created_at DATETIME NOT NULL
+    );
+
+CREATE INDEX IF NOT EXISTS idx_audit_log_entity ON audit_log (entity_type, entity_id);
+
+CREATE INDEX IF NOT EXISTS idx_audit_log_event ON audit_log (event_id);
+
+CREATE INDEX IF NOT EXISTS idx_audit_log_actor ON audit_log (actor_id);
@@ -0,0 +1,7 @@
+ALTER TABLE events ADD COLUMN deleted_at DATETIME;
+
+ALTER TABLE users ADD COLUMN deleted_at DATETIME;
+
+CREATE INDEX IF NOT EXISTS idx_events_deleted_at ON events (deleted_at);
+
+CREATE INDEX IF NOT EXISTS idx_users_deleted_at ON users (deleted_at);
@@ -0,0 +1,9 @@
+DROP TABLE IF EXISTS event_occurrences;
+
+DROP INDEX IF EXISTS idx_attendees_event_occurrence;
+
+ALTER TABLE attendees DROP COLUMN occurrence_date;
+
+ALTER TABLE events DROP COLUMN exdates;
+
+ALTER TABLE events DROP COLUMN rrule;
@@ -0,0 +1,20 @@
+ALTER TABLE events ADD COLUMN rrule TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE events ADD COLUMN exdates TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE attendees ADD COLUMN occurrence_date TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS idx_attendees_event_occurrence ON attendees (event_id, occurrence_date);
+
+CREATE TABLE
+    IF NOT EXISTS event_occurrences (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        event_id INTEGER NOT NULL,
+        occurrence_date TEXT NOT NULL,
+        name TEXT NOT NULL,
+        description TEXT NOT NULL,
+        date TEXT NOT NULL,
+        location TEXT NOT NULL,
+        UNIQUE (event_id, occurrence_date),
+        FOREIGN KEY (event_id) REFERENCES events (id) ON DELETE CASCADE
+    );
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS event_tags;
+
+DROP TABLE IF EXISTS tags;
@@ -0,0 +1,5 @@
+ALTER TABLE events ADD COLUMN latitude REAL;
+
+ALTER TABLE events ADD COLUMN longitude REAL;
+
+CREATE INDEX IF NOT EXISTS idx_events_latitude_longitude ON events (latitude, longitude);
@@ -0,0 +1,3 @@
+ALTER TABLE events ADD COLUMN status TEXT NOT NULL DEFAULT 'published' CHECK (status IN ('draft', 'published', 'cancelled', 'completed'));
+
+CREATE INDEX IF NOT EXISTS idx_events_status ON events (status);
@@ -0,0 +1,15 @@
+CREATE TABLE
+    IF NOT EXISTS event_organizers (
+        event_id INTEGER NOT NULL,
+        user_id INTEGER NOT NULL,
+        role TEXT NOT NULL CHECK (role IN ('owner', 'co-host', 'checkin')),
+        created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+        PRIMARY KEY (event_id, user_id),
+        FOREIGN KEY (event_id) REFERENCES events (id) ON DELETE CASCADE,
+        FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+    );
+
+CREATE INDEX IF NOT EXISTS idx_event_organizers_user_id ON event_organizers (user_id);
+
+INSERT INTO event_organizers (event_id, user_id, role)
+SELECT id, owner_id, 'owner' FROM events;
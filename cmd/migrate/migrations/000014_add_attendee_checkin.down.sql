@@ -0,0 +1 @@
+ALTER TABLE attendees DROP COLUMN checked_in_at;
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS orders;
+
+DROP TABLE IF EXISTS promo_codes;
+
+DROP TABLE IF EXISTS ticket_types;
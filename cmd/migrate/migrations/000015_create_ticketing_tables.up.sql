@@ -0,0 +1,60 @@
+CREATE TABLE
+    IF NOT EXISTS ticket_types (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        event_id INTEGER NOT NULL,
+        name TEXT NOT NULL,
+        price_minor INTEGER NOT NULL CHECK (price_minor >= 0),
+        currency TEXT NOT NULL,
+        quantity INTEGER NOT NULL CHECK (quantity > 0),
+        sales_start DATETIME,
+        sales_end DATETIME,
+        created_at DATETIME NOT NULL,
+        FOREIGN KEY (event_id) REFERENCES events (id) ON DELETE CASCADE
+    );
+
+CREATE INDEX IF NOT EXISTS idx_ticket_types_event_id ON ticket_types (event_id);
+
+CREATE TABLE
+    IF NOT EXISTS promo_codes (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        event_id INTEGER NOT NULL,
+        code TEXT NOT NULL,
+        kind TEXT NOT NULL CHECK (kind IN ('percent', 'fixed')),
+        amount INTEGER NOT NULL CHECK (amount > 0),
+        max_uses INTEGER,
+        created_at DATETIME NOT NULL,
+        UNIQUE (event_id, code),
+        FOREIGN KEY (event_id) REFERENCES events (id) ON DELETE CASCADE
+    );
+
+CREATE TABLE
+    IF NOT EXISTS orders (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        event_id INTEGER NOT NULL,
+        user_id INTEGER NOT NULL,
+        ticket_type_id INTEGER NOT NULL,
+        promo_code_id INTEGER,
+        occurrence_date TEXT NOT NULL DEFAULT '',
+        amount_minor INTEGER NOT NULL,
+        currency TEXT NOT NULL,
+        status TEXT NOT NULL CHECK (status IN ('pending', 'paid', 'failed', 'expired')),
+        provider TEXT NOT NULL DEFAULT '',
+        provider_ref TEXT NOT NULL DEFAULT '',
+        attendee_id INTEGER,
+        expires_at DATETIME NOT NULL,
+        created_at DATETIME NOT NULL,
+        paid_at DATETIME,
+        FOREIGN KEY (event_id) REFERENCES events (id) ON DELETE CASCADE,
+        FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+        FOREIGN KEY (ticket_type_id) REFERENCES ticket_types (id) ON DELETE CASCADE,
+        FOREIGN KEY (promo_code_id) REFERENCES promo_codes (id) ON DELETE SET NULL,
+        FOREIGN KEY (attendee_id) REFERENCES attendees (id) ON DELETE SET NULL
+    );
+
+CREATE INDEX IF NOT EXISTS idx_orders_ticket_type_status ON orders (ticket_type_id, status);
+
+CREATE INDEX IF NOT EXISTS idx_orders_promo_code_id ON orders (promo_code_id);
+
+CREATE INDEX IF NOT EXISTS idx_orders_user_id ON orders (user_id);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_orders_provider_ref ON orders (provider, provider_ref) WHERE provider_ref <> '';
@@ -0,0 +1,5 @@
+ALTER TABLE orders DROP COLUMN answers;
+
+ALTER TABLE attendees DROP COLUMN answers;
+
+DROP TABLE IF EXISTS event_forms;
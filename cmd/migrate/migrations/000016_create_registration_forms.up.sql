@@ -0,0 +1,11 @@
+CREATE TABLE
+    IF NOT EXISTS event_forms (
+        event_id INTEGER PRIMARY KEY,
+        questions TEXT NOT NULL,
+        updated_at DATETIME NOT NULL,
+        FOREIGN KEY (event_id) REFERENCES events (id) ON DELETE CASCADE
+    );
+
+ALTER TABLE attendees ADD COLUMN answers TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE orders ADD COLUMN answers TEXT NOT NULL DEFAULT '';
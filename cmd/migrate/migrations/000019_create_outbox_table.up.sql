@@ -0,0 +1,12 @@
+CREATE TABLE
+    IF NOT EXISTS outbox (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        aggregate_type TEXT NOT NULL,
+        aggregate_id INTEGER NOT NULL,
+        event_type TEXT NOT NULL,
+        payload TEXT NOT NULL,
+        created_at DATETIME NOT NULL,
+        published_at DATETIME
+    );
+
+CREATE INDEX IF NOT EXISTS idx_outbox_unpublished ON outbox (id) WHERE published_at IS NULL;
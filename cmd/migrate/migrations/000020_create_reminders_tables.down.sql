@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS event_reminders;
+
+DROP TABLE IF EXISTS notification_preferences;
@@ -0,0 +1,23 @@
+CREATE TABLE
+    IF NOT EXISTS notification_preferences (
+        user_id INTEGER PRIMARY KEY,
+        reminders BOOLEAN NOT NULL DEFAULT 1,
+        email BOOLEAN NOT NULL DEFAULT 1,
+        webhook BOOLEAN NOT NULL DEFAULT 1,
+        in_app BOOLEAN NOT NULL DEFAULT 1,
+        updated_at DATETIME NOT NULL,
+        FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+    );
+
+CREATE TABLE
+    IF NOT EXISTS event_reminders (
+        event_id INTEGER NOT NULL,
+        occurrence_date TEXT NOT NULL,
+        user_id INTEGER NOT NULL,
+        starts_on TEXT NOT NULL,
+        offset_seconds INTEGER NOT NULL,
+        sent_at DATETIME NOT NULL,
+        PRIMARY KEY (event_id, occurrence_date, user_id, starts_on, offset_seconds),
+        FOREIGN KEY (event_id) REFERENCES events (id) ON DELETE CASCADE,
+        FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+    );
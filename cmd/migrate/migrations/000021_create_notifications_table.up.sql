@@ -0,0 +1,17 @@
+CREATE TABLE
+    IF NOT EXISTS notifications (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        user_id INTEGER NOT NULL,
+        type TEXT NOT NULL,
+        event_id INTEGER,
+        title TEXT NOT NULL,
+        body TEXT NOT NULL DEFAULT '',
+        dedupe_key TEXT NOT NULL,
+        read_at DATETIME,
+        created_at DATETIME NOT NULL,
+        UNIQUE (user_id, dedupe_key),
+        FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+        FOREIGN KEY (event_id) REFERENCES events (id) ON DELETE CASCADE
+    );
+
+CREATE INDEX IF NOT EXISTS idx_notifications_unread ON notifications (user_id, read_at);
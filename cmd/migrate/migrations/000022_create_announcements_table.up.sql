@@ -0,0 +1,15 @@
+CREATE TABLE
+    IF NOT EXISTS announcements (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        event_id INTEGER NOT NULL,
+        author_id INTEGER,
+        subject TEXT NOT NULL,
+        body TEXT NOT NULL,
+        body_html TEXT NOT NULL,
+        created_at DATETIME NOT NULL,
+        emailed_at DATETIME,
+        FOREIGN KEY (event_id) REFERENCES events (id) ON DELETE CASCADE,
+        FOREIGN KEY (author_id) REFERENCES users (id) ON DELETE SET NULL
+    );
+
+CREATE INDEX IF NOT EXISTS idx_announcements_event_id ON announcements (event_id, created_at);
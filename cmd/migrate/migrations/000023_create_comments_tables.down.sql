@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS comment_reports;
+
+DROP TABLE IF EXISTS comments;
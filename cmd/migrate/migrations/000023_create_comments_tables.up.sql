@@ -0,0 +1,34 @@
+CREATE TABLE
+    IF NOT EXISTS comments (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        event_id INTEGER NOT NULL,
+        user_id INTEGER NOT NULL,
+        parent_id INTEGER,
+        root_id INTEGER,
+        body TEXT NOT NULL,
+        created_at DATETIME NOT NULL,
+        edited_at DATETIME,
+        deleted_at DATETIME,
+        deleted_by INTEGER,
+        FOREIGN KEY (event_id) REFERENCES events (id) ON DELETE CASCADE,
+        FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+        FOREIGN KEY (parent_id) REFERENCES comments (id) ON DELETE CASCADE,
+        FOREIGN KEY (deleted_by) REFERENCES users (id) ON DELETE SET NULL
+    );
+
+CREATE INDEX IF NOT EXISTS idx_comments_event_root ON comments (event_id, root_id);
+
+CREATE TABLE
+    IF NOT EXISTS comment_reports (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        comment_id INTEGER NOT NULL,
+        user_id INTEGER NOT NULL,
+        reason TEXT NOT NULL,
+        created_at DATETIME NOT NULL,
+        resolved_at DATETIME,
+        UNIQUE (comment_id, user_id),
+        FOREIGN KEY (comment_id) REFERENCES comments (id) ON DELETE CASCADE,
+        FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+    );
+
+CREATE INDEX IF NOT EXISTS idx_comment_reports_open ON comment_reports (comment_id, resolved_at);
@@ -0,0 +1,16 @@
+CREATE TABLE
+    IF NOT EXISTS reviews (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        event_id INTEGER NOT NULL,
+        user_id INTEGER NOT NULL,
+        rating INTEGER NOT NULL CHECK (rating BETWEEN 1 AND 5),
+        body TEXT NOT NULL DEFAULT '',
+        created_at DATETIME NOT NULL,
+        reply TEXT,
+        replied_at DATETIME,
+        replied_by INTEGER,
+        UNIQUE (event_id, user_id),
+        FOREIGN KEY (event_id) REFERENCES events (id) ON DELETE CASCADE,
+        FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+        FOREIGN KEY (replied_by) REFERENCES users (id) ON DELETE SET NULL
+    );
@@ -0,0 +1 @@
+ALTER TABLE events DROP COLUMN cancellation_emailed_at;
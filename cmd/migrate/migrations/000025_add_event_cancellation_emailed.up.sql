@@ -0,0 +1 @@
+ALTER TABLE events ADD COLUMN cancellation_emailed_at DATETIME;
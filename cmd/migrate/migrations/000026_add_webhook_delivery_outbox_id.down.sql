@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_webhook_deliveries_outbox_id;
+
+ALTER TABLE webhook_deliveries DROP COLUMN outbox_id;
@@ -0,0 +1,3 @@
+ALTER TABLE webhook_deliveries ADD COLUMN outbox_id INTEGER;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_webhook_deliveries_outbox_id ON webhook_deliveries (webhook_id, outbox_id);
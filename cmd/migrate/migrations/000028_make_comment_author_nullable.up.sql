@@ -0,0 +1,55 @@
+-- Purging a user keeps their comments, so replies under them survive, and
+-- clears the author instead. SQLite cannot alter a foreign key in place,
+-- so comments and comment_reports, which references it, are rebuilt.
+PRAGMA defer_foreign_keys = ON;
+
+CREATE TABLE
+    comments_new (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        event_id INTEGER NOT NULL,
+        user_id INTEGER,
+        parent_id INTEGER,
+        root_id INTEGER,
+        body TEXT NOT NULL,
+        created_at DATETIME NOT NULL,
+        edited_at DATETIME,
+        deleted_at DATETIME,
+        deleted_by INTEGER,
+        FOREIGN KEY (event_id) REFERENCES events (id) ON DELETE CASCADE,
+        FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE SET NULL,
+        FOREIGN KEY (parent_id) REFERENCES comments_new (id) ON DELETE CASCADE,
+        FOREIGN KEY (deleted_by) REFERENCES users (id) ON DELETE SET NULL
+    );
+
+INSERT INTO comments_new (id, event_id, user_id, parent_id, root_id, body, created_at, edited_at, deleted_at, deleted_by)
+SELECT id, event_id, user_id, parent_id, root_id, body, created_at, edited_at, deleted_at, deleted_by
+FROM comments ORDER BY id;
+
+CREATE TABLE
+    comment_reports_new (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        comment_id INTEGER NOT NULL,
+        user_id INTEGER NOT NULL,
+        reason TEXT NOT NULL,
+        created_at DATETIME NOT NULL,
+        resolved_at DATETIME,
+        UNIQUE (comment_id, user_id),
+        FOREIGN KEY (comment_id) REFERENCES comments_new (id) ON DELETE CASCADE,
+        FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+    );
+
+INSERT INTO comment_reports_new (id, comment_id, user_id, reason, created_at, resolved_at)
+SELECT id, comment_id, user_id, reason, created_at, resolved_at
+FROM comment_reports;
+
+DROP TABLE comment_reports;
+
+DROP TABLE comments;
+
+ALTER TABLE comments_new RENAME TO comments;
+
+ALTER TABLE comment_reports_new RENAME TO comment_reports;
+
+CREATE INDEX IF NOT EXISTS idx_comments_event_root ON comments (event_id, root_id);
+
+CREATE INDEX IF NOT EXISTS idx_comment_reports_open ON comment_reports (comment_id, resolved_at);
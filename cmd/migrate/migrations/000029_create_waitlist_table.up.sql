@@ -0,0 +1,20 @@
+CREATE TABLE
+    IF NOT EXISTS waitlist_entries (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        event_id INTEGER NOT NULL,
+        ticket_type_id INTEGER NOT NULL,
+        user_id INTEGER NOT NULL,
+        occurrence_date TEXT NOT NULL DEFAULT '',
+        order_id INTEGER,
+        created_at DATETIME NOT NULL,
+        promoted_at DATETIME,
+        UNIQUE (ticket_type_id, user_id, occurrence_date),
+        FOREIGN KEY (event_id) REFERENCES events (id) ON DELETE CASCADE,
+        FOREIGN KEY (ticket_type_id) REFERENCES ticket_types (id) ON DELETE CASCADE,
+        FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+        FOREIGN KEY (order_id) REFERENCES orders (id) ON DELETE SET NULL
+    );
+
+CREATE INDEX IF NOT EXISTS idx_waitlist_entries_waiting ON waitlist_entries (ticket_type_id, promoted_at);
+
+CREATE INDEX IF NOT EXISTS idx_waitlist_entries_order_id ON waitlist_entries (order_id);
@@ -0,0 +1,201 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// JobAnnouncementEmail is the type of the job that emails an announcement
+// to the attendees of its event.
+const JobAnnouncementEmail = "announcement.email"
+
+// ErrRateLimited is returned when an event has posted as many
+// announcements as it may for now.
+var ErrRateLimited = errors.New("rate limited")
+
+type AnnouncementModel struct {
+	DB     *sql.DB
+	ReadDB *sql.DB
+}
+
+// Announcement is a message from an event's organizers to its attendees.
+// Body is Markdown; BodyHTML is its sanitized rendering.
+type Announcement struct {
+	ID        int       `json:"id"`
+	EventID   int       `json:"event_id"`
+	AuthorID  *int      `json:"author_id"`
+	Subject   string    `json:"subject"`
+	Body      string    `json:"body"`
+	BodyHTML  string    `json:"body_html"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// AnnouncementJob is the payload of a JobAnnouncementEmail job.
+type AnnouncementJob struct {
+	AnnouncementID int `json:"announcement_id"`
+}
+
+const announcementColumns = `id, event_id, author_id, subject, body, body_html, created_at`
+
+func scanAnnouncement(row rowScanner, a *Announcement) error {
+	return row.Scan(&a.ID, &a.EventID, &a.AuthorID, &a.Subject, &a.Body, &a.BodyHTML, &a.CreatedAt)
+}
+
+// Insert posts an announcement unless its event already posted limit of
+// them within window, in which case it returns ErrRateLimited and when
+// the next one may be posted. In the same transaction it puts the
+// announcement in the inbox of every attendee and queues a
+// JobAnnouncementEmail job to email them.
+func (s *AnnouncementModel) Insert(announcement *Announcement, limit int, window time.Duration) (time.Time, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	now := time.Now().UTC()
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer tx.Rollback()
+
+	// The limit-th newest announcement in the window is the one whose
+	// expiry frees a slot.
+	query := `
+		SELECT created_at FROM announcements WHERE event_id = $1 AND created_at > $2
+		ORDER BY created_at DESC LIMIT $3`
+	rows, err := tx.QueryContext(ctx, query, announcement.EventID, now.Add(-window), limit)
+	if err != nil {
+		return time.Time{}, err
+	}
+	count := 0
+	var oldest time.Time
+	for rows.Next() {
+		if err := rows.Scan(&oldest); err != nil {
+			rows.Close()
+			return time.Time{}, err
+		}
+		count++
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return time.Time{}, err
+	}
+	if count >= limit {
+		if count == 0 {
+			return now.Add(window), ErrRateLimited
+		}
+		return oldest.Add(window), ErrRateLimited
+	}
+
+	announcement.CreatedAt = now
+	query = `
+		INSERT INTO announcements (event_id, author_id, subject, body, body_html, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id`
+	err = tx.QueryRowContext(ctx, query, announcement.EventID, announcement.AuthorID, announcement.Subject,
+		announcement.Body, announcement.BodyHTML, now).Scan(&announcement.ID)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	_, err = insertNotifications(ctx, tx, &Notification{
+		Type:    NotificationAnnouncement,
+		EventID: &announcement.EventID,
+		Title:   announcement.Subject,
+		Body:    announcement.Body,
+		Key:     fmt.Sprintf("%s:%d", NotificationAnnouncement, announcement.ID),
+	}, attendeeRecipients)
+	if err != nil {
+		return time.Time{}, err
+	}
+	job, err := NewJob(JobAnnouncementEmail, AnnouncementJob{AnnouncementID: announcement.ID})
+	if err != nil {
+		return time.Time{}, err
+	}
+	if err := insertJob(ctx, tx, job); err != nil {
+		return time.Time{}, err
+	}
+	return time.Time{}, tx.Commit()
+}
+
+// Get returns an announcement, or nil when there is none.
+func (s *AnnouncementModel) Get(id int) (*Announcement, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var announcement Announcement
+	err := scanAnnouncement(s.ReadDB.QueryRowContext(ctx, `SELECT `+announcementColumns+` FROM announcements WHERE id = $1`, id), &announcement)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &announcement, nil
+}
+
+// GetByEvent lists the announcements of an event, newest first.
+func (s *AnnouncementModel) GetByEvent(eventID, limit, offset int) ([]*Announcement, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	query := `SELECT ` + announcementColumns + ` FROM announcements WHERE event_id = $1 ORDER BY id DESC LIMIT $2 OFFSET $3`
+	rows, err := s.ReadDB.QueryContext(ctx, query, eventID, limit, max(offset, 0))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	announcements := []*Announcement{}
+	for rows.Next() {
+		var announcement Announcement
+		if err := scanAnnouncement(rows, &announcement); err != nil {
+			return nil, err
+		}
+		announcements = append(announcements, &announcement)
+	}
+	return announcements, rows.Err()
+}
+
+// EmailRecipients lists the attendees of an announcement's event who keep
+// email notifications on.
+func (s *AnnouncementModel) EmailRecipients(announcement *Announcement) ([]Recipient, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	return emailRecipients(ctx, s.ReadDB, announcement.EventID)
+}
+
+// QueueEmails queues jobs, one email per recipient, and records that the
+// announcement was emailed, all at once. It queues nothing when the
+// announcement was already emailed, so a retried job never sends twice.
+func (s *AnnouncementModel) QueueEmails(announcement *Announcement, jobs []*Job) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE announcements SET emailed_at = $1 WHERE id = $2 AND emailed_at IS NULL`
+	err = expectOneRow(tx.ExecContext(ctx, query, time.Now().UTC(), announcement.ID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, job := range jobs {
+		if err := insertJob(ctx, tx, job); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
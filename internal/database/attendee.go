@@ -7,37 +7,76 @@ import (
 )
 
 type AttendeeModel struct {
-	DB *sql.DB
+	DB     *sql.DB
+	ReadDB *sql.DB
 }
 
 type Attendee struct {
 	ID      int `json:"id"`
 	EventID int `json:"event_id"`
 	UserID  int `json:"user_id"`
+	// OccurrenceDate is the instance of a recurring event the attendee
+	// signed up for; it is empty for single events.
+	OccurrenceDate string     `json:"occurrence_date,omitempty"`
+	CheckedInAt    *time.Time `json:"checked_in_at,omitempty"`
+	// Answers holds the attendee's replies to the event's registration
+	// form, validated with ValidateAnswers before insert.
+	Answers Answers `json:"answers,omitempty"`
+	// Ticket is the signed code shown at the door. It is derived from the
+	// row rather than stored, and only filled in on the response that
+	// creates the attendance: the attendee's own RSVP, or the organizer's
+	// when they add someone.
+	Ticket string `json:"ticket,omitempty"`
 }
 
-func (s *AttendeeModel) Insert(attendee *Attendee) error {
+// CheckInCounts summarizes the check-ins of an event.
+type CheckInCounts struct {
+	CheckedIn int `json:"checked_in"`
+	Total     int `json:"total"`
+}
+
+func (s *AttendeeModel) Insert(actor Actor, attendee *Attendee) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
+	answers, err := encodeAnswers(attendee.Answers)
+	if err != nil {
+		return err
+	}
 	query := `
-		INSERT INTO attendees (event_id, user_id)
-		VALUES ($1, $2) RETURNING id`
+		INSERT INTO attendees (event_id, user_id, occurrence_date, answers)
+		VALUES ($1, $2, $3, $4) RETURNING id`
 
-	err := s.DB.QueryRowContext(ctx, query, attendee.EventID, attendee.UserID).Scan(&attendee.ID)
+	tx, err := s.DB.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
-	return nil
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, query, attendee.EventID, attendee.UserID, attendee.OccurrenceDate, answers).Scan(&attendee.ID)
+	if err != nil {
+		return err
+	}
+	if err := recordAttendeeChange(ctx, tx, DomainAttendeeAdded, attendee); err != nil {
+		return err
+	}
+	if err := insertAudit(ctx, tx, actor, AuditEntityAttendee, attendee.ID, attendee.EventID, AuditCreate, nil, attendee); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
 func (s *AttendeeModel) Get(id int) (*Attendee, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
-	query := `SELECT id, event_id, user_id FROM attendees WHERE id = ?`
-	row := s.DB.QueryRowContext(ctx, query, id)
+	query := `
+		SELECT a.id, a.event_id, a.user_id, a.occurrence_date, a.checked_in_at, a.answers FROM attendees a
+		JOIN events e ON e.id = a.event_id
+		JOIN users u ON u.id = a.user_id
+		WHERE a.id = ? AND e.deleted_at IS NULL AND u.deleted_at IS NULL`
+	row := s.ReadDB.QueryRowContext(ctx, query, id)
 	var attendee Attendee
-	if err := row.Scan(&attendee.ID, &attendee.EventID, &attendee.UserID); err != nil {
+	if err := scanAttendee(row, &attendee); err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
 		}
@@ -46,15 +85,19 @@ func (s *AttendeeModel) Get(id int) (*Attendee, error) {
 	return &attendee, nil
 }
 
-func (s *AttendeeModel) GetByEventAndUserId(eventID, userID int) (*Attendee, error) {
+func (s *AttendeeModel) GetByEventAndUserId(eventID, userID int, occurrence string) (*Attendee, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	query := `SELECT id, event_id, user_id FROM attendees WHERE event_id = ? AND user_id = ?`
-	row := s.DB.QueryRowContext(ctx, query, eventID, userID)
+	query := `
+		SELECT a.id, a.event_id, a.user_id, a.occurrence_date, a.checked_in_at, a.answers FROM attendees a
+		JOIN events e ON e.id = a.event_id
+		JOIN users u ON u.id = a.user_id
+		WHERE a.event_id = ? AND a.user_id = ? AND a.occurrence_date = ? AND e.deleted_at IS NULL AND u.deleted_at IS NULL`
+	row := s.ReadDB.QueryRowContext(ctx, query, eventID, userID, occurrence)
 
 	var attendee Attendee
-	if err := row.Scan(&attendee.ID, &attendee.EventID, &attendee.UserID); err != nil {
+	if err := scanAttendee(row, &attendee); err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil // Attendee not found
 		}
@@ -63,12 +106,30 @@ func (s *AttendeeModel) GetByEventAndUserId(eventID, userID int) (*Attendee, err
 	return &attendee, nil
 }
 
-func (s *AttendeeModel) GetAttendeesByEvent(id int) ([]*User, error) {
+func scanAttendee(row rowScanner, attendee *Attendee) error {
+	var answers string
+	err := row.Scan(&attendee.ID, &attendee.EventID, &attendee.UserID, &attendee.OccurrenceDate, &attendee.CheckedInAt, &answers)
+	if err != nil {
+		return err
+	}
+	attendee.Answers, err = decodeAnswers(answers)
+	return err
+}
+
+// GetAttendeesByEvent lists the users attending an event. For recurring
+// events occurrence picks one instance; left empty, everyone attending
+// any instance is returned once.
+func (s *AttendeeModel) GetAttendeesByEvent(id int, occurrence string) ([]*User, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	query := `SELECT u.id, u.email, u.name FROM users u JOIN attendees a ON a.user_id = u.id WHERE a.event_id = $1`
-	rows, err := s.DB.QueryContext(ctx, query, id)
+	query := `
+		SELECT DISTINCT u.id, u.email, u.name FROM users u
+		JOIN attendees a ON a.user_id = u.id
+		JOIN events e ON e.id = a.event_id
+		WHERE a.event_id = $1 AND ($2 = '' OR a.occurrence_date = $2)
+		AND e.deleted_at IS NULL AND u.deleted_at IS NULL`
+	rows, err := s.ReadDB.QueryContext(ctx, query, id, occurrence)
 	if err != nil {
 		return nil, err
 	}
@@ -88,22 +149,138 @@ func (s *AttendeeModel) GetAttendeesByEvent(id int) ([]*User, error) {
 	return users, nil
 }
 
-func (s *AttendeeModel) Delete(attendeeID int) error {
+// Registration is one attendance of an event together with who signed
+// up and what they answered, as exported to the event's organizers.
+type Registration struct {
+	Attendee
+	Username string `json:"username"`
+	Email    string `json:"email"`
+}
+
+// GetRegistrations lists every attendance of an event, one per
+// occurrence for recurring events, oldest first. occurrence narrows it to
+// one instance.
+func (s *AttendeeModel) GetRegistrations(eventID int, occurrence string) ([]*Registration, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	query := `DELETE FROM attendees WHERE id = ?`
-	result, err := s.DB.ExecContext(ctx, query, attendeeID)
+	query := `
+		SELECT a.id, a.event_id, a.user_id, a.occurrence_date, a.checked_in_at, a.answers, u.name, u.email FROM attendees a
+		JOIN users u ON u.id = a.user_id
+		JOIN events e ON e.id = a.event_id
+		WHERE a.event_id = $1 AND ($2 = '' OR a.occurrence_date = $2)
+		AND e.deleted_at IS NULL AND u.deleted_at IS NULL
+		ORDER BY a.occurrence_date, a.id`
+	rows, err := s.ReadDB.QueryContext(ctx, query, eventID, occurrence)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	defer rows.Close()
+
+	registrations := []*Registration{}
+	for rows.Next() {
+		var registration Registration
+		var answers string
+		err := rows.Scan(&registration.ID, &registration.EventID, &registration.UserID, &registration.OccurrenceDate,
+			&registration.CheckedInAt, &answers, &registration.Username, &registration.Email)
+		if err != nil {
+			return nil, err
+		}
+		if registration.Answers, err = decodeAnswers(answers); err != nil {
+			return nil, err
+		}
+		registrations = append(registrations, &registration)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return registrations, nil
+}
+
+// IsAttending reports whether the user attends any occurrence of the event.
+func (s *AttendeeModel) IsAttending(eventID, userID int) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var exists bool
+	query := `SELECT EXISTS (SELECT 1 FROM attendees WHERE event_id = $1 AND user_id = $2)`
+	err := s.ReadDB.QueryRowContext(ctx, query, eventID, userID).Scan(&exists)
+	return exists, err
+}
+
+// CheckIn marks the attendee as checked in. It returns sql.ErrNoRows when
+// there is no such attendee or they were already checked in.
+func (s *AttendeeModel) CheckIn(actor Actor, attendee *Attendee) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
 
-	rowsAffected, err := result.RowsAffected()
+	tx, err := s.DB.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
-	if rowsAffected == 0 {
-		return sql.ErrNoRows // No attendee found to delete
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+	query := `UPDATE attendees SET checked_in_at = $1 WHERE id = $2 AND checked_in_at IS NULL`
+	if err := expectOneRow(tx.ExecContext(ctx, query, now, attendee.ID)); err != nil {
+		return err
+	}
+	checkedIn := *attendee
+	checkedIn.CheckedInAt = &now
+	if err := recordAttendeeChange(ctx, tx, DomainAttendeeCheckedIn, &checkedIn); err != nil {
+		return err
+	}
+	if err := insertAudit(ctx, tx, actor, AuditEntityAttendee, attendee.ID, attendee.EventID, AuditUpdate, attendee, &checkedIn); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
 	}
+	attendee.CheckedInAt = &now
 	return nil
 }
+
+// CountCheckIns counts the attendees of an event and how many of them
+// have checked in. For recurring events occurrence picks one instance;
+// left empty, every instance is counted.
+func (s *AttendeeModel) CountCheckIns(eventID int, occurrence string) (*CheckInCounts, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `
+		SELECT COUNT(a.checked_in_at), COUNT(*) FROM attendees a
+		JOIN users u ON u.id = a.user_id
+		WHERE a.event_id = $1 AND ($2 = '' OR a.occurrence_date = $2) AND u.deleted_at IS NULL`
+	var counts CheckInCounts
+	err := s.ReadDB.QueryRowContext(ctx, query, eventID, occurrence).Scan(&counts.CheckedIn, &counts.Total)
+	if err != nil {
+		return nil, err
+	}
+	return &counts, nil
+}
+
+func (s *AttendeeModel) Delete(actor Actor, attendeeID int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+		DELETE FROM attendees WHERE id = ?
+		RETURNING id, event_id, user_id, occurrence_date, checked_in_at, answers`
+	var attendee Attendee
+	if err := scanAttendee(tx.QueryRowContext(ctx, query, attendeeID), &attendee); err != nil {
+		return err // sql.ErrNoRows when there was no attendee to delete
+	}
+	if err := recordAttendeeChange(ctx, tx, DomainAttendeeRemoved, &attendee); err != nil {
+		return err
+	}
+	if err := insertAudit(ctx, tx, actor, AuditEntityAttendee, attendee.ID, attendee.EventID, AuditDelete, &attendee, nil); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,309 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+)
+
+const (
+	AuditEntityUser      = "user"
+	AuditEntityEvent     = "event"
+	AuditEntityAttendee  = "attendee"
+	AuditEntityOrganizer = "organizer"
+	AuditEntityComment   = "comment"
+	AuditEntityReview    = "review"
+)
+
+const (
+	AuditCreate  = "create"
+	AuditUpdate  = "update"
+	AuditDelete  = "delete"
+	AuditRestore = "restore"
+)
+
+type AuditModel struct {
+	DB     *sql.DB
+	ReadDB *sql.DB
+}
+
+// AuditEntry is one append-only record of a mutation. Diff maps every
+// changed field to its before and after value; creates have no before and
+// deletes have no after.
+type AuditEntry struct {
+	ID         int             `json:"id"`
+	ActorID    *int            `json:"actor_id"`
+	RequestID  string          `json:"request_id"`
+	EntityType string          `json:"entity_type"`
+	EntityID   int             `json:"entity_id"`
+	EventID    *int            `json:"event_id,omitempty"`
+	Action     string          `json:"action"`
+	Diff       json.RawMessage `json:"diff" swaggertype:"object"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+type AuditChange struct {
+	Before any `json:"before"`
+	After  any `json:"after"`
+}
+
+type AuditFilter struct {
+	EntityType string
+	EntityID   int
+	EventID    int
+	ActorID    int
+	Action     string
+	RequestID  string
+	Since      time.Time
+	Until      time.Time
+	Limit      int
+	Offset     int
+}
+
+// AuditDiff compares the JSON representations of before and after and
+// returns the fields that differ. Either side may be nil.
+func AuditDiff(before, after any) (json.RawMessage, error) {
+	beforeFields, err := jsonFields(before)
+	if err != nil {
+		return nil, err
+	}
+	afterFields, err := jsonFields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := map[string]AuditChange{}
+	for key, value := range beforeFields {
+		if other, ok := afterFields[key]; !ok || !reflect.DeepEqual(value, other) {
+			changes[key] = AuditChange{Before: value, After: afterFields[key]}
+		}
+	}
+	for key, value := range afterFields {
+		if _, ok := beforeFields[key]; !ok {
+			changes[key] = AuditChange{After: value}
+		}
+	}
+	return json.Marshal(changes)
+}
+
+func jsonFields(v any) (map[string]any, error) {
+	fields := map[string]any{}
+	if v == nil {
+		return fields, nil
+	}
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Pointer && rv.IsNil() {
+		return fields, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// Actor is who a mutation is recorded against in the audit log. The zero
+// UserID stands for the system itself, such as the purge job or the admin
+// CLI, which still name themselves through RequestID.
+type Actor struct {
+	UserID    int
+	RequestID string
+}
+
+// insertAudit appends an entry for a mutation to the audit log as part of
+// tx, so that it commits or rolls back together with the change. An
+// eventID of 0 keeps the entry out of every event's history.
+func insertAudit(ctx context.Context, tx *sql.Tx, actor Actor, entityType string, entityID, eventID int, action string, before, after any) error {
+	diff, err := AuditDiff(before, after)
+	if err != nil {
+		return err
+	}
+	var actorID, eventRef *int
+	if actor.UserID != 0 {
+		actorID = &actor.UserID
+	}
+	if eventID != 0 {
+		eventRef = &eventID
+	}
+	query := `
+		INSERT INTO audit_log (actor_id, request_id, entity_type, entity_id, event_id, action, diff, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+	_, err = tx.ExecContext(ctx, query, actorID, actor.RequestID, entityType, entityID, eventRef, action, string(diff), time.Now().UTC())
+	return err
+}
+
+// auditEventChange records action on an event, comparing before with the
+// event as it now stands in tx. Deletes have no after.
+func auditEventChange(ctx context.Context, tx *sql.Tx, actor Actor, action string, before *Event, eventID int) error {
+	var after *Event
+	if action != AuditDelete {
+		var err error
+		if after, err = eventInTx(ctx, tx, eventID); err != nil {
+			return err
+		}
+	}
+	return insertAudit(ctx, tx, actor, AuditEntityEvent, eventID, eventID, action, before, after)
+}
+
+// auditRemovedEvents records the deletion of every event matching where,
+// which must be called before they are deleted.
+func auditRemovedEvents(ctx context.Context, tx *sql.Tx, actor Actor, where string, args ...any) error {
+	rows, err := tx.QueryContext(ctx, `SELECT `+eventColumns+` FROM events e WHERE `+where, args...)
+	if err != nil {
+		return err
+	}
+	var events []*Event
+	for rows.Next() {
+		var event Event
+		if err := scanEvent(rows, &event); err != nil {
+			rows.Close()
+			return err
+		}
+		events = append(events, &event)
+	}
+	if err := closeRows(rows); err != nil {
+		return err
+	}
+	for _, event := range events {
+		if err := insertAudit(ctx, tx, actor, AuditEntityEvent, event.ID, event.ID, AuditDelete, event, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// auditRemovedAttendees records the removal of every attendee matching
+// where, which must be called before they are deleted.
+func auditRemovedAttendees(ctx context.Context, tx *sql.Tx, actor Actor, where string, args ...any) error {
+	rows, err := tx.QueryContext(ctx, `SELECT id, event_id, user_id, occurrence_date, checked_in_at, answers FROM attendees WHERE `+where, args...)
+	if err != nil {
+		return err
+	}
+	var attendees []*Attendee
+	for rows.Next() {
+		var attendee Attendee
+		if err := scanAttendee(rows, &attendee); err != nil {
+			rows.Close()
+			return err
+		}
+		attendees = append(attendees, &attendee)
+	}
+	if err := closeRows(rows); err != nil {
+		return err
+	}
+	for _, attendee := range attendees {
+		if err := insertAudit(ctx, tx, actor, AuditEntityAttendee, attendee.ID, attendee.EventID, AuditDelete, attendee, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// auditRemovedUsers records the deletion of every user matching where,
+// which must be called before they are deleted.
+func auditRemovedUsers(ctx context.Context, tx *sql.Tx, actor Actor, where string, args ...any) error {
+	rows, err := tx.QueryContext(ctx, `SELECT id, name, email, password, is_admin FROM users WHERE `+where, args...)
+	if err != nil {
+		return err
+	}
+	var users []*User
+	for rows.Next() {
+		var user User
+		if err := rows.Scan(&user.ID, &user.Username, &user.Email, &user.Password, &user.IsAdmin); err != nil {
+			rows.Close()
+			return err
+		}
+		users = append(users, &user)
+	}
+	if err := closeRows(rows); err != nil {
+		return err
+	}
+	for _, user := range users {
+		if err := insertAudit(ctx, tx, actor, AuditEntityUser, user.ID, 0, AuditDelete, user, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// closeRows closes rows that were read to the end and returns the error,
+// if any, that ended the iteration.
+func closeRows(rows *sql.Rows) error {
+	err := rows.Err()
+	rows.Close()
+	return err
+}
+
+// Find returns the entries matching filter, newest first.
+func (s *AuditModel) Find(filter AuditFilter) ([]*AuditEntry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var conditions []string
+	var args []any
+	add := func(condition string, arg any) {
+		conditions = append(conditions, condition)
+		args = append(args, arg)
+	}
+	if filter.EntityType != "" {
+		add("entity_type = ?", filter.EntityType)
+	}
+	if filter.EntityID != 0 {
+		add("entity_id = ?", filter.EntityID)
+	}
+	if filter.EventID != 0 {
+		add("event_id = ?", filter.EventID)
+	}
+	if filter.ActorID != 0 {
+		add("actor_id = ?", filter.ActorID)
+	}
+	if filter.Action != "" {
+		add("action = ?", filter.Action)
+	}
+	if filter.RequestID != "" {
+		add("request_id = ?", filter.RequestID)
+	}
+	if !filter.Since.IsZero() {
+		add("created_at >= ?", filter.Since.UTC())
+	}
+	if !filter.Until.IsZero() {
+		add("created_at < ?", filter.Until.UTC())
+	}
+
+	query := `SELECT id, actor_id, request_id, entity_type, entity_id, event_id, action, diff, created_at FROM audit_log`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	limit := filter.Limit
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+	query += " ORDER BY id DESC LIMIT ? OFFSET ?"
+	args = append(args, limit, filter.Offset)
+
+	rows, err := s.ReadDB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []*AuditEntry{}
+	for rows.Next() {
+		var entry AuditEntry
+		var diff string
+		if err := rows.Scan(&entry.ID, &entry.ActorID, &entry.RequestID, &entry.EntityType, &entry.EntityID, &entry.EventID, &entry.Action, &diff, &entry.CreatedAt); err != nil {
+			return nil, err
+		}
+		entry.Diff = json.RawMessage(diff)
+		entries = append(entries, &entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
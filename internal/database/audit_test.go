@@ -0,0 +1,133 @@
+package database
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAuditIsWrittenWithTheChange(t *testing.T) {
+	models := newTestModels(t)
+	actor := Actor{RequestID: "req-1"}
+
+	host := &User{Username: "host", Email: "host@example.com", Password: "x"}
+	if err := models.Users.Insert(actor, host); err != nil {
+		t.Fatal(err)
+	}
+	actor.UserID = host.ID
+	event := &Event{OwnerId: host.ID, Name: "Audit night", Description: "Every change leaves a trace", Date: "2026-11-05", Location: "Hawassa"}
+	if err := models.Events.Insert(actor, event); err != nil {
+		t.Fatal(err)
+	}
+
+	stale := *event
+	event.Name = "Audit evening"
+	if err := models.Events.Update(actor, event); err != nil {
+		t.Fatal(err)
+	}
+	stale.Name = "Lost update"
+	if err := models.Events.Update(actor, &stale); !errors.Is(err, ErrEditConflict) {
+		t.Fatalf("Update with a stale version = %v, want ErrEditConflict", err)
+	}
+
+	entries, err := models.Audit.Find(AuditFilter{EventID: event.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries for the event, want the create and one update", len(entries))
+	}
+	update := entries[0]
+	if update.Action != AuditUpdate || update.ActorID == nil || *update.ActorID != host.ID || update.RequestID != "req-1" {
+		t.Errorf("update entry = %+v, want an update by user %d in req-1", update, host.ID)
+	}
+	if diff := string(update.Diff); !strings.Contains(diff, `"Audit evening"`) || strings.Contains(diff, "Lost update") {
+		t.Errorf("update diff = %s, want only the committed rename", update.Diff)
+	}
+
+	signups, err := models.Audit.Find(AuditFilter{EntityType: AuditEntityUser, EntityID: host.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(signups) != 1 || signups[0].ActorID != nil {
+		t.Errorf("sign-up entries = %+v, want one without an actor", signups)
+	}
+}
+
+func TestPurgeAuditsWhatItRemoves(t *testing.T) {
+	models := newTestModels(t)
+	system := Actor{RequestID: "job-7"}
+
+	owner := &User{Username: "leaving", Email: "leaving@example.com", Password: "x"}
+	guest := &User{Username: "guest", Email: "guest@example.com", Password: "x"}
+	for _, user := range []*User{owner, guest} {
+		if err := models.Users.Insert(system, user); err != nil {
+			t.Fatal(err)
+		}
+	}
+	event := &Event{OwnerId: owner.ID, Name: "Farewell", Description: "The last one they host", Date: "2026-11-12", Location: "Gondar", Status: EventStatusPublished}
+	if err := models.Events.Insert(system, event); err != nil {
+		t.Fatal(err)
+	}
+	attendee := &Attendee{EventID: event.ID, UserID: guest.ID}
+	if err := models.Attendees.Insert(system, attendee); err != nil {
+		t.Fatal(err)
+	}
+	if err := models.Users.Delete(system, owner.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := models.Users.Purge(system, time.Now().Add(time.Minute))
+	if err != nil || n != 1 {
+		t.Fatalf("Purge = %d, %v, want 1 user", n, err)
+	}
+
+	for _, want := range []struct {
+		entityType string
+		entityID   int
+	}{
+		{AuditEntityUser, owner.ID},
+		{AuditEntityEvent, event.ID},
+		{AuditEntityAttendee, attendee.ID},
+	} {
+		entries, err := models.Audit.Find(AuditFilter{EntityType: want.entityType, EntityID: want.entityID, Action: AuditDelete, RequestID: "job-7"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The user was soft-deleted before, so it has a delete entry of its own.
+		if len(entries) == 0 {
+			t.Errorf("no delete entry for %s %d", want.entityType, want.entityID)
+		}
+		for _, entry := range entries {
+			if entry.ActorID != nil {
+				t.Errorf("purge entry for %s %d has actor %d, want none", want.entityType, want.entityID, *entry.ActorID)
+			}
+		}
+	}
+}
+
+func TestAuditLogIsAppendOnly(t *testing.T) {
+	models := newTestModels(t)
+	user := &User{Username: "auditor", Email: "auditor@example.com", Password: "x"}
+	if err := models.Users.Insert(Actor{RequestID: "req-1"}, user); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, query := range []string{
+		`UPDATE audit_log SET request_id = 'forged'`,
+		`DELETE FROM audit_log`,
+	} {
+		_, err := models.Audit.DB.Exec(query)
+		if err == nil || !strings.Contains(err.Error(), "append-only") {
+			t.Errorf("%s = %v, want the append-only error", query, err)
+		}
+	}
+	entries, err := models.Audit.Find(AuditFilter{RequestID: "req-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("got %d entries after the attempts, want the original one", len(entries))
+	}
+}
@@ -0,0 +1,207 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+const backupTimeLayout = "20060102T150405Z"
+
+// Backup copies the live database into dest through the SQLite online
+// backup API. It reads from the reader pool, so writers are not blocked
+// and the copy is a consistent snapshot of the last committed state.
+func (db *DB) Backup(ctx context.Context, dest string) error {
+	return copyDatabase(ctx, db.Reader, dest)
+}
+
+// VacuumInto writes a compacted copy of the database into dest. VACUUM
+// INTO is refused on query-only connections, so this goes through the
+// writer and holds it until the copy is done.
+func (db *DB) VacuumInto(ctx context.Context, dest string) error {
+	if _, err := os.Stat(dest); err == nil {
+		return fmt.Errorf("backup target %s already exists", dest)
+	}
+	_, err := db.Writer.ExecContext(ctx, `VACUUM INTO ?`, dest)
+	return err
+}
+
+// Restore verifies src and then copies it over the live database with the
+// backup API, so open connections see the restored pages instead of a file
+// swapped underneath them. The current state is saved to safetyCopy first.
+func (db *DB) Restore(ctx context.Context, src, safetyCopy string) error {
+	if err := Verify(ctx, src); err != nil {
+		return fmt.Errorf("refusing to restore %s: %w", src, err)
+	}
+	if safetyCopy != "" {
+		if err := db.Backup(ctx, safetyCopy); err != nil {
+			return fmt.Errorf("failed to save current database: %w", err)
+		}
+	}
+
+	source, err := sql.Open("sqlite3", "file:"+src+"?mode=ro")
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	srcConn, err := source.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer srcConn.Close()
+
+	dstConn, err := db.Writer.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer dstConn.Close()
+
+	return srcConn.Raw(func(srcDriver any) error {
+		return dstConn.Raw(func(dstDriver any) error {
+			return runBackup(dstDriver.(*sqlite3.SQLiteConn), srcDriver.(*sqlite3.SQLiteConn))
+		})
+	})
+}
+
+// Verify opens the file at path read-only and runs PRAGMA integrity_check.
+func Verify(ctx context.Context, path string) error {
+	if _, err := os.Stat(path); err != nil {
+		return err
+	}
+	db, err := sql.Open("sqlite3", "file:"+path+"?mode=ro")
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	rows, err := db.QueryContext(ctx, `PRAGMA integrity_check`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var problems []string
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			return err
+		}
+		if line != "ok" {
+			problems = append(problems, line)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("integrity check failed: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// BackupFileName returns the name used for rotated backups taken at t.
+func BackupFileName(prefix string, t time.Time) string {
+	return fmt.Sprintf("%s-%s.db", prefix, t.UTC().Format(backupTimeLayout))
+}
+
+// RotateBackups keeps the newest keep backups named by BackupFileName in
+// dir and removes the rest. It returns the paths that were removed.
+func RotateBackups(dir, prefix string, keep int) ([]string, error) {
+	if keep < 1 {
+		return nil, errors.New("keep must be at least 1")
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var backups []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, prefix+"-") || !strings.HasSuffix(name, ".db") {
+			continue
+		}
+		stamp := strings.TrimSuffix(strings.TrimPrefix(name, prefix+"-"), ".db")
+		if _, err := time.Parse(backupTimeLayout, stamp); err != nil {
+			continue
+		}
+		backups = append(backups, name)
+	}
+	// The timestamp layout sorts lexically in chronological order.
+	sort.Sort(sort.Reverse(sort.StringSlice(backups)))
+
+	var removed []string
+	for i := keep; i < len(backups); i++ {
+		path := filepath.Join(dir, backups[i])
+		if err := os.Remove(path); err != nil {
+			return removed, err
+		}
+		removed = append(removed, path)
+	}
+	return removed, nil
+}
+
+func copyDatabase(ctx context.Context, from *sql.DB, dest string) error {
+	if _, err := os.Stat(dest); err == nil {
+		return fmt.Errorf("backup target %s already exists", dest)
+	}
+
+	target, err := sql.Open("sqlite3", dest)
+	if err != nil {
+		return err
+	}
+	defer target.Close()
+
+	dstConn, err := target.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer dstConn.Close()
+
+	srcConn, err := from.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer srcConn.Close()
+
+	err = srcConn.Raw(func(srcDriver any) error {
+		return dstConn.Raw(func(dstDriver any) error {
+			return runBackup(dstDriver.(*sqlite3.SQLiteConn), srcDriver.(*sqlite3.SQLiteConn))
+		})
+	})
+	if err != nil {
+		return err
+	}
+	// The copied header still says WAL; switch back so the backup is a
+	// single self-contained file.
+	_, err = dstConn.ExecContext(ctx, `PRAGMA journal_mode = DELETE`)
+	return err
+}
+
+func runBackup(dst, src *sqlite3.SQLiteConn) error {
+	backup, err := dst.Backup("main", src, "main")
+	if err != nil {
+		return err
+	}
+	// Copying every page in one step keeps the snapshot consistent even
+	// while the writer keeps committing.
+	done, err := backup.Step(-1)
+	if err != nil {
+		backup.Close()
+		return err
+	}
+	if !done {
+		backup.Close()
+		return errors.New("backup did not complete")
+	}
+	return backup.Finish()
+}
@@ -0,0 +1,358 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrInvalidParent is returned when a reply names a comment that is not
+// on the same event or was deleted.
+var ErrInvalidParent = errors.New("parent comment not found")
+
+type CommentModel struct {
+	DB     *sql.DB
+	ReadDB *sql.DB
+}
+
+// Comment is a post in an event's discussion. Replies name the comment
+// they answer as ParentID and share the RootID of the thread's first
+// comment. A deleted comment keeps its place in the thread without its
+// body or author; DeletedBy tells moderators who removed it. UserID is
+// nil once the author's account is purged.
+type Comment struct {
+	ID        int        `json:"id"`
+	EventID   int        `json:"event_id"`
+	UserID    *int       `json:"user_id,omitempty"`
+	Username  string     `json:"username,omitempty"`
+	ParentID  *int       `json:"parent_id,omitempty"`
+	RootID    int        `json:"-"`
+	Body      string     `json:"body"`
+	CreatedAt time.Time  `json:"created_at"`
+	EditedAt  *time.Time `json:"edited_at,omitempty"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	DeletedBy *int       `json:"-"`
+	Replies   []*Comment `json:"replies,omitempty"`
+}
+
+// CommentReport is a complaint about a comment.
+type CommentReport struct {
+	ID         int        `json:"id"`
+	CommentID  int        `json:"comment_id"`
+	UserID     int        `json:"user_id"`
+	Reason     string     `json:"reason"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ResolvedAt *time.Time `json:"resolved_at,omitempty"`
+}
+
+// ReportedComment is a comment with the reports against it that no
+// moderator has dealt with yet.
+type ReportedComment struct {
+	Comment *Comment         `json:"comment"`
+	Reports []*CommentReport `json:"reports"`
+}
+
+// IsBy reports whether userID wrote comment.
+func (c *Comment) IsBy(userID int) bool {
+	return c.UserID != nil && *c.UserID == userID
+}
+
+const commentColumns = `c.id, c.event_id, c.user_id, COALESCE(u.name, ''), c.parent_id, c.root_id, c.body, c.created_at, c.edited_at, c.deleted_at, c.deleted_by`
+
+func scanComment(row rowScanner, comment *Comment) error {
+	err := row.Scan(&comment.ID, &comment.EventID, &comment.UserID, &comment.Username, &comment.ParentID, &comment.RootID,
+		&comment.Body, &comment.CreatedAt, &comment.EditedAt, &comment.DeletedAt, &comment.DeletedBy)
+	if err != nil {
+		return err
+	}
+	if comment.DeletedAt != nil {
+		comment.UserID, comment.Username, comment.Body = nil, "", ""
+	}
+	return nil
+}
+
+// Insert posts comment. A reply must answer a comment of the same event
+// that is not deleted, or Insert returns ErrInvalidParent.
+func (s *CommentModel) Insert(comment *Comment) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	comment.CreatedAt = time.Now().UTC()
+	var rootID *int
+	if comment.ParentID != nil {
+		var root int
+		query := `SELECT root_id FROM comments WHERE id = $1 AND event_id = $2 AND deleted_at IS NULL`
+		err := tx.QueryRowContext(ctx, query, *comment.ParentID, comment.EventID).Scan(&root)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrInvalidParent
+		}
+		if err != nil {
+			return err
+		}
+		rootID = &root
+	}
+	query := `
+		INSERT INTO comments (event_id, user_id, parent_id, root_id, body, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id`
+	err = tx.QueryRowContext(ctx, query, comment.EventID, comment.UserID, comment.ParentID, rootID, comment.Body, comment.CreatedAt).Scan(&comment.ID)
+	if err != nil {
+		return err
+	}
+	if rootID == nil {
+		// A thread's first comment is its own root.
+		if _, err := tx.ExecContext(ctx, `UPDATE comments SET root_id = id WHERE id = $1`, comment.ID); err != nil {
+			return err
+		}
+		rootID = &comment.ID
+	}
+	comment.RootID = *rootID
+	return tx.Commit()
+}
+
+// Get returns a comment of an event, deleted or not, or nil when there
+// is none.
+func (s *CommentModel) Get(eventID, id int) (*Comment, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var comment Comment
+	query := `SELECT ` + commentColumns + ` FROM comments c LEFT JOIN users u ON u.id = c.user_id WHERE c.id = $1 AND c.event_id = $2`
+	err := scanComment(s.ReadDB.QueryRowContext(ctx, query, id, eventID), &comment)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &comment, nil
+}
+
+// GetThreads returns a page of an event's threads, newest first, each as
+// its first comment with every reply nested under the comment it
+// answers, oldest first.
+func (s *CommentModel) GetThreads(eventID, limit, offset int) ([]*Comment, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	query := `
+		SELECT ` + commentColumns + ` FROM comments c LEFT JOIN users u ON u.id = c.user_id
+		WHERE c.event_id = $1 AND c.root_id IN (
+			SELECT id FROM comments WHERE event_id = $1 AND parent_id IS NULL
+			ORDER BY id DESC LIMIT $2 OFFSET $3
+		)
+		ORDER BY c.id`
+	rows, err := s.ReadDB.QueryContext(ctx, query, eventID, limit, max(offset, 0))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	byID := map[int]*Comment{}
+	threads := []*Comment{}
+	for rows.Next() {
+		var comment Comment
+		if err := scanComment(rows, &comment); err != nil {
+			return nil, err
+		}
+		byID[comment.ID] = &comment
+		if comment.ParentID == nil {
+			threads = append(threads, &comment)
+		} else if parent, ok := byID[*comment.ParentID]; ok {
+			parent.Replies = append(parent.Replies, &comment)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	// Rows come oldest first; threads are listed newest first.
+	for i, j := 0, len(threads)-1; i < j; i, j = i+1, j-1 {
+		threads[i], threads[j] = threads[j], threads[i]
+	}
+	return threads, nil
+}
+
+// UpdateBody changes the body of a comment that is not deleted, as long
+// as it was posted after editableSince.
+func (s *CommentModel) UpdateBody(actor Actor, comment *Comment, body string, editableSince time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+	query := `UPDATE comments SET body = $1, edited_at = $2 WHERE id = $3 AND deleted_at IS NULL AND created_at > $4`
+	if err := expectOneRow(tx.ExecContext(ctx, query, body, now, comment.ID, editableSince.UTC())); err != nil {
+		return err
+	}
+	edited := *comment
+	edited.Body = body
+	edited.EditedAt = &now
+	if err := insertAudit(ctx, tx, actor, AuditEntityComment, comment.ID, comment.EventID, AuditUpdate, comment, &edited); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	*comment = edited
+	return nil
+}
+
+// Delete soft-deletes a comment on behalf of actor, its author or a
+// moderator, and resolves the reports against it. Its replies stay.
+func (s *CommentModel) Delete(actor Actor, comment *Comment) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+	query := `UPDATE comments SET deleted_at = $1, deleted_by = $2 WHERE id = $3 AND deleted_at IS NULL`
+	if err := expectOneRow(tx.ExecContext(ctx, query, now, actor.UserID, comment.ID)); err != nil {
+		return err
+	}
+	if err := resolveReports(ctx, tx, comment.ID, now); err != nil {
+		return err
+	}
+	if err := insertAudit(ctx, tx, actor, AuditEntityComment, comment.ID, comment.EventID, AuditDelete, comment, nil); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Report files report against its comment. Reporting a comment again
+// replaces the reason given before and reopens the report.
+func (s *CommentModel) Report(report *CommentReport) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	report.CreatedAt = time.Now().UTC()
+	query := `
+		INSERT INTO comment_reports (comment_id, user_id, reason, created_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (comment_id, user_id) DO UPDATE SET reason = excluded.reason, created_at = excluded.created_at, resolved_at = NULL
+		RETURNING id`
+	return s.DB.QueryRowContext(ctx, query, report.CommentID, report.UserID, report.Reason, report.CreatedAt).Scan(&report.ID)
+}
+
+// GetReported lists the comments of an event that are not deleted and
+// have open reports, most reported first.
+func (s *CommentModel) GetReported(eventID int) ([]*ReportedComment, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `
+		SELECT ` + commentColumns + `, r.id, r.user_id, r.reason, r.created_at
+		FROM comment_reports r
+		JOIN comments c ON c.id = r.comment_id AND c.event_id = $1 AND c.deleted_at IS NULL
+		LEFT JOIN users u ON u.id = c.user_id
+		WHERE r.resolved_at IS NULL
+		ORDER BY (SELECT COUNT(*) FROM comment_reports o WHERE o.comment_id = c.id AND o.resolved_at IS NULL) DESC, c.id, r.id`
+	rows, err := s.ReadDB.QueryContext(ctx, query, eventID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reported := []*ReportedComment{}
+	var current *ReportedComment
+	for rows.Next() {
+		var comment Comment
+		report := CommentReport{}
+		err := rows.Scan(&comment.ID, &comment.EventID, &comment.UserID, &comment.Username, &comment.ParentID, &comment.RootID,
+			&comment.Body, &comment.CreatedAt, &comment.EditedAt, &comment.DeletedAt, &comment.DeletedBy,
+			&report.ID, &report.UserID, &report.Reason, &report.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		report.CommentID = comment.ID
+		if current == nil || current.Comment.ID != comment.ID {
+			current = &ReportedComment{Comment: &comment}
+			reported = append(reported, current)
+		}
+		current.Reports = append(current.Reports, &report)
+	}
+	return reported, rows.Err()
+}
+
+// DismissReports resolves the open reports against a comment, leaving it
+// in place.
+func (s *CommentModel) DismissReports(commentID int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := resolveReports(ctx, tx, commentID, time.Now().UTC()); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func resolveReports(ctx context.Context, tx *sql.Tx, commentID int, at time.Time) error {
+	query := `UPDATE comment_reports SET resolved_at = $1 WHERE comment_id = $2 AND resolved_at IS NULL`
+	_, err := tx.ExecContext(ctx, query, at, commentID)
+	return err
+}
+
+// Participants returns the IDs of the users named in names who organize
+// or attend the event, matching names without regard to case. Names
+// shared by several participants mention all of them.
+func (s *CommentModel) Participants(eventID int, names []string) ([]int, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	args := []any{eventID}
+	placeholders := make([]string, len(names))
+	for i, name := range names {
+		placeholders[i] = fmt.Sprintf("?%d", i+2)
+		args = append(args, strings.ToLower(name))
+	}
+	query := `
+		SELECT u.id FROM users u
+		WHERE u.deleted_at IS NULL AND lower(u.name) IN (` + strings.Join(placeholders, ", ") + `)
+		AND (EXISTS (SELECT 1 FROM attendees a WHERE a.event_id = ?1 AND a.user_id = u.id)
+			OR EXISTS (SELECT 1 FROM event_organizers o WHERE o.event_id = ?1 AND o.user_id = u.id))`
+	rows, err := s.ReadDB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
@@ -3,11 +3,105 @@ package database
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
 	"time"
 )
 
+// ErrEditConflict is returned by Update when the row was changed by
+// someone else since the caller read it.
+var ErrEditConflict = errors.New("edit conflict")
+
+// Event statuses. New events start as drafts, which only their owner can
+// see; see CanTransitionEvent for the allowed moves between them.
+const (
+	EventStatusDraft     = "draft"
+	EventStatusPublished = "published"
+	EventStatusCancelled = "cancelled"
+	EventStatusCompleted = "completed"
+)
+
+// Event visibilities. Public events are listed for everyone, unlisted
+// ones can be read by anyone who knows their ID, and private ones only by
+// their owner, their attendees and holders of a valid invite.
+const (
+	EventVisibilityPublic   = "public"
+	EventVisibilityUnlisted = "unlisted"
+	EventVisibilityPrivate  = "private"
+)
+
+var eventTransitions = map[string][]string{
+	EventStatusDraft:     {EventStatusPublished},
+	EventStatusPublished: {EventStatusCancelled, EventStatusCompleted},
+}
+
+// CanTransitionEvent reports whether an event may move from one status to
+// another. Cancelled and completed are final.
+func CanTransitionEvent(from, to string) bool {
+	for _, next := range eventTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+const eventColumns = `e.id, e.owner_id, e.name, e.description, e.date, e.location, e.latitude, e.longitude, e.rrule, e.exdates, e.status, e.visibility, e.version, e.deleted_at,
+	(SELECT group_concat(t.name, ',') FROM event_tags et JOIN tags t ON t.id = et.tag_id WHERE et.event_id = e.id),
+	(SELECT AVG(r.rating) FROM reviews r WHERE r.event_id = e.id), (SELECT COUNT(*) FROM reviews r WHERE r.event_id = e.id)`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanEvent(row rowScanner, event *Event) error {
+	var exdates string
+	var tags sql.NullString
+	var average sql.NullFloat64
+	var reviews int
+	err := row.Scan(&event.ID, &event.OwnerId, &event.Name, &event.Description, &event.Date, &event.Location, &event.Latitude, &event.Longitude, &event.RRule, &exdates, &event.Status, &event.Visibility, &event.Version, &event.DeletedAt, &tags, &average, &reviews)
+	if err != nil {
+		return err
+	}
+	event.ExDates = splitDates(exdates)
+	event.Tags = splitTags(tags.String)
+	if reviews > 0 {
+		event.Rating = &RatingSummary{Average: math.Round(average.Float64*100) / 100, Count: reviews}
+	}
+	// The driver parses DATETIME columns and hands them back as RFC 3339
+	// strings; keep the date-only layout the API accepts on write.
+	if t, err := time.Parse(time.RFC3339, event.Date); err == nil {
+		event.Date = t.Format(time.DateOnly)
+	}
+	return nil
+}
+
+// eventInTx returns the event as it stands in tx, soft-deleted or not.
+func eventInTx(ctx context.Context, tx *sql.Tx, id int) (*Event, error) {
+	var event Event
+	err := scanEvent(tx.QueryRowContext(ctx, `SELECT `+eventColumns+` FROM events e WHERE e.id = $1`, id), &event)
+	if err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
+// eventBeforeChange reads the event in tx ahead of a versioned change, for
+// its audit entry. An event that is gone is an ErrEditConflict, as the
+// change itself would have been.
+func eventBeforeChange(ctx context.Context, tx *sql.Tx, id int) (*Event, error) {
+	event, err := eventInTx(ctx, tx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrEditConflict
+	}
+	return event, err
+}
+
 type EventModel struct {
-	DB *sql.DB
+	DB     *sql.DB
+	ReadDB *sql.DB
 }
 
 type Event struct {
@@ -17,32 +111,119 @@ type Event struct {
 	Description string `json:"description" binding:"required,min=10,max=500"`
 	Date        string `json:"date" binding:"required,datetime=2006-01-02"`
 	Location    string `json:"location" binding:"required,min=3,max=100"`
+	// Latitude and Longitude are optional WGS 84 coordinates of Location;
+	// either both are set or neither is.
+	Latitude  *float64 `json:"latitude,omitempty" binding:"required_with=Longitude,omitempty,min=-90,max=90"`
+	Longitude *float64 `json:"longitude,omitempty" binding:"required_with=Latitude,omitempty,min=-180,max=180"`
+	// RRule is an optional RFC 5545 recurrence rule such as
+	// "FREQ=WEEKLY;BYDAY=TU;COUNT=10". Date is the first occurrence.
+	RRule   string   `json:"rrule,omitempty" binding:"omitempty,rrule"`
+	ExDates []string `json:"exdates,omitempty" binding:"omitempty,max=366,dive,datetime=2006-01-02"`
+	// Status is changed through the transition endpoints only.
+	Status     string `json:"status" binding:"-"`
+	Visibility string `json:"visibility" binding:"omitempty,oneof=public unlisted private"`
+	Version    int    `json:"version"`
+	// Tags are managed through TagModel.SetForEvent and are read-only here.
+	Tags []string `json:"tags,omitempty" binding:"-"`
+	// DeletedAt is only set on events read through GetDeletedByID; every
+	// other query hides soft-deleted rows.
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	// Rating sums up the event's reviews; it is nil until it has one.
+	Rating *RatingSummary `json:"rating,omitempty" binding:"-"`
 }
 
-func (s *EventModel) Insert(event *Event) error {
+func (s *EventModel) Insert(actor Actor, event *Event) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
+	if event.Status == "" {
+		event.Status = EventStatusDraft
+	}
+	if event.Visibility == "" {
+		event.Visibility = EventVisibilityPublic
+	}
 	query := `
-		INSERT INTO events (owner_id, name, description, date, location)
-		VALUES ($1, $2, $3, $4, $5)
-		RETURNING id`
+		INSERT INTO events (owner_id, name, description, date, location, latitude, longitude, rrule, exdates, status, visibility)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		RETURNING id, version`
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
 
-	return s.DB.QueryRowContext(ctx, query,
+	err = tx.QueryRowContext(ctx, query,
 		event.OwnerId,
 		event.Name,
 		event.Description,
 		event.Date,
 		event.Location,
-	).Scan(&event.ID)
+		event.Latitude,
+		event.Longitude,
+		event.RRule,
+		joinDates(event.ExDates),
+		event.Status,
+		event.Visibility,
+	).Scan(&event.ID, &event.Version)
+	if err != nil {
+		return err
+	}
+	if err := insertOwner(ctx, tx, event.ID, event.OwnerId); err != nil {
+		return err
+	}
+	if err := recordEventChange(ctx, tx, DomainEventCreated, event.ID); err != nil {
+		return err
+	}
+	if err := auditEventChange(ctx, tx, actor, AuditCreate, nil, event.ID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// EventFilter narrows the event listings. The zero value matches every
+// event that is not soft-deleted.
+type EventFilter struct {
+	// ViewerID is the user asking. Drafts are only listed for their
+	// organizers; unlisted and private events for their organizers and
+	// attendees.
+	ViewerID int
+	// Status keeps only events in this status.
+	Status string
+	// Tags keeps events carrying every one of these normalized tags.
+	Tags []string
 }
 
-func (s *EventModel) GetAll() ([]*Event, error) {
+func (f EventFilter) where() (string, []any) {
+	conditions := []string{"e.deleted_at IS NULL", `(EXISTS (SELECT 1 FROM event_organizers o WHERE o.event_id = e.id AND o.user_id = ?)
+		OR (e.status <> 'draft' AND (e.visibility = 'public'
+		OR EXISTS (SELECT 1 FROM attendees a WHERE a.event_id = e.id AND a.user_id = ?))))`}
+	args := []any{f.ViewerID, f.ViewerID}
+	if f.Status != "" {
+		conditions = append(conditions, "e.status = ?")
+		args = append(args, f.Status)
+	}
+	if len(f.Tags) > 0 {
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(f.Tags)), ", ")
+		conditions = append(conditions, `e.id IN (
+			SELECT et.event_id FROM event_tags et JOIN tags t ON t.id = et.tag_id
+			WHERE t.name IN (`+placeholders+`)
+			GROUP BY et.event_id HAVING COUNT(DISTINCT t.name) = ?)`)
+		for _, tag := range f.Tags {
+			args = append(args, tag)
+		}
+		args = append(args, len(f.Tags))
+	}
+	return strings.Join(conditions, " AND "), args
+}
+
+func (s *EventModel) GetAll(filter EventFilter) ([]*Event, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	query := "SELECT * FROM events"
-	row, err := s.DB.QueryContext(ctx, query)
+	where, args := filter.where()
+	query := "SELECT " + eventColumns + " FROM events e WHERE " + where
+	row, err := s.ReadDB.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -51,7 +232,7 @@ func (s *EventModel) GetAll() ([]*Event, error) {
 	events := []*Event{}
 	for row.Next() {
 		var event Event
-		err = row.Scan(&event.ID, &event.OwnerId, &event.Name, &event.Description, &event.Date, &event.Location)
+		err = scanEvent(row, &event)
 		if err != nil {
 			return nil, err
 		}
@@ -67,10 +248,10 @@ func (s *EventModel) GetByID(id int) (*Event, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	query := `SELECT id, owner_id, name, description, date, location FROM events WHERE id = $1`
+	query := `SELECT ` + eventColumns + ` FROM events e WHERE e.id = $1 AND e.deleted_at IS NULL`
 
 	event := Event{}
-	err := s.DB.QueryRowContext(ctx, query, id).Scan(&event.ID, &event.OwnerId, &event.Name, &event.Description, &event.Date, &event.Location)
+	err := scanEvent(s.ReadDB.QueryRowContext(ctx, query, id), &event)
 	if err != nil {
 		return nil, err
 	}
@@ -78,46 +259,339 @@ func (s *EventModel) GetByID(id int) (*Event, error) {
 	return &event, nil
 }
 
-func (s *EventModel) Update(event *Event) error {
+// GetDeletedByID returns an event only if it is soft-deleted.
+func (s *EventModel) GetDeletedByID(id int) (*Event, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	query := "UPDATE events SET  name = $1, description = $2, date = $3, location = $4 WHERE id = $5"
+	query := `SELECT ` + eventColumns + ` FROM events e WHERE e.id = $1 AND e.deleted_at IS NOT NULL`
 
-	_, err := s.DB.ExecContext(ctx, query, event.Name, event.Description, event.Date, event.Location, event.ID)
+	event := Event{}
+	err := scanEvent(s.ReadDB.QueryRowContext(ctx, query, id), &event)
+	if err != nil {
+		return nil, err
+	}
+
+	return &event, nil
+}
+
+// Update saves event, failing with ErrEditConflict when it changed since
+// it was read. Reminders need no rescheduling when the date moves: they
+// are worked out from the current date each time they are due.
+func (s *EventModel) Update(actor Actor, event *Event) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `
+		UPDATE events SET name = $1, description = $2, date = $3, location = $4, latitude = $5, longitude = $6, rrule = $7, exdates = $8, visibility = $9, version = version + 1
+		WHERE id = $10 AND version = $11 AND deleted_at IS NULL
+		RETURNING version`
+
+	tx, err := s.DB.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
-	return nil
+	defer tx.Rollback()
+
+	before, err := eventBeforeChange(ctx, tx, event.ID)
+	if err != nil {
+		return err
+	}
+
+	err = tx.QueryRowContext(ctx, query, event.Name, event.Description, event.Date, event.Location, event.Latitude, event.Longitude, event.RRule, joinDates(event.ExDates), event.Visibility, event.ID, event.Version).Scan(&event.Version)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrEditConflict
+		}
+		return err
+	}
+	if err := recordEventChange(ctx, tx, DomainEventUpdated, event.ID); err != nil {
+		return err
+	}
+	if err := auditEventChange(ctx, tx, actor, AuditUpdate, before, event.ID); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
-func (s *EventModel) Delete(id int) error {
+// SetStatus moves event to status with the same version check as Update.
+// Callers check the move with CanTransitionEvent first.
+func (s *EventModel) SetStatus(actor Actor, event *Event, status string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	query := `DELETE FROM events WHERE id = $1`
-	res, err := s.DB.ExecContext(ctx, query, id)
+	query := `
+		UPDATE events SET status = $1, version = version + 1
+		WHERE id = $2 AND version = $3 AND deleted_at IS NULL
+		RETURNING version`
+
+	tx, err := s.DB.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
 
-	rowsAffected, err := res.RowsAffected()
+	before, err := eventBeforeChange(ctx, tx, event.ID)
 	if err != nil {
 		return err
 	}
 
-	if rowsAffected == 0 {
-		return sql.ErrNoRows
+	err = tx.QueryRowContext(ctx, query, status, event.ID, event.Version).Scan(&event.Version)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrEditConflict
+		}
+		return err
+	}
+	if err := recordEventChange(ctx, tx, DomainEventUpdated, event.ID); err != nil {
+		return err
 	}
+	if err := auditEventChange(ctx, tx, actor, AuditUpdate, before, event.ID); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	event.Status = status
 	return nil
 }
 
+// UpdateFields writes only the given columns of event, with the same
+// version check as Update. Columns outside the editable set are rejected.
+func (s *EventModel) UpdateFields(actor Actor, event *Event, columns []string) error {
+	if len(columns) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	assignments := make([]string, 0, len(columns)+1)
+	args := make([]any, 0, len(columns)+2)
+	for _, column := range columns {
+		value, ok := editableEventColumn(event, column)
+		if !ok {
+			return fmt.Errorf("column %q cannot be updated", column)
+		}
+		args = append(args, value)
+		assignments = append(assignments, fmt.Sprintf("%s = $%d", column, len(args)))
+	}
+	assignments = append(assignments, "version = version + 1")
+	args = append(args, event.ID, event.Version)
+
+	query := fmt.Sprintf(`UPDATE events SET %s WHERE id = $%d AND version = $%d AND deleted_at IS NULL RETURNING version`,
+		strings.Join(assignments, ", "), len(args)-1, len(args))
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := eventBeforeChange(ctx, tx, event.ID)
+	if err != nil {
+		return err
+	}
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&event.Version)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrEditConflict
+		}
+		return err
+	}
+	if err := recordEventChange(ctx, tx, DomainEventUpdated, event.ID); err != nil {
+		return err
+	}
+	if err := auditEventChange(ctx, tx, actor, AuditUpdate, before, event.ID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ChangedEventColumns lists the editable columns whose values differ
+// between before and after.
+func ChangedEventColumns(before, after *Event) []string {
+	var columns []string
+	for _, column := range []string{"name", "description", "date", "location", "latitude", "longitude", "rrule", "exdates", "visibility"} {
+		old, _ := editableEventColumn(before, column)
+		updated, _ := editableEventColumn(after, column)
+		if old != updated {
+			columns = append(columns, column)
+		}
+	}
+	return columns
+}
+
+func editableEventColumn(event *Event, column string) (any, bool) {
+	switch column {
+	case "name":
+		return event.Name, true
+	case "description":
+		return event.Description, true
+	case "date":
+		return event.Date, true
+	case "location":
+		return event.Location, true
+	case "latitude":
+		return nullFloat(event.Latitude), true
+	case "longitude":
+		return nullFloat(event.Longitude), true
+	case "rrule":
+		return event.RRule, true
+	case "exdates":
+		return joinDates(event.ExDates), true
+	case "visibility":
+		return event.Visibility, true
+	}
+	return nil, false
+}
+
+// nullFloat turns an optional coordinate into a comparable column value.
+func nullFloat(f *float64) sql.NullFloat64 {
+	if f == nil {
+		return sql.NullFloat64{}
+	}
+	return sql.NullFloat64{Float64: *f, Valid: true}
+}
+
+// Delete soft-deletes the event, failing with ErrEditConflict when it is
+// no longer at version.
+func (s *EventModel) Delete(actor Actor, id, version int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := eventBeforeChange(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+
+	query := `UPDATE events SET deleted_at = $1 WHERE id = $2 AND version = $3 AND deleted_at IS NULL`
+	if err := expectOneRow(tx.ExecContext(ctx, query, time.Now().UTC(), id, version)); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrEditConflict
+		}
+		return err
+	}
+	if err := recordEventChange(ctx, tx, DomainEventDeleted, id); err != nil {
+		return err
+	}
+	if err := auditEventChange(ctx, tx, actor, AuditDelete, before, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// CancellationRecipients lists the attendees of a cancelled event who keep
+// email notifications on.
+func (s *EventModel) CancellationRecipients(eventID int) ([]Recipient, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	return emailRecipients(ctx, s.ReadDB, eventID)
+}
+
+// QueueCancellationEmails queues jobs, one email per recipient, and records
+// that the cancellation was emailed, all at once. It queues nothing when
+// it was already emailed, so a retried job never sends twice.
+func (s *EventModel) QueueCancellationEmails(eventID int, jobs []*Job) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE events SET cancellation_emailed_at = $1 WHERE id = $2 AND cancellation_emailed_at IS NULL`
+	err = expectOneRow(tx.ExecContext(ctx, query, time.Now().UTC(), eventID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, job := range jobs {
+		if err := insertJob(ctx, tx, job); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Restore brings back an event that was soft-deleted at or after since.
+func (s *EventModel) Restore(actor Actor, id int, since time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := eventInTx(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+
+	query := `UPDATE events SET deleted_at = NULL, version = version + 1 WHERE id = $1 AND deleted_at >= $2`
+	if err := expectOneRow(tx.ExecContext(ctx, query, id, since.UTC())); err != nil {
+		return err
+	}
+	if err := recordEventChange(ctx, tx, DomainEventUpdated, id); err != nil {
+		return err
+	}
+	if err := auditEventChange(ctx, tx, actor, AuditRestore, before, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Purge permanently removes events soft-deleted before cutoff. Their
+// attendees go with them through ON DELETE CASCADE; both are audited as
+// deleted on behalf of actor.
+func (s *EventModel) Purge(actor Actor, cutoff time.Time) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	purged := `SELECT id FROM events WHERE deleted_at IS NOT NULL AND deleted_at < $1`
+	if err := auditRemovedAttendees(ctx, tx, actor, `event_id IN (`+purged+`)`, cutoff.UTC()); err != nil {
+		return 0, err
+	}
+	if err := auditRemovedEvents(ctx, tx, actor, `e.id IN (`+purged+`)`, cutoff.UTC()); err != nil {
+		return 0, err
+	}
+	res, err := tx.ExecContext(ctx, `DELETE FROM events WHERE id IN (`+purged+`)`, cutoff.UTC())
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return n, tx.Commit()
+}
+
 func (s *EventModel) GetByAttendeeId(attendeeId int) ([]*Event, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	query := `SELECT e.id, e.owner_id, e.name, e.description, e.date, e.location FROM events e JOIN attendees a ON a.event_id = e.id WHERE a.id = $1`
-	rows, err := s.DB.QueryContext(ctx, query, attendeeId)
+	query := `SELECT ` + eventColumns + ` FROM events e JOIN attendees a ON a.event_id = e.id WHERE a.id = $1 AND e.deleted_at IS NULL`
+	rows, err := s.ReadDB.QueryContext(ctx, query, attendeeId)
 	if err != nil {
 		return nil, err
 	}
@@ -126,7 +600,7 @@ func (s *EventModel) GetByAttendeeId(attendeeId int) ([]*Event, error) {
 	var events []*Event
 	for rows.Next() {
 		var event Event
-		if err := rows.Scan(&event.ID, &event.OwnerId, &event.Name, &event.Description, &event.Date, &event.Location); err != nil {
+		if err := scanEvent(rows, &event); err != nil {
 			return nil, err
 		}
 		events = append(events, &event)
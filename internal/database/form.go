@@ -0,0 +1,255 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Question types of a registration form.
+const (
+	QuestionText     = "text"
+	QuestionSelect   = "select"
+	QuestionCheckbox = "checkbox"
+)
+
+const (
+	MaxQuestionsPerForm  = 30
+	DefaultAnswerLength  = 500
+	maxQuestionOptions   = 50
+	maxAnswerLengthLimit = 2000
+)
+
+var questionKey = regexp.MustCompile(`^[a-z][a-z0-9_]{0,39}$`)
+
+type FormModel struct {
+	DB     *sql.DB
+	ReadDB *sql.DB
+}
+
+// Question is one field of an event's registration form. Text answers are
+// strings of at most MaxLength characters (DefaultAnswerLength when 0)
+// that match Pattern, if set; select answers are one of Options; checkbox
+// answers are booleans, and a required checkbox must be ticked.
+type Question struct {
+	Key       string   `json:"key" binding:"required"`
+	Label     string   `json:"label" binding:"required,max=200"`
+	Type      string   `json:"type" binding:"required,oneof=text select checkbox"`
+	Required  bool     `json:"required"`
+	Options   []string `json:"options,omitempty"`
+	MaxLength int      `json:"max_length,omitempty" binding:"min=0"`
+	Pattern   string   `json:"pattern,omitempty"`
+}
+
+// Answers maps question keys to an attendee's answers.
+type Answers map[string]any
+
+// ValidateQuestions checks a form before it is stored.
+func ValidateQuestions(questions []Question) error {
+	if len(questions) > MaxQuestionsPerForm {
+		return fmt.Errorf("a form can have at most %d questions", MaxQuestionsPerForm)
+	}
+	seen := map[string]bool{}
+	for _, q := range questions {
+		if !questionKey.MatchString(q.Key) {
+			return fmt.Errorf("question key %q must be lowercase letters, digits and underscores, starting with a letter", q.Key)
+		}
+		if seen[q.Key] {
+			return fmt.Errorf("question key %q is used twice", q.Key)
+		}
+		seen[q.Key] = true
+
+		switch q.Type {
+		case QuestionSelect:
+			if len(q.Options) == 0 || len(q.Options) > maxQuestionOptions {
+				return fmt.Errorf("select question %q needs between 1 and %d options", q.Key, maxQuestionOptions)
+			}
+			options := map[string]bool{}
+			for _, option := range q.Options {
+				if strings.TrimSpace(option) == "" || options[option] {
+					return fmt.Errorf("select question %q has an empty or repeated option", q.Key)
+				}
+				options[option] = true
+			}
+		case QuestionText:
+			if q.MaxLength > maxAnswerLengthLimit {
+				return fmt.Errorf("question %q cannot allow more than %d characters", q.Key, maxAnswerLengthLimit)
+			}
+			if q.Pattern != "" {
+				if _, err := regexp.Compile(q.Pattern); err != nil {
+					return fmt.Errorf("question %q has an invalid pattern: %w", q.Key, err)
+				}
+			}
+		}
+		if q.Type != QuestionSelect && len(q.Options) > 0 {
+			return fmt.Errorf("only select questions take options (question %q)", q.Key)
+		}
+		if q.Type != QuestionText && (q.Pattern != "" || q.MaxLength != 0) {
+			return fmt.Errorf("only text questions take a pattern or max_length (question %q)", q.Key)
+		}
+	}
+	return nil
+}
+
+// ValidateAnswers checks answers against the form and returns them
+// cleaned up: text is trimmed and unanswered optional questions are
+// dropped. Keys that are not in the form are rejected.
+func ValidateAnswers(questions []Question, answers Answers) (Answers, error) {
+	known := map[string]bool{}
+	for _, q := range questions {
+		known[q.Key] = true
+	}
+	for key := range answers {
+		if !known[key] {
+			return nil, fmt.Errorf("%q is not a question of this event", key)
+		}
+	}
+
+	cleaned := Answers{}
+	for _, q := range questions {
+		value, ok := answers[q.Key]
+		if ok && value == nil {
+			ok = false
+		}
+		switch q.Type {
+		case QuestionText:
+			text, isString := value.(string)
+			if ok && !isString {
+				return nil, fmt.Errorf("%s must be text", q.Label)
+			}
+			text = strings.TrimSpace(text)
+			if text == "" {
+				if q.Required {
+					return nil, fmt.Errorf("%s is required", q.Label)
+				}
+				continue
+			}
+			limit := q.MaxLength
+			if limit == 0 {
+				limit = DefaultAnswerLength
+			}
+			if utf8.RuneCountInString(text) > limit {
+				return nil, fmt.Errorf("%s must be at most %d characters", q.Label, limit)
+			}
+			if q.Pattern != "" && !regexp.MustCompile(q.Pattern).MatchString(text) {
+				return nil, fmt.Errorf("%s is not in the expected format", q.Label)
+			}
+			cleaned[q.Key] = text
+		case QuestionSelect:
+			choice, isString := value.(string)
+			if ok && !isString {
+				return nil, fmt.Errorf("%s must be one of its options", q.Label)
+			}
+			if choice == "" {
+				if q.Required {
+					return nil, fmt.Errorf("%s is required", q.Label)
+				}
+				continue
+			}
+			valid := false
+			for _, option := range q.Options {
+				valid = valid || option == choice
+			}
+			if !valid {
+				return nil, fmt.Errorf("%s must be one of: %s", q.Label, strings.Join(q.Options, ", "))
+			}
+			cleaned[q.Key] = choice
+		case QuestionCheckbox:
+			checked, isBool := value.(bool)
+			if ok && !isBool {
+				return nil, fmt.Errorf("%s must be true or false", q.Label)
+			}
+			if q.Required && !checked {
+				return nil, fmt.Errorf("%s must be checked", q.Label)
+			}
+			if ok {
+				cleaned[q.Key] = checked
+			}
+		}
+	}
+	return cleaned, nil
+}
+
+// Get returns the registration form of an event; events without one have
+// no questions.
+func (s *FormModel) Get(eventID int) ([]Question, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return findForm(ctx, s.ReadDB, eventID)
+}
+
+func findForm(ctx context.Context, db queryRower, eventID int) ([]Question, error) {
+	var raw string
+	err := db.QueryRowContext(ctx, `SELECT questions FROM event_forms WHERE event_id = $1`, eventID).Scan(&raw)
+	if errors.Is(err, sql.ErrNoRows) {
+		return []Question{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	questions := []Question{}
+	if err := json.Unmarshal([]byte(raw), &questions); err != nil {
+		return nil, err
+	}
+	return questions, nil
+}
+
+// Set replaces the registration form of an event. Answers already given
+// are kept as they were.
+func (s *FormModel) Set(actor Actor, eventID int, questions []Question) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	raw, err := json.Marshal(questions)
+	if err != nil {
+		return err
+	}
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := findForm(ctx, tx, eventID)
+	if err != nil {
+		return err
+	}
+	query := `
+		INSERT INTO event_forms (event_id, questions, updated_at) VALUES ($1, $2, $3)
+		ON CONFLICT (event_id) DO UPDATE SET questions = excluded.questions, updated_at = excluded.updated_at`
+	if _, err := tx.ExecContext(ctx, query, eventID, string(raw), time.Now().UTC()); err != nil {
+		return err
+	}
+	err = insertAudit(ctx, tx, actor, AuditEntityEvent, eventID, eventID, AuditUpdate,
+		map[string]any{"form": before}, map[string]any{"form": questions})
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// encodeAnswers turns answers into their column value; no answers are
+// stored as an empty string.
+func encodeAnswers(answers Answers) (string, error) {
+	if len(answers) == 0 {
+		return "", nil
+	}
+	raw, err := json.Marshal(answers)
+	return string(raw), err
+}
+
+func decodeAnswers(raw string) (Answers, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	var answers Answers
+	err := json.Unmarshal([]byte(raw), &answers)
+	return answers, err
+}
@@ -0,0 +1,69 @@
+package database
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidateAnswers(t *testing.T) {
+	questions := []Question{
+		{Key: "dietary", Label: "Dietary needs", Type: QuestionText},
+		{Key: "phone", Label: "Phone", Type: QuestionText, Required: true, MaxLength: 13, Pattern: `^\+?[0-9]+$`},
+		{Key: "shirt", Label: "Shirt size", Type: QuestionSelect, Options: []string{"S", "M", "L"}},
+		{Key: "rules", Label: "Race rules", Type: QuestionCheckbox, Required: true},
+		{Key: "photos", Label: "Photo consent", Type: QuestionCheckbox},
+	}
+
+	tests := []struct {
+		name    string
+		answers Answers
+		want    Answers
+		wantErr string
+	}{
+		{
+			name:    "trimmed and optional ones dropped",
+			answers: Answers{"dietary": "  ", "phone": " +251911000000 ", "shirt": "", "rules": true, "photos": nil},
+			want:    Answers{"phone": "+251911000000", "rules": true},
+		},
+		{
+			name:    "all answered",
+			answers: Answers{"dietary": "vegan", "phone": "0911000000", "shirt": "M", "rules": true, "photos": false},
+			want:    Answers{"dietary": "vegan", "phone": "0911000000", "shirt": "M", "rules": true, "photos": false},
+		},
+		{name: "unknown key", answers: Answers{"phone": "0911000000", "rules": true, "pet": "cat"}, wantErr: `"pet" is not a question`},
+		{name: "required text missing", answers: Answers{"rules": true}, wantErr: "Phone is required"},
+		{name: "required text blank", answers: Answers{"phone": "   ", "rules": true}, wantErr: "Phone is required"},
+		{name: "text of the wrong type", answers: Answers{"phone": 911000000, "rules": true}, wantErr: "Phone must be text"},
+		{name: "text too long", answers: Answers{"phone": "+2519110000000", "rules": true}, wantErr: "Phone must be at most 13 characters"},
+		{name: "text over the default limit", answers: Answers{"dietary": strings.Repeat("é", DefaultAnswerLength+1), "phone": "0911000000", "rules": true}, wantErr: "at most 500 characters"},
+		{name: "text not matching the pattern", answers: Answers{"phone": "call me", "rules": true}, wantErr: "Phone is not in the expected format"},
+		{name: "unknown option", answers: Answers{"phone": "0911000000", "shirt": "XL", "rules": true}, wantErr: "Shirt size must be one of: S, M, L"},
+		{name: "option of the wrong type", answers: Answers{"phone": "0911000000", "shirt": 2, "rules": true}, wantErr: "Shirt size must be one of its options"},
+		{name: "required checkbox unticked", answers: Answers{"phone": "0911000000", "rules": false}, wantErr: "Race rules must be checked"},
+		{name: "checkbox of the wrong type", answers: Answers{"phone": "0911000000", "rules": "yes"}, wantErr: "Race rules must be true or false"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ValidateAnswers(questions, tt.answers)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("ValidateAnswers = %v, %v; want an error containing %q", got, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ValidateAnswers = %v, %v; want %v", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateAnswersWithoutAForm(t *testing.T) {
+	if got, err := ValidateAnswers(nil, nil); err != nil || len(got) != 0 {
+		t.Errorf("ValidateAnswers(nil, nil) = %v, %v; want no answers", got, err)
+	}
+	if _, err := ValidateAnswers(nil, Answers{"note": "hi"}); err == nil {
+		t.Error("ValidateAnswers accepted an answer for an event without a form")
+	}
+}
@@ -0,0 +1,96 @@
+package database
+
+import (
+	"context"
+	"math"
+	"sort"
+	"time"
+)
+
+const earthRadiusKm = 6371.0
+
+// NearbyEvent is an event together with its distance from the point a
+// nearby search was made around.
+type NearbyEvent struct {
+	Event
+	DistanceKm float64 `json:"distance_km"`
+}
+
+// HaversineKm returns the great-circle distance between two points given
+// in degrees.
+func HaversineKm(lat1, lng1, lat2, lng2 float64) float64 {
+	dLat, dLng := radians(lat2-lat1), radians(lng2-lng1)
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(radians(lat1))*math.Cos(radians(lat2))*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * earthRadiusKm * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+func radians(deg float64) float64 {
+	return deg * math.Pi / 180
+}
+
+func degrees(rad float64) float64 {
+	return rad * 180 / math.Pi
+}
+
+// GetNearby returns the events with coordinates within radiusKm of lat and
+// lng, nearest first. Candidates come from a bounding box served by the
+// coordinates index; the exact distance is checked here.
+func (s *EventModel) GetNearby(lat, lng, radiusKm float64, filter EventFilter) ([]*NearbyEvent, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	where, args := filter.where()
+	box, boxArgs := boundingBox(lat, lng, radiusKm)
+	query := "SELECT " + eventColumns + " FROM events e WHERE " + where + " AND " + box
+	rows, err := s.ReadDB.QueryContext(ctx, query, append(args, boxArgs...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []*NearbyEvent{}
+	for rows.Next() {
+		var event NearbyEvent
+		if err := scanEvent(rows, &event.Event); err != nil {
+			return nil, err
+		}
+		event.DistanceKm = HaversineKm(lat, lng, *event.Latitude, *event.Longitude)
+		if event.DistanceKm <= radiusKm {
+			events = append(events, &event)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].DistanceKm < events[j].DistanceKm
+	})
+	return events, nil
+}
+
+// boundingBox returns a condition matching every point within radiusKm of
+// lat and lng (and some outside it). Near the poles the box spans every
+// longitude; across the antimeridian the longitude range wraps.
+func boundingBox(lat, lng, radiusKm float64) (string, []any) {
+	angular := radiusKm / earthRadiusKm
+	minLat, maxLat := lat-degrees(angular), lat+degrees(angular)
+	if minLat <= -90 || maxLat >= 90 {
+		return "e.latitude BETWEEN ? AND ? AND e.longitude IS NOT NULL",
+			[]any{math.Max(minLat, -90), math.Min(maxLat, 90)}
+	}
+
+	dLng := degrees(math.Asin(math.Sin(angular) / math.Cos(radians(lat))))
+	minLng, maxLng := lng-dLng, lng+dLng
+	switch {
+	case minLng < -180:
+		return "e.latitude BETWEEN ? AND ? AND (e.longitude >= ? OR e.longitude <= ?)",
+			[]any{minLat, maxLat, minLng + 360, maxLng}
+	case maxLng > 180:
+		return "e.latitude BETWEEN ? AND ? AND (e.longitude >= ? OR e.longitude <= ?)",
+			[]any{minLat, maxLat, minLng, maxLng - 360}
+	}
+	return "e.latitude BETWEEN ? AND ? AND e.longitude BETWEEN ? AND ?",
+		[]any{minLat, maxLat, minLng, maxLng}
+}
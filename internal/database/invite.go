@@ -0,0 +1,117 @@
+package database
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"time"
+)
+
+type InviteModel struct {
+	DB     *sql.DB
+	ReadDB *sql.DB
+}
+
+// Invite lets whoever holds its token view an event and RSVP to it until
+// it expires or is revoked. Only a hash of the token is stored; the token
+// itself is returned once, when the invite is created.
+type Invite struct {
+	ID        int        `json:"id"`
+	EventID   int        `json:"event_id"`
+	CreatedBy int        `json:"created_by"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+	Token     string     `json:"token,omitempty"`
+}
+
+// NewInviteToken returns a random URL-safe token.
+func NewInviteToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+func hashInviteToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Insert stores invite under the hash of invite.Token.
+func (s *InviteModel) Insert(invite *Invite) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	if invite.CreatedAt.IsZero() {
+		invite.CreatedAt = time.Now().UTC()
+	}
+	query := `
+		INSERT INTO event_invites (event_id, token_hash, created_by, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id`
+
+	return s.DB.QueryRowContext(ctx, query,
+		invite.EventID,
+		hashInviteToken(invite.Token),
+		invite.CreatedBy,
+		invite.CreatedAt,
+		invite.ExpiresAt.UTC(),
+	).Scan(&invite.ID)
+}
+
+// IsValid reports whether token is an unexpired, unrevoked invite to the
+// event.
+func (s *InviteModel) IsValid(eventID int, token string) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var valid bool
+	query := `
+		SELECT EXISTS (SELECT 1 FROM event_invites
+		WHERE event_id = $1 AND token_hash = $2 AND revoked_at IS NULL AND expires_at > $3)`
+	err := s.ReadDB.QueryRowContext(ctx, query, eventID, hashInviteToken(token), time.Now().UTC()).Scan(&valid)
+	return valid, err
+}
+
+// GetByEvent lists every invite of an event, newest first.
+func (s *InviteModel) GetByEvent(eventID int) ([]*Invite, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `
+		SELECT id, event_id, created_by, created_at, expires_at, revoked_at FROM event_invites
+		WHERE event_id = $1 ORDER BY id DESC`
+	rows, err := s.ReadDB.QueryContext(ctx, query, eventID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	invites := []*Invite{}
+	for rows.Next() {
+		var invite Invite
+		if err := rows.Scan(&invite.ID, &invite.EventID, &invite.CreatedBy, &invite.CreatedAt, &invite.ExpiresAt, &invite.RevokedAt); err != nil {
+			return nil, err
+		}
+		invites = append(invites, &invite)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return invites, nil
+}
+
+// Revoke invalidates an invite of the event. It returns sql.ErrNoRows when
+// there is no such invite or it was already revoked.
+func (s *InviteModel) Revoke(eventID, id int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `UPDATE event_invites SET revoked_at = $1 WHERE id = $2 AND event_id = $3 AND revoked_at IS NULL`
+	return expectOneRow(s.DB.ExecContext(ctx, query, time.Now().UTC(), id, eventID))
+}
@@ -0,0 +1,329 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// Job statuses. A queued job waits for RunAt; a worker claims it as
+// running until it succeeds or fails. A failed job is queued again with a
+// later RunAt until it has used MaxAttempts, when it is dead and waits
+// for an admin to retry it.
+const (
+	JobStatusQueued    = "queued"
+	JobStatusRunning   = "running"
+	JobStatusSucceeded = "succeeded"
+	JobStatusDead      = "dead"
+)
+
+// DefaultJobAttempts is the MaxAttempts of jobs that do not set one.
+const DefaultJobAttempts = 5
+
+type JobModel struct {
+	DB     *sql.DB
+	ReadDB *sql.DB
+}
+
+// Job is one unit of background work. Payload is handed to the handler
+// registered for Type. A running job is leased to its worker until
+// LockedUntil; once that passes, another worker may claim it again.
+type Job struct {
+	ID          int             `json:"id"`
+	Type        string          `json:"type"`
+	Payload     json.RawMessage `json:"payload" swaggertype:"object"`
+	Status      string          `json:"status"`
+	Attempts    int             `json:"attempts"`
+	MaxAttempts int             `json:"max_attempts"`
+	RunAt       time.Time       `json:"run_at"`
+	LockedUntil *time.Time      `json:"locked_until,omitempty"`
+	LastError   string          `json:"last_error,omitempty"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+}
+
+type JobFilter struct {
+	Status string
+	Type   string
+	Limit  int
+	Offset int
+}
+
+// NewJob returns a job of jobType with payload encoded as JSON, to run as
+// soon as a worker is free.
+func NewJob(jobType string, payload any) (*Job, error) {
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	return &Job{Type: jobType, Payload: raw}, nil
+}
+
+const jobColumns = `id, type, payload, status, attempts, max_attempts, run_at, locked_until, last_error, created_at, updated_at`
+
+func scanJob(row rowScanner, job *Job) error {
+	var payload string
+	err := row.Scan(&job.ID, &job.Type, &payload, &job.Status, &job.Attempts, &job.MaxAttempts, &job.RunAt,
+		&job.LockedUntil, &job.LastError, &job.CreatedAt, &job.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	job.Payload = json.RawMessage(payload)
+	return nil
+}
+
+// queryRower is satisfied by both *sql.DB and *sql.Tx, so jobs can be
+// queued in the transaction that makes them necessary.
+type queryRower interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func insertJob(ctx context.Context, db queryRower, job *Job) error {
+	now := time.Now().UTC()
+	if job.RunAt.IsZero() {
+		job.RunAt = now
+	}
+	if job.MaxAttempts <= 0 {
+		job.MaxAttempts = DefaultJobAttempts
+	}
+	if job.Payload == nil {
+		job.Payload = json.RawMessage("null")
+	}
+	job.Status = JobStatusQueued
+	job.CreatedAt = now
+	job.UpdatedAt = now
+	query := `
+		INSERT INTO jobs (type, payload, status, attempts, max_attempts, run_at, created_at, updated_at)
+		VALUES ($1, $2, $3, 0, $4, $5, $6, $6)
+		RETURNING id`
+	return db.QueryRowContext(ctx, query, job.Type, string(job.Payload), job.Status, job.MaxAttempts,
+		job.RunAt.UTC(), now).Scan(&job.ID)
+}
+
+// Enqueue queues a job. RunAt defaults to now and MaxAttempts to
+// DefaultJobAttempts.
+func (s *JobModel) Enqueue(job *Job) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return insertJob(ctx, s.DB, job)
+}
+
+// EnqueueOnce queues a job unless one of the same type is already queued
+// or running, and reports whether it did. It keeps recurring jobs from
+// piling up.
+func (s *JobModel) EnqueueOnce(job *Job) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	query := `SELECT EXISTS (SELECT 1 FROM jobs WHERE type = $1 AND status IN ('queued', 'running'))`
+	if err := tx.QueryRowContext(ctx, query, job.Type).Scan(&exists); err != nil {
+		return false, err
+	}
+	if exists {
+		return false, nil
+	}
+	if err := insertJob(ctx, tx, job); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// Claim leases the next due job to the caller for lease, counting it as an
+// attempt. Jobs whose lease ran out are claimed again, since their worker
+// is presumed gone. It returns nil when nothing is due.
+func (s *JobModel) Claim(lease time.Duration) (*Job, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	now := time.Now().UTC()
+	query := `
+		UPDATE jobs SET status = 'running', attempts = attempts + 1, locked_until = $lock, updated_at = $now
+		WHERE id = (
+			SELECT id FROM jobs
+			WHERE (status = 'queued' AND run_at <= $now) OR (status = 'running' AND locked_until <= $now)
+			ORDER BY run_at, id LIMIT 1
+		)
+		RETURNING ` + jobColumns
+	var job Job
+	err := scanJob(s.DB.QueryRowContext(ctx, query, sql.Named("now", now), sql.Named("lock", now.Add(lease))), &job)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// Complete marks a claimed job as succeeded. It returns sql.ErrNoRows
+// when the job's lease ran out and another worker claimed it meanwhile.
+func (s *JobModel) Complete(job *Job) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	now := time.Now().UTC()
+	query := `
+		UPDATE jobs SET status = 'succeeded', locked_until = NULL, last_error = '', updated_at = $1
+		WHERE id = $2 AND status = 'running' AND attempts = $3`
+	if err := expectOneRow(s.DB.ExecContext(ctx, query, now, job.ID, job.Attempts)); err != nil {
+		return err
+	}
+	job.Status = JobStatusSucceeded
+	job.LockedUntil = nil
+	job.LastError = ""
+	job.UpdatedAt = now
+	return nil
+}
+
+// Fail records why a claimed job failed and queues it again at next, or
+// marks it dead once it has used all its attempts. It returns
+// sql.ErrNoRows when the job's lease ran out and another worker claimed
+// it meanwhile.
+func (s *JobModel) Fail(job *Job, reason string, next time.Time) error {
+	if job.Attempts >= job.MaxAttempts {
+		return s.Bury(job, reason)
+	}
+	return s.finish(job, JobStatusQueued, reason, next)
+}
+
+// Bury marks a claimed job dead right away, whatever attempts it has
+// left, for failures that retrying cannot fix.
+func (s *JobModel) Bury(job *Job, reason string) error {
+	return s.finish(job, JobStatusDead, reason, job.RunAt)
+}
+
+func (s *JobModel) finish(job *Job, status, reason string, next time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	now := time.Now().UTC()
+	query := `
+		UPDATE jobs SET status = $1, run_at = $2, locked_until = NULL, last_error = $3, updated_at = $4
+		WHERE id = $5 AND status = 'running' AND attempts = $6`
+	if err := expectOneRow(s.DB.ExecContext(ctx, query, status, next.UTC(), reason, now, job.ID, job.Attempts)); err != nil {
+		return err
+	}
+	job.Status = status
+	job.RunAt = next.UTC()
+	job.LockedUntil = nil
+	job.LastError = reason
+	job.UpdatedAt = now
+	return nil
+}
+
+// Release hands a claimed job back without counting the attempt, for a
+// worker that is shutting down.
+func (s *JobModel) Release(job *Job) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `
+		UPDATE jobs SET status = 'queued', attempts = attempts - 1, locked_until = NULL, updated_at = $1
+		WHERE id = $2 AND status = 'running' AND attempts = $3`
+	return expectOneRow(s.DB.ExecContext(ctx, query, time.Now().UTC(), job.ID, job.Attempts))
+}
+
+// Retry queues a dead or queued job to run right away with a fresh set of
+// attempts. It returns sql.ErrNoRows when there is no such job or it is
+// running or has succeeded.
+func (s *JobModel) Retry(id int) (*Job, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	now := time.Now().UTC()
+	query := `
+		UPDATE jobs SET status = 'queued', attempts = 0, run_at = $1, locked_until = NULL, updated_at = $1
+		WHERE id = $2 AND status IN ('queued', 'dead')
+		RETURNING ` + jobColumns
+	var job Job
+	if err := scanJob(s.DB.QueryRowContext(ctx, query, now, id), &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// Get returns a job, or nil when there is none.
+func (s *JobModel) Get(id int) (*Job, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var job Job
+	err := scanJob(s.ReadDB.QueryRowContext(ctx, `SELECT `+jobColumns+` FROM jobs WHERE id = $1`, id), &job)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// Find lists the jobs matching filter, newest first.
+func (s *JobModel) Find(filter JobFilter) ([]*Job, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var conditions []string
+	var args []any
+	if filter.Status != "" {
+		conditions = append(conditions, "status = ?")
+		args = append(args, filter.Status)
+	}
+	if filter.Type != "" {
+		conditions = append(conditions, "type = ?")
+		args = append(args, filter.Type)
+	}
+
+	query := `SELECT ` + jobColumns + ` FROM jobs`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	limit := filter.Limit
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+	query += " ORDER BY id DESC LIMIT ? OFFSET ?"
+	args = append(args, limit, max(filter.Offset, 0))
+
+	rows, err := s.ReadDB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	jobs := []*Job{}
+	for rows.Next() {
+		var job Job
+		if err := scanJob(rows, &job); err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, &job)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+// Purge removes jobs that succeeded before cutoff and returns how many
+// were removed. Dead jobs are kept for inspection.
+func (s *JobModel) Purge(cutoff time.Time) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	res, err := s.DB.ExecContext(ctx, `DELETE FROM jobs WHERE status = 'succeeded' AND updated_at < $1`, cutoff.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,162 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestJobClaimOrderAndLease(t *testing.T) {
+	models := newTestModels(t)
+	later := &Job{Type: "later", RunAt: time.Now().Add(time.Hour)}
+	first := &Job{Type: "first", RunAt: time.Now().Add(-time.Minute)}
+	second := &Job{Type: "second"}
+	for _, job := range []*Job{later, first, second} {
+		if err := models.Jobs.Enqueue(job); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	job, err := models.Jobs.Claim(time.Minute)
+	if err != nil || job == nil || job.ID != first.ID {
+		t.Fatalf("Claim = %+v, %v; want the job that is most overdue", job, err)
+	}
+	if job.Status != JobStatusRunning || job.Attempts != 1 || job.LockedUntil == nil {
+		t.Errorf("claimed job = %+v, want running on its first attempt with a lease", job)
+	}
+	if job, err := models.Jobs.Claim(time.Minute); err != nil || job == nil || job.ID != second.ID {
+		t.Fatalf("Claim = %+v, %v; want the next due job", job, err)
+	}
+	if job, err := models.Jobs.Claim(time.Minute); err != nil || job != nil {
+		t.Fatalf("Claim = %+v, %v; want nothing before the last job is due", job, err)
+	}
+}
+
+func TestJobExpiredLeaseIsReclaimed(t *testing.T) {
+	models := newTestModels(t)
+	if err := models.Jobs.Enqueue(&Job{Type: "work"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// A lease that has already run out stands in for a worker that died.
+	stale, err := models.Jobs.Claim(-time.Second)
+	if err != nil || stale == nil {
+		t.Fatalf("Claim = %v, %v", stale, err)
+	}
+	fresh, err := models.Jobs.Claim(time.Minute)
+	if err != nil || fresh == nil || fresh.ID != stale.ID || fresh.Attempts != 2 {
+		t.Fatalf("reclaim = %+v, %v; want the same job on its second attempt", fresh, err)
+	}
+
+	if err := models.Jobs.Complete(stale); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Complete by the worker that lost the lease = %v, want sql.ErrNoRows", err)
+	}
+	if err := models.Jobs.Fail(stale, "boom", time.Now()); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Fail by the worker that lost the lease = %v, want sql.ErrNoRows", err)
+	}
+	if err := models.Jobs.Complete(fresh); err != nil {
+		t.Fatal(err)
+	}
+	job, err := models.Jobs.Get(fresh.ID)
+	if err != nil || job.Status != JobStatusSucceeded || job.LockedUntil != nil {
+		t.Errorf("Get = %+v, %v; want succeeded without a lease", job, err)
+	}
+}
+
+func TestJobFailRetriesThenDies(t *testing.T) {
+	models := newTestModels(t)
+	if err := models.Jobs.Enqueue(&Job{Type: "work", MaxAttempts: 2}); err != nil {
+		t.Fatal(err)
+	}
+
+	job, err := models.Jobs.Claim(time.Minute)
+	if err != nil || job == nil {
+		t.Fatalf("Claim = %v, %v", job, err)
+	}
+	next := time.Now().Add(time.Hour)
+	if err := models.Jobs.Fail(job, "first failure", next); err != nil {
+		t.Fatal(err)
+	}
+	if job.Status != JobStatusQueued || job.LastError != "first failure" {
+		t.Errorf("after one failure the job = %+v, want queued with the reason", job)
+	}
+	if job, err := models.Jobs.Claim(time.Minute); err != nil || job != nil {
+		t.Fatalf("Claim = %+v, %v; want nothing before the retry is due", job, err)
+	}
+
+	if _, err := models.Jobs.Retry(job.ID); err != nil {
+		t.Fatal(err)
+	}
+	// Retry resets the attempts, so use them up again.
+	for attempt := 1; attempt <= 2; attempt++ {
+		job, err = models.Jobs.Claim(time.Minute)
+		if err != nil || job == nil || job.Attempts != attempt {
+			t.Fatalf("Claim = %+v, %v; want attempt %d", job, err, attempt)
+		}
+		if err := models.Jobs.Fail(job, "failure", time.Now()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if job.Status != JobStatusDead {
+		t.Errorf("after its last attempt the job is %s, want dead", job.Status)
+	}
+	if job, err := models.Jobs.Claim(time.Minute); err != nil || job != nil {
+		t.Errorf("Claim = %+v, %v; want dead jobs left alone", job, err)
+	}
+}
+
+func TestJobBuryAndRelease(t *testing.T) {
+	models := newTestModels(t)
+	for _, jobType := range []string{"bury", "release"} {
+		if err := models.Jobs.Enqueue(&Job{Type: jobType}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	buried, err := models.Jobs.Claim(time.Minute)
+	if err != nil || buried == nil {
+		t.Fatalf("Claim = %v, %v", buried, err)
+	}
+	if err := models.Jobs.Bury(buried, "unknown type"); err != nil {
+		t.Fatal(err)
+	}
+	if buried.Status != JobStatusDead || buried.Attempts >= buried.MaxAttempts {
+		t.Errorf("buried job = %+v, want dead with attempts left", buried)
+	}
+
+	released, err := models.Jobs.Claim(time.Minute)
+	if err != nil || released == nil {
+		t.Fatalf("Claim = %v, %v", released, err)
+	}
+	if err := models.Jobs.Release(released); err != nil {
+		t.Fatal(err)
+	}
+	job, err := models.Jobs.Claim(time.Minute)
+	if err != nil || job == nil || job.ID != released.ID || job.Attempts != 1 {
+		t.Errorf("Claim after Release = %+v, %v; want the same job without the released attempt counted", job, err)
+	}
+}
+
+func TestJobEnqueueOnce(t *testing.T) {
+	models := newTestModels(t)
+	if ok, err := models.Jobs.EnqueueOnce(&Job{Type: "scan"}); err != nil || !ok {
+		t.Fatalf("EnqueueOnce = %v, %v; want queued", ok, err)
+	}
+	if ok, err := models.Jobs.EnqueueOnce(&Job{Type: "scan"}); err != nil || ok {
+		t.Fatalf("EnqueueOnce = %v, %v; want skipped while one is queued", ok, err)
+	}
+	job, err := models.Jobs.Claim(time.Minute)
+	if err != nil || job == nil {
+		t.Fatalf("Claim = %v, %v", job, err)
+	}
+	if ok, err := models.Jobs.EnqueueOnce(&Job{Type: "scan"}); err != nil || ok {
+		t.Fatalf("EnqueueOnce = %v, %v; want skipped while one is running", ok, err)
+	}
+	if err := models.Jobs.Complete(job); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := models.Jobs.EnqueueOnce(&Job{Type: "scan"}); err != nil || !ok {
+		t.Fatalf("EnqueueOnce = %v, %v; want queued once the last one finished", ok, err)
+	}
+}
@@ -0,0 +1,33 @@
+package database
+
+import (
+	"github.com/golang-migrate/migrate"
+	"github.com/golang-migrate/migrate/database/sqlite3"
+	"github.com/golang-migrate/migrate/source/file"
+)
+
+// Migrator returns a migrator for the migrations in dir, such as
+// cmd/migrate/migrations, applied through the writer.
+func (db *DB) Migrator(dir string) (*migrate.Migrate, error) {
+	instance, err := sqlite3.WithInstance(db.Writer, &sqlite3.Config{})
+	if err != nil {
+		return nil, err
+	}
+	source, err := (&file.File{}).Open(dir)
+	if err != nil {
+		return nil, err
+	}
+	return migrate.NewWithInstance("file", source, "sqlite3", instance)
+}
+
+// MigrateUp applies every migration in dir that has not been applied yet.
+func (db *DB) MigrateUp(dir string) error {
+	m, err := db.Migrator(dir)
+	if err != nil {
+		return err
+	}
+	if err := m.Up(); err != nil && err != migrate.ErrNoChange {
+		return err
+	}
+	return nil
+}
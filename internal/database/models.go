@@ -3,15 +3,64 @@ package database
 import "database/sql"
 
 type Models struct {
-	Users     UserModel
-	Events    EventModel
-	Attendees AttendeeModel
+	Users         UserModel
+	Events        EventModel
+	Attendees     AttendeeModel
+	Audit         AuditModel
+	Tags          TagModel
+	Invites       InviteModel
+	Organizers    OrganizerModel
+	TicketTypes   TicketTypeModel
+	PromoCodes    PromoCodeModel
+	Orders        OrderModel
+	Forms         FormModel
+	Webhooks      WebhookModel
+	Jobs          JobModel
+	Outbox        OutboxModel
+	Reminders     ReminderModel
+	Notifications NotificationModel
+	Announcements AnnouncementModel
+	Comments      CommentModel
+	Reviews       ReviewModel
+	Waitlist      WaitlistModel
 }
 
-func NewModels(db *sql.DB) Models {
+func NewModels(db *DB) Models {
 	return Models{
-		Users:     UserModel{DB: db},
-		Events:    EventModel{DB: db},
-		Attendees: AttendeeModel{DB: db},
+		Users:         UserModel{DB: db.Writer, ReadDB: db.Reader},
+		Events:        EventModel{DB: db.Writer, ReadDB: db.Reader},
+		Attendees:     AttendeeModel{DB: db.Writer, ReadDB: db.Reader},
+		Audit:         AuditModel{DB: db.Writer, ReadDB: db.Reader},
+		Tags:          TagModel{DB: db.Writer, ReadDB: db.Reader},
+		Invites:       InviteModel{DB: db.Writer, ReadDB: db.Reader},
+		Organizers:    OrganizerModel{DB: db.Writer, ReadDB: db.Reader},
+		TicketTypes:   TicketTypeModel{DB: db.Writer, ReadDB: db.Reader},
+		PromoCodes:    PromoCodeModel{DB: db.Writer, ReadDB: db.Reader},
+		Orders:        OrderModel{DB: db.Writer, ReadDB: db.Reader},
+		Forms:         FormModel{DB: db.Writer, ReadDB: db.Reader},
+		Webhooks:      WebhookModel{DB: db.Writer, ReadDB: db.Reader},
+		Jobs:          JobModel{DB: db.Writer, ReadDB: db.Reader},
+		Outbox:        OutboxModel{DB: db.Writer, ReadDB: db.Reader},
+		Reminders:     ReminderModel{DB: db.Writer, ReadDB: db.Reader},
+		Notifications: NotificationModel{DB: db.Writer, ReadDB: db.Reader},
+		Announcements: AnnouncementModel{DB: db.Writer, ReadDB: db.Reader},
+		Comments:      CommentModel{DB: db.Writer, ReadDB: db.Reader},
+		Reviews:       ReviewModel{DB: db.Writer, ReadDB: db.Reader},
+		Waitlist:      WaitlistModel{DB: db.Writer, ReadDB: db.Reader},
 	}
-}
\ No newline at end of file
+}
+
+// expectOneRow turns an Exec result that touched no rows into sql.ErrNoRows.
+func expectOneRow(res sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,237 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// Notification types shown in the in-app inbox.
+const (
+	NotificationAttendeeAdded    = "attendee.added"
+	NotificationEventUpdated     = "event.updated"
+	NotificationEventCancelled   = "event.cancelled"
+	NotificationEventReminder    = "event.reminder"
+	NotificationAnnouncement     = "announcement"
+	NotificationMention          = "comment.mention"
+	NotificationWaitlistPromoted = "waitlist.promoted"
+)
+
+type NotificationModel struct {
+	DB     *sql.DB
+	ReadDB *sql.DB
+}
+
+// Notification is one entry of a user's in-app inbox. Key identifies what
+// it is about, such as "event.cancelled:12", so that recording the same
+// thing twice, as a retried job does, leaves a single notification.
+type Notification struct {
+	ID        int        `json:"id"`
+	UserID    int        `json:"-"`
+	Type      string     `json:"type"`
+	EventID   *int       `json:"event_id,omitempty"`
+	Title     string     `json:"title"`
+	Body      string     `json:"body,omitempty"`
+	Key       string     `json:"-"`
+	ReadAt    *time.Time `json:"read_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// NotificationPage is one page of an inbox, newest first. NextCursor,
+// when set, fetches the page after it.
+type NotificationPage struct {
+	Notifications []*Notification `json:"notifications"`
+	UnreadCount   int             `json:"unread_count"`
+	NextCursor    *int            `json:"next_cursor,omitempty"`
+}
+
+// insertNotifications adds notification to the inbox of every user
+// selected by recipients, a query returning user_id that may refer to the
+// notification's event as $event, who keeps in-app notifications on.
+// Users who already have a notification with its key are skipped.
+func insertNotifications(ctx context.Context, tx *sql.Tx, notification *Notification, recipients string, args ...any) (int64, error) {
+	query := `
+		INSERT INTO notifications (user_id, type, event_id, title, body, dedupe_key, created_at)
+		SELECT r.user_id, $type, $event, $title, $body, $key, $now
+		FROM (` + recipients + `) r
+		LEFT JOIN notification_preferences p ON p.user_id = r.user_id
+		WHERE COALESCE(p.in_app, 1)
+		ON CONFLICT (user_id, dedupe_key) DO NOTHING`
+	args = append(args,
+		sql.Named("type", notification.Type),
+		sql.Named("event", notification.EventID),
+		sql.Named("title", notification.Title),
+		sql.Named("body", notification.Body),
+		sql.Named("key", notification.Key),
+		sql.Named("now", time.Now().UTC()),
+	)
+	res, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// attendeeRecipients selects everyone attending any occurrence of $event
+// who does not organize it.
+const attendeeRecipients = `
+	SELECT DISTINCT a.user_id FROM attendees a
+	JOIN users u ON u.id = a.user_id AND u.deleted_at IS NULL
+	WHERE a.event_id = $event
+	AND NOT EXISTS (SELECT 1 FROM event_organizers o WHERE o.event_id = a.event_id AND o.user_id = a.user_id)`
+
+// Recipient is an attendee who is emailed about their event.
+type Recipient struct {
+	UserID int
+	Email  string
+}
+
+// emailRecipients lists the attendeeRecipients of an event who keep email
+// notifications on.
+func emailRecipients(ctx context.Context, db *sql.DB, eventID int) ([]Recipient, error) {
+	query := `
+		SELECT r.user_id, u.email FROM (` + attendeeRecipients + `) r
+		JOIN users u ON u.id = r.user_id
+		LEFT JOIN notification_preferences p ON p.user_id = r.user_id
+		WHERE COALESCE(p.email, 1)
+		ORDER BY r.user_id`
+	rows, err := db.QueryContext(ctx, query, sql.Named("event", eventID))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var recipients []Recipient
+	for rows.Next() {
+		var recipient Recipient
+		if err := rows.Scan(&recipient.UserID, &recipient.Email); err != nil {
+			return nil, err
+		}
+		recipients = append(recipients, recipient)
+	}
+	return recipients, rows.Err()
+}
+
+// Insert adds notification to the inbox of notification.UserID, unless
+// they turned in-app notifications off or already have one with its key.
+func (s *NotificationModel) Insert(notification *Notification) error {
+	return s.insert(notification, `SELECT $user AS user_id`, sql.Named("user", notification.UserID))
+}
+
+// InsertForAttendees adds notification to the inbox of everyone attending
+// any occurrence of its event, except its organizers, who made the change
+// the notification is about.
+func (s *NotificationModel) InsertForAttendees(notification *Notification) error {
+	return s.insert(notification, attendeeRecipients)
+}
+
+func (s *NotificationModel) insert(notification *Notification, recipients string, args ...any) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := insertNotifications(ctx, tx, notification, recipients, args...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// List returns up to limit notifications of a user older than the one
+// with ID cursor, or the newest ones when cursor is 0, along with how many
+// of all their notifications are unread.
+func (s *NotificationModel) List(userID, cursor, limit int, unreadOnly bool) (*NotificationPage, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	query := `
+		SELECT id, type, event_id, title, body, read_at, created_at FROM notifications
+		WHERE user_id = $user AND ($cursor = 0 OR id < $cursor) AND (NOT $unread OR read_at IS NULL)
+		ORDER BY id DESC LIMIT $limit`
+	rows, err := s.ReadDB.QueryContext(ctx, query,
+		sql.Named("user", userID),
+		sql.Named("cursor", cursor),
+		sql.Named("unread", unreadOnly),
+		sql.Named("limit", limit+1),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	page := &NotificationPage{Notifications: []*Notification{}}
+	for rows.Next() {
+		n := Notification{UserID: userID}
+		if err := rows.Scan(&n.ID, &n.Type, &n.EventID, &n.Title, &n.Body, &n.ReadAt, &n.CreatedAt); err != nil {
+			return nil, err
+		}
+		page.Notifications = append(page.Notifications, &n)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(page.Notifications) > limit {
+		page.Notifications = page.Notifications[:limit]
+		page.NextCursor = &page.Notifications[limit-1].ID
+	}
+
+	page.UnreadCount, err = s.UnreadCount(userID)
+	if err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// UnreadCount returns how many notifications of a user are unread.
+func (s *NotificationModel) UnreadCount(userID int) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var count int
+	query := `SELECT COUNT(*) FROM notifications WHERE user_id = $1 AND read_at IS NULL`
+	err := s.ReadDB.QueryRowContext(ctx, query, userID).Scan(&count)
+	return count, err
+}
+
+// MarkRead marks a notification of a user as read. Marking it again keeps
+// the time it was first read.
+func (s *NotificationModel) MarkRead(userID, id int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `UPDATE notifications SET read_at = COALESCE(read_at, $1) WHERE id = $2 AND user_id = $3`
+	return expectOneRow(s.DB.ExecContext(ctx, query, time.Now().UTC(), id, userID))
+}
+
+// MarkAllRead marks every unread notification of a user as read and
+// returns how many there were.
+func (s *NotificationModel) MarkAllRead(userID int) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `UPDATE notifications SET read_at = $1 WHERE user_id = $2 AND read_at IS NULL`
+	res, err := s.DB.ExecContext(ctx, query, time.Now().UTC(), userID)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// Purge removes notifications read before cutoff.
+func (s *NotificationModel) Purge(cutoff time.Time) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	res, err := s.DB.ExecContext(ctx, `DELETE FROM notifications WHERE read_at < $1`, cutoff.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,284 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Order statuses. A pending order reserves its ticket until ExpiresAt; it
+// becomes paid when the provider confirms payment, failed when the
+// provider reports a failure, and expired once its reservation lapses.
+const (
+	OrderStatusPending = "pending"
+	OrderStatusPaid    = "paid"
+	OrderStatusFailed  = "failed"
+	OrderStatusExpired = "expired"
+)
+
+var (
+	// ErrSoldOut is returned when a ticket tier has nothing left to sell.
+	ErrSoldOut = errors.New("sold out")
+	// ErrPromoExhausted is returned when a promo code has reached its
+	// usage limit.
+	ErrPromoExhausted = errors.New("promo code usage limit reached")
+)
+
+type OrderModel struct {
+	DB     *sql.DB
+	ReadDB *sql.DB
+}
+
+// Order buys one ticket of a tier for its user. Once paid, the user is
+// added to the event's attendees and AttendeeID points at that row.
+type Order struct {
+	ID             int        `json:"id"`
+	EventID        int        `json:"event_id"`
+	UserID         int        `json:"user_id"`
+	TicketTypeID   int        `json:"ticket_type_id"`
+	PromoCodeID    *int       `json:"promo_code_id,omitempty"`
+	OccurrenceDate string     `json:"occurrence_date,omitempty"`
+	AmountMinor    int64      `json:"amount_minor"`
+	Currency       string     `json:"currency"`
+	Status         string     `json:"status"`
+	Provider       string     `json:"provider,omitempty"`
+	ProviderRef    string     `json:"provider_ref,omitempty"`
+	AttendeeID     *int       `json:"attendee_id,omitempty"`
+	ExpiresAt      time.Time  `json:"expires_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+	PaidAt         *time.Time `json:"paid_at,omitempty"`
+	// Answers to the event's registration form, given at checkout and
+	// copied to the attendee once the order is paid.
+	Answers Answers `json:"answers,omitempty"`
+}
+
+const orderColumns = `id, event_id, user_id, ticket_type_id, promo_code_id, occurrence_date, amount_minor, currency, status,
+	provider, provider_ref, attendee_id, expires_at, created_at, paid_at, answers`
+
+func scanOrder(row rowScanner, order *Order) error {
+	var answers string
+	err := row.Scan(&order.ID, &order.EventID, &order.UserID, &order.TicketTypeID, &order.PromoCodeID, &order.OccurrenceDate,
+		&order.AmountMinor, &order.Currency, &order.Status, &order.Provider, &order.ProviderRef, &order.AttendeeID,
+		&order.ExpiresAt, &order.CreatedAt, &order.PaidAt, &answers)
+	if err != nil {
+		return err
+	}
+	order.Answers, err = decodeAnswers(answers)
+	return err
+}
+
+// Reserve inserts order as pending, holding one ticket of its tier until
+// order.ExpiresAt. It returns ErrSoldOut or ErrPromoExhausted when the
+// tier or the promo code has nothing left. When the waitlist holds a
+// ticket of the tier for the user, order takes that hold over instead,
+// keeping its ID and expiry.
+func (s *OrderModel) Reserve(order *Order) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	now := time.Now().UTC()
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `UPDATE orders SET status = 'expired' WHERE status = 'pending' AND expires_at <= $1`, now)
+	if err != nil {
+		return err
+	}
+
+	var held *Order
+	query := `
+		SELECT o.id, o.expires_at, o.created_at FROM orders o JOIN waitlist_entries w ON w.order_id = o.id
+		WHERE o.user_id = $1 AND o.ticket_type_id = $2 AND o.occurrence_date = $3 AND o.status = 'pending' AND o.expires_at > $4`
+	var hold Order
+	err = tx.QueryRowContext(ctx, query, order.UserID, order.TicketTypeID, order.OccurrenceDate, now).Scan(&hold.ID, &hold.ExpiresAt, &hold.CreatedAt)
+	switch {
+	case err == nil:
+		held = &hold
+	case !errors.Is(err, sql.ErrNoRows):
+		return err
+	}
+
+	if held == nil {
+		var available int
+		err = tx.QueryRowContext(ctx, `SELECT tt.quantity - `+reservedOrders+` FROM ticket_types tt WHERE tt.id = $id`,
+			sql.Named("id", order.TicketTypeID), sql.Named("now", now)).Scan(&available)
+		if err != nil {
+			return err
+		}
+		if available <= 0 {
+			return ErrSoldOut
+		}
+	}
+	if order.PromoCodeID != nil {
+		var maxUses sql.NullInt64
+		var uses int64
+		err = tx.QueryRowContext(ctx, `SELECT p.max_uses, `+promoUses+` FROM promo_codes p WHERE p.id = $id`,
+			sql.Named("id", *order.PromoCodeID), sql.Named("now", now)).Scan(&maxUses, &uses)
+		if err != nil {
+			return err
+		}
+		if maxUses.Valid && uses >= maxUses.Int64 {
+			return ErrPromoExhausted
+		}
+	}
+
+	answers, err := encodeAnswers(order.Answers)
+	if err != nil {
+		return err
+	}
+	order.Status = OrderStatusPending
+	if held != nil {
+		query = `UPDATE orders SET promo_code_id = $1, amount_minor = $2, currency = $3, answers = $4 WHERE id = $5`
+		_, err = tx.ExecContext(ctx, query, order.PromoCodeID, order.AmountMinor, order.Currency, answers, held.ID)
+		if err != nil {
+			return err
+		}
+		order.ID, order.ExpiresAt, order.CreatedAt = held.ID, held.ExpiresAt, held.CreatedAt
+		return tx.Commit()
+	}
+	order.CreatedAt = now
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO orders (event_id, user_id, ticket_type_id, promo_code_id, occurrence_date, amount_minor, currency, status, expires_at, created_at, answers)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		RETURNING id`,
+		order.EventID, order.UserID, order.TicketTypeID, order.PromoCodeID, order.OccurrenceDate,
+		order.AmountMinor, order.Currency, order.Status, order.ExpiresAt.UTC(), order.CreatedAt, answers,
+	).Scan(&order.ID)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Get returns an order, or nil when there is none.
+func (s *OrderModel) Get(id int) (*Order, error) {
+	return s.getOrder(`SELECT `+orderColumns+` FROM orders WHERE id = $1`, id)
+}
+
+// GetByProviderRef returns the order paid through provider under ref, or
+// nil when there is none.
+func (s *OrderModel) GetByProviderRef(provider, ref string) (*Order, error) {
+	return s.getOrder(`SELECT `+orderColumns+` FROM orders WHERE provider = $1 AND provider_ref = $2 AND provider_ref <> ''`, provider, ref)
+}
+
+func (s *OrderModel) getOrder(query string, args ...any) (*Order, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var order Order
+	err := scanOrder(s.ReadDB.QueryRowContext(ctx, query, args...), &order)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+// SetProvider records the provider session an order is paid through.
+func (s *OrderModel) SetProvider(order *Order, provider, ref string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `UPDATE orders SET provider = $1, provider_ref = $2 WHERE id = $3`
+	if err := expectOneRow(s.DB.ExecContext(ctx, query, provider, ref, order.ID)); err != nil {
+		return err
+	}
+	order.Provider = provider
+	order.ProviderRef = ref
+	return nil
+}
+
+// MarkPaid settles an order and adds its user to the event's attendees,
+// reusing their attendance if they already have one. A payment that
+// arrives after the reservation lapsed is still honored while the tier
+// has tickets left; otherwise MarkPaid returns ErrSoldOut. Paying a paid
+// order again is a no-op.
+func (s *OrderModel) MarkPaid(actor Actor, order *Order) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	now := time.Now().UTC()
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := scanOrder(tx.QueryRowContext(ctx, `SELECT `+orderColumns+` FROM orders WHERE id = $1`, order.ID), order); err != nil {
+		return err
+	}
+	if order.Status == OrderStatusPaid {
+		return nil
+	}
+	if order.Status != OrderStatusPending || !order.ExpiresAt.After(now) {
+		var available int
+		err = tx.QueryRowContext(ctx, `SELECT tt.quantity - `+reservedOrders+` FROM ticket_types tt WHERE tt.id = $id`,
+			sql.Named("id", order.TicketTypeID), sql.Named("now", now)).Scan(&available)
+		if err != nil {
+			return err
+		}
+		if available <= 0 {
+			return ErrSoldOut
+		}
+	}
+
+	var attendeeID int
+	err = tx.QueryRowContext(ctx, `SELECT id FROM attendees WHERE event_id = $1 AND user_id = $2 AND occurrence_date = $3`,
+		order.EventID, order.UserID, order.OccurrenceDate).Scan(&attendeeID)
+	if errors.Is(err, sql.ErrNoRows) {
+		answers, encodeErr := encodeAnswers(order.Answers)
+		if encodeErr != nil {
+			return encodeErr
+		}
+		err = tx.QueryRowContext(ctx, `INSERT INTO attendees (event_id, user_id, occurrence_date, answers) VALUES ($1, $2, $3, $4) RETURNING id`,
+			order.EventID, order.UserID, order.OccurrenceDate, answers).Scan(&attendeeID)
+		if err == nil {
+			attendee := &Attendee{
+				ID:             attendeeID,
+				EventID:        order.EventID,
+				UserID:         order.UserID,
+				OccurrenceDate: order.OccurrenceDate,
+				Answers:        order.Answers,
+			}
+			err = recordAttendeeChange(ctx, tx, DomainAttendeeAdded, attendee)
+			if err == nil {
+				err = insertAudit(ctx, tx, actor, AuditEntityAttendee, attendeeID, order.EventID, AuditCreate, nil, attendee)
+			}
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE orders SET status = 'paid', paid_at = $1, attendee_id = $2 WHERE id = $3`, now, attendeeID, order.ID)
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	order.Status = OrderStatusPaid
+	order.PaidAt = &now
+	order.AttendeeID = &attendeeID
+	return nil
+}
+
+// MarkFailed records that the provider could not take payment for a
+// pending order, releasing its reservation.
+func (s *OrderModel) MarkFailed(order *Order) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `UPDATE orders SET status = 'failed' WHERE id = $1 AND status IN ('pending', 'expired')`
+	if err := expectOneRow(s.DB.ExecContext(ctx, query, order.ID)); err != nil {
+		return err
+	}
+	order.Status = OrderStatusFailed
+	return nil
+}
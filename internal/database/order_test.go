@@ -0,0 +1,177 @@
+package database
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPromoCodeApply(t *testing.T) {
+	tests := []struct {
+		kind   string
+		amount int64
+		price  int64
+		want   int64
+	}{
+		{PromoKindPercent, 25, 2000, 1500},
+		{PromoKindPercent, 100, 2000, 0},
+		{PromoKindPercent, 150, 2000, 0},
+		{PromoKindFixed, 500, 2000, 1500},
+		{PromoKindFixed, 5000, 2000, 0},
+	}
+	for _, tt := range tests {
+		promo := &PromoCode{Kind: tt.kind, Amount: tt.amount}
+		if got := promo.Apply(tt.price); got != tt.want {
+			t.Errorf("%s %d off %d = %d, want %d", tt.kind, tt.amount, tt.price, got, tt.want)
+		}
+	}
+}
+
+// insertTicketedEvent inserts a published concert selling quantity
+// tickets of a single tier at 10 USD.
+func insertTicketedEvent(t *testing.T, models Models, quantity int) (*Event, *TicketType) {
+	t.Helper()
+	promoter := &User{Username: "promoter", Email: "promoter@example.com", Password: "x"}
+	if err := models.Users.Insert(Actor{}, promoter); err != nil {
+		t.Fatal(err)
+	}
+	event := &Event{
+		OwnerId:     promoter.ID,
+		Name:        "Jazz night",
+		Description: "Ethio-jazz in the old cinema",
+		Date:        "2026-12-04",
+		Location:    "Cinema Empire",
+		Status:      EventStatusPublished,
+	}
+	if err := models.Events.Insert(Actor{}, event); err != nil {
+		t.Fatal(err)
+	}
+	tier := &TicketType{EventID: event.ID, Name: "General", PriceMinor: 1000, Currency: "USD", Quantity: quantity}
+	if err := models.TicketTypes.Insert(tier); err != nil {
+		t.Fatal(err)
+	}
+	return event, tier
+}
+
+func insertBuyer(t *testing.T, models Models, name string) *User {
+	t.Helper()
+	buyer := &User{Username: name, Email: name + "@example.com", Password: "x"}
+	if err := models.Users.Insert(Actor{}, buyer); err != nil {
+		t.Fatal(err)
+	}
+	return buyer
+}
+
+func TestOrderReserveAndPay(t *testing.T) {
+	models := newTestModels(t)
+	event, tier := insertTicketedEvent(t, models, 2)
+
+	reserve := func(name string, expiresIn time.Duration) (*Order, error) {
+		buyer := insertBuyer(t, models, name)
+		order := &Order{
+			EventID:      event.ID,
+			UserID:       buyer.ID,
+			TicketTypeID: tier.ID,
+			AmountMinor:  tier.PriceMinor,
+			Currency:     tier.Currency,
+			ExpiresAt:    time.Now().Add(expiresIn),
+		}
+		return order, models.Orders.Reserve(order)
+	}
+
+	lapsed, err := reserve("lapsed", -time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, err := reserve("first", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := reserve("second", time.Minute)
+	if err != nil {
+		t.Fatalf("Reserve beside a lapsed reservation = %v, want the lapsed one not to hold a ticket", err)
+	}
+	if _, err := reserve("third", time.Minute); !errors.Is(err, ErrSoldOut) {
+		t.Fatalf("Reserve of a full tier = %v, want ErrSoldOut", err)
+	}
+
+	if err := models.Orders.MarkFailed(second); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reserve("fourth", time.Minute); err != nil {
+		t.Fatalf("Reserve after a failed payment = %v, want its ticket released", err)
+	}
+	if err := models.Orders.MarkPaid(Actor{}, lapsed); !errors.Is(err, ErrSoldOut) {
+		t.Errorf("MarkPaid of a lapsed order with the tier full = %v, want ErrSoldOut", err)
+	}
+
+	if err := models.Orders.MarkPaid(Actor{}, first); err != nil {
+		t.Fatal(err)
+	}
+	if first.Status != OrderStatusPaid || first.AttendeeID == nil {
+		t.Fatalf("paid order = %+v, want paid with an attendee", first)
+	}
+	attendeeID := *first.AttendeeID
+	if err := models.Orders.MarkPaid(Actor{}, first); err != nil || *first.AttendeeID != attendeeID {
+		t.Errorf("paying again = %v with attendee %d, want a no-op keeping attendee %d", err, *first.AttendeeID, attendeeID)
+	}
+	if ok, err := models.Attendees.IsAttending(event.ID, first.UserID); err != nil || !ok {
+		t.Errorf("IsAttending after payment = %v, %v", ok, err)
+	}
+	tiers, err := models.TicketTypes.GetByEvent(event.ID)
+	if err != nil || len(tiers) != 1 || tiers[0].Available != 0 {
+		t.Errorf("GetByEvent = %+v, %v; want no tickets available", tiers, err)
+	}
+}
+
+func TestOrderPromoCodeLimit(t *testing.T) {
+	models := newTestModels(t)
+	event, tier := insertTicketedEvent(t, models, 10)
+	maxUses := 1
+	promo := &PromoCode{EventID: event.ID, Code: " early ", Kind: PromoKindPercent, Amount: 50, MaxUses: &maxUses}
+	if err := models.PromoCodes.Insert(promo); err != nil {
+		t.Fatal(err)
+	}
+	if promo.Code != "EARLY" {
+		t.Errorf("stored code = %q, want EARLY", promo.Code)
+	}
+
+	reserve := func(name string) (*Order, error) {
+		buyer := insertBuyer(t, models, name)
+		found, err := models.PromoCodes.GetByCode(event.ID, "Early")
+		if err != nil || found == nil {
+			t.Fatalf("GetByCode = %v, %v", found, err)
+		}
+		order := &Order{
+			EventID:      event.ID,
+			UserID:       buyer.ID,
+			TicketTypeID: tier.ID,
+			PromoCodeID:  &found.ID,
+			AmountMinor:  found.Apply(tier.PriceMinor),
+			Currency:     tier.Currency,
+			ExpiresAt:    time.Now().Add(time.Minute),
+		}
+		return order, models.Orders.Reserve(order)
+	}
+
+	order, err := reserve("first")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.AmountMinor != 500 {
+		t.Errorf("discounted amount = %d, want 500", order.AmountMinor)
+	}
+	if _, err := reserve("second"); !errors.Is(err, ErrPromoExhausted) {
+		t.Fatalf("Reserve past the code's limit = %v, want ErrPromoExhausted", err)
+	}
+	if found, err := models.PromoCodes.GetByCode(event.ID, "early"); err != nil || found.Uses != 1 {
+		t.Errorf("GetByCode = %+v, %v; want 1 use", found, err)
+	}
+
+	if err := models.Orders.MarkFailed(order); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reserve("third"); err != nil {
+		t.Errorf("Reserve after the only use failed = %v, want the use released", err)
+	}
+}
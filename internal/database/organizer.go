@@ -0,0 +1,254 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Organizer roles. Every event has exactly one owner, mirrored in
+// events.owner_id; co-hosts help run it and check-in staff only admit
+// attendees at the door.
+const (
+	OrganizerRoleOwner   = "owner"
+	OrganizerRoleCoHost  = "co-host"
+	OrganizerRoleCheckIn = "checkin"
+)
+
+// Permissions checked against an organizer's role with RoleAllows.
+const (
+	// PermissionEdit covers changing the event, its status, tags,
+	// occurrences and invites.
+	PermissionEdit = "edit"
+	// PermissionManageAttendees covers adding and removing attendees.
+	PermissionManageAttendees = "manage_attendees"
+	// PermissionCheckIn covers checking attendees in.
+	PermissionCheckIn = "checkin"
+	// PermissionOwn covers deleting the event, managing organizers and
+	// transferring ownership.
+	PermissionOwn = "own"
+)
+
+var rolePermissions = map[string][]string{
+	OrganizerRoleOwner:   {PermissionEdit, PermissionManageAttendees, PermissionCheckIn, PermissionOwn},
+	OrganizerRoleCoHost:  {PermissionEdit, PermissionManageAttendees, PermissionCheckIn},
+	OrganizerRoleCheckIn: {PermissionCheckIn},
+}
+
+// ErrOwnerRole is returned when an organizer change would leave an event
+// without its single owner.
+var ErrOwnerRole = errors.New("the owner can only change through an ownership transfer")
+
+// RoleAllows reports whether an organizer with role has permission.
+func RoleAllows(role, permission string) bool {
+	for _, p := range rolePermissions[role] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+type OrganizerModel struct {
+	DB     *sql.DB
+	ReadDB *sql.DB
+}
+
+type Organizer struct {
+	EventID   int       `json:"event_id"`
+	UserID    int       `json:"user_id"`
+	Role      string    `json:"role"`
+	Username  string    `json:"username"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// GetRole returns the role of the user on the event, or "" when they are
+// not one of its organizers.
+func (s *OrganizerModel) GetRole(eventID, userID int) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return findRole(ctx, s.ReadDB, eventID, userID)
+}
+
+// GetByEvent lists the organizers of an event, owner first.
+func (s *OrganizerModel) GetByEvent(eventID int) ([]*Organizer, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `
+		SELECT o.event_id, o.user_id, o.role, u.name, u.email, o.created_at FROM event_organizers o
+		JOIN users u ON u.id = o.user_id
+		WHERE o.event_id = $1 AND u.deleted_at IS NULL
+		ORDER BY o.role = 'owner' DESC, o.created_at, o.user_id`
+	rows, err := s.ReadDB.QueryContext(ctx, query, eventID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	organizers := []*Organizer{}
+	for rows.Next() {
+		var organizer Organizer
+		if err := rows.Scan(&organizer.EventID, &organizer.UserID, &organizer.Role, &organizer.Username, &organizer.Email, &organizer.CreatedAt); err != nil {
+			return nil, err
+		}
+		organizers = append(organizers, &organizer)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return organizers, nil
+}
+
+// Set adds the user to the event's organizers with role, or changes the
+// role of an existing organizer. The owner cannot be set or changed here.
+func (s *OrganizerModel) Set(actor Actor, eventID, userID int, role string) error {
+	if role == OrganizerRoleOwner {
+		return ErrOwnerRole
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := findRole(ctx, tx, eventID, userID)
+	if err != nil {
+		return err
+	}
+	query := `
+		INSERT INTO event_organizers (event_id, user_id, role, created_at) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (event_id, user_id) DO UPDATE SET role = excluded.role
+		WHERE event_organizers.role <> 'owner'`
+	if err := translateOwnerConflict(tx.ExecContext(ctx, query, eventID, userID, role, time.Now().UTC())); err != nil {
+		return err
+	}
+	switch before {
+	case role:
+		// Unchanged, so there is nothing to audit.
+	case "":
+		err = insertAudit(ctx, tx, actor, AuditEntityOrganizer, userID, eventID, AuditCreate, nil, map[string]string{"role": role})
+	default:
+		err = insertAudit(ctx, tx, actor, AuditEntityOrganizer, userID, eventID, AuditUpdate, map[string]string{"role": before}, map[string]string{"role": role})
+	}
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Remove takes the user off the event's organizers. The owner cannot be
+// removed.
+func (s *OrganizerModel) Remove(actor Actor, eventID, userID int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	role, err := findRole(ctx, tx, eventID, userID)
+	if err != nil {
+		return err
+	}
+	if role == OrganizerRoleOwner {
+		return ErrOwnerRole
+	}
+	query := `DELETE FROM event_organizers WHERE event_id = $1 AND user_id = $2 AND role <> 'owner'`
+	if err := expectOneRow(tx.ExecContext(ctx, query, eventID, userID)); err != nil {
+		return err
+	}
+	if err := insertAudit(ctx, tx, actor, AuditEntityOrganizer, userID, eventID, AuditDelete, map[string]string{"role": role}, nil); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func findRole(ctx context.Context, db queryRower, eventID, userID int) (string, error) {
+	var role string
+	query := `SELECT role FROM event_organizers WHERE event_id = $1 AND user_id = $2`
+	err := db.QueryRowContext(ctx, query, eventID, userID).Scan(&role)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	return role, err
+}
+
+// TransferOwnership makes the user the owner of event, with the same
+// version check as EventModel.Update. The previous owner stays on as a
+// co-host.
+func (s *OrganizerModel) TransferOwnership(actor Actor, event *Event, userID int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := eventBeforeChange(ctx, tx, event.ID)
+	if err != nil {
+		return err
+	}
+
+	err = tx.QueryRowContext(ctx, `
+		UPDATE events SET owner_id = $1, version = version + 1
+		WHERE id = $2 AND version = $3 AND deleted_at IS NULL
+		RETURNING version`, userID, event.ID, event.Version).Scan(&event.Version)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrEditConflict
+		}
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `UPDATE event_organizers SET role = 'co-host' WHERE event_id = $1 AND role = 'owner'`, event.ID)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO event_organizers (event_id, user_id, role, created_at) VALUES ($1, $2, 'owner', $3)
+		ON CONFLICT (event_id, user_id) DO UPDATE SET role = 'owner'`, event.ID, userID, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	if err := recordEventChange(ctx, tx, DomainEventUpdated, event.ID); err != nil {
+		return err
+	}
+	if err := auditEventChange(ctx, tx, actor, AuditUpdate, before, event.ID); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	event.OwnerId = userID
+	return nil
+}
+
+// translateOwnerConflict maps an upsert that was skipped because it hit
+// the owner's row to ErrOwnerRole.
+func translateOwnerConflict(res sql.Result, err error) error {
+	if err := expectOneRow(res, err); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrOwnerRole
+		}
+		return err
+	}
+	return nil
+}
+
+// insertOwner records the owner of a newly inserted event.
+func insertOwner(ctx context.Context, tx *sql.Tx, eventID, userID int) error {
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO event_organizers (event_id, user_id, role, created_at) VALUES ($1, $2, 'owner', $3)`,
+		eventID, userID, time.Now().UTC())
+	return err
+}
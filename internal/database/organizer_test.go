@@ -0,0 +1,71 @@
+package database
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestRoleAllows(t *testing.T) {
+	permissions := []string{PermissionEdit, PermissionManageAttendees, PermissionCheckIn, PermissionOwn}
+	tests := []struct {
+		role string
+		want []string
+	}{
+		{OrganizerRoleOwner, permissions},
+		{OrganizerRoleCoHost, []string{PermissionEdit, PermissionManageAttendees, PermissionCheckIn}},
+		{OrganizerRoleCheckIn, []string{PermissionCheckIn}},
+		{"", nil},
+		{"admin", nil},
+	}
+	for _, tt := range tests {
+		for _, permission := range permissions {
+			want := slices.Contains(tt.want, permission)
+			if got := RoleAllows(tt.role, permission); got != want {
+				t.Errorf("RoleAllows(%q, %q) = %v, want %v", tt.role, permission, got, want)
+			}
+		}
+	}
+}
+
+func TestOrganizerOwnerOnlyChangesByTransfer(t *testing.T) {
+	models := newTestModels(t)
+	var crew []*User
+	for _, name := range []string{"founder", "successor", "doorman"} {
+		user := &User{Username: name, Email: name + "@example.com", Password: "x"}
+		if err := models.Users.Insert(Actor{}, user); err != nil {
+			t.Fatal(err)
+		}
+		crew = append(crew, user)
+	}
+	founder, successor, doorman := crew[0], crew[1], crew[2]
+	event := &Event{OwnerId: founder.ID, Name: "Book fair", Description: "Publishers from all over the region", Date: "2026-11-14", Location: "Mekelle"}
+	if err := models.Events.Insert(Actor{}, event); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := models.Organizers.Set(Actor{}, event.ID, doorman.ID, OrganizerRoleOwner); !errors.Is(err, ErrOwnerRole) {
+		t.Errorf("Set to owner = %v, want ErrOwnerRole", err)
+	}
+	if err := models.Organizers.Set(Actor{}, event.ID, founder.ID, OrganizerRoleCheckIn); !errors.Is(err, ErrOwnerRole) {
+		t.Errorf("Set on the owner = %v, want ErrOwnerRole", err)
+	}
+	if err := models.Organizers.Remove(Actor{}, event.ID, founder.ID); !errors.Is(err, ErrOwnerRole) {
+		t.Errorf("Remove of the owner = %v, want ErrOwnerRole", err)
+	}
+	if err := models.Organizers.Set(Actor{}, event.ID, doorman.ID, OrganizerRoleCheckIn); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := models.Organizers.TransferOwnership(Actor{}, event, successor.ID); err != nil {
+		t.Fatal(err)
+	}
+	for user, want := range map[*User]string{founder: OrganizerRoleCoHost, successor: OrganizerRoleOwner, doorman: OrganizerRoleCheckIn} {
+		if role, err := models.Organizers.GetRole(event.ID, user.ID); err != nil || role != want {
+			t.Errorf("role of %s after the transfer = %q, %v; want %q", user.Username, role, err, want)
+		}
+	}
+	if event.OwnerId != successor.ID {
+		t.Errorf("OwnerId = %d, want %d", event.OwnerId, successor.ID)
+	}
+}
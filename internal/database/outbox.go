@@ -0,0 +1,131 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+// Domain event types written to the outbox.
+const (
+	DomainEventCreated      = "event.created"
+	DomainEventUpdated      = "event.updated"
+	DomainEventDeleted      = "event.deleted"
+	DomainAttendeeAdded     = "attendee.added"
+	DomainAttendeeRemoved   = "attendee.removed"
+	DomainAttendeeCheckedIn = "attendee.checked_in"
+	DomainWaitlistPromoted  = "waitlist.promoted"
+)
+
+// AggregateEvent is the aggregate every domain event belongs to: an event
+// together with its attendees. Domain events of one aggregate are
+// published in the order they were written.
+const AggregateEvent = "event"
+
+type OutboxModel struct {
+	DB     *sql.DB
+	ReadDB *sql.DB
+}
+
+// DomainEvent records that a change was committed. It is written in the
+// transaction that makes the change, so it exists exactly when the change
+// does, and stays unpublished until every sink has accepted it.
+type DomainEvent struct {
+	ID            int             `json:"id"`
+	AggregateType string          `json:"aggregate_type"`
+	AggregateID   int             `json:"aggregate_id"`
+	Type          string          `json:"type"`
+	Payload       json.RawMessage `json:"payload" swaggertype:"object"`
+	CreatedAt     time.Time       `json:"created_at"`
+	PublishedAt   *time.Time      `json:"published_at,omitempty"`
+}
+
+func insertDomainEvent(ctx context.Context, tx *sql.Tx, aggregateType string, aggregateID int, eventType string, payload any) error {
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	query := `
+		INSERT INTO outbox (aggregate_type, aggregate_id, event_type, payload, created_at)
+		VALUES ($1, $2, $3, $4, $5)`
+	_, err = tx.ExecContext(ctx, query, aggregateType, aggregateID, eventType, string(raw), time.Now().UTC())
+	return err
+}
+
+// recordEventChange writes eventType to the outbox with the event as it
+// stands in tx, soft-deleted or not.
+func recordEventChange(ctx context.Context, tx *sql.Tx, eventType string, eventID int) error {
+	event, err := eventInTx(ctx, tx, eventID)
+	if err != nil {
+		return err
+	}
+	return insertDomainEvent(ctx, tx, AggregateEvent, eventID, eventType, event)
+}
+
+// recordAttendeeChange writes eventType to the outbox for attendee, as
+// part of its event's aggregate. The ticket code is left out.
+func recordAttendeeChange(ctx context.Context, tx *sql.Tx, eventType string, attendee *Attendee) error {
+	payload := *attendee
+	payload.Ticket = ""
+	return insertDomainEvent(ctx, tx, AggregateEvent, attendee.EventID, eventType, &payload)
+}
+
+// Unpublished returns up to limit domain events after afterID that are
+// not published yet, oldest first.
+func (s *OutboxModel) Unpublished(afterID, limit int) ([]*DomainEvent, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `
+		SELECT id, aggregate_type, aggregate_id, event_type, payload, created_at, published_at
+		FROM outbox WHERE published_at IS NULL AND id > $1 ORDER BY id LIMIT $2`
+	rows, err := s.ReadDB.QueryContext(ctx, query, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []*DomainEvent{}
+	for rows.Next() {
+		var event DomainEvent
+		var payload string
+		err := rows.Scan(&event.ID, &event.AggregateType, &event.AggregateID, &event.Type, &payload, &event.CreatedAt, &event.PublishedAt)
+		if err != nil {
+			return nil, err
+		}
+		event.Payload = json.RawMessage(payload)
+		events = append(events, &event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// MarkPublished records that every sink accepted a domain event.
+func (s *OutboxModel) MarkPublished(event *DomainEvent) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	now := time.Now().UTC()
+	query := `UPDATE outbox SET published_at = $1 WHERE id = $2 AND published_at IS NULL`
+	if _, err := s.DB.ExecContext(ctx, query, now, event.ID); err != nil {
+		return err
+	}
+	event.PublishedAt = &now
+	return nil
+}
+
+// Purge removes domain events published before cutoff and returns how
+// many were removed.
+func (s *OutboxModel) Purge(cutoff time.Time) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	res, err := s.DB.ExecContext(ctx, `DELETE FROM outbox WHERE published_at IS NOT NULL AND published_at < $1`, cutoff.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
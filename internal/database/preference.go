@@ -0,0 +1,62 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// NotificationPreferences say how a user wants to hear about the events
+// they attend. Reminders turns event reminders off altogether; Email,
+// Webhook and InApp choose the channels notifications arrive through.
+// Users who never set them get everything.
+type NotificationPreferences struct {
+	Reminders bool       `json:"reminders"`
+	Email     bool       `json:"email"`
+	Webhook   bool       `json:"webhook"`
+	InApp     bool       `json:"in_app"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+}
+
+// DefaultNotificationPreferences are the preferences of a user who never
+// set any.
+func DefaultNotificationPreferences() NotificationPreferences {
+	return NotificationPreferences{Reminders: true, Email: true, Webhook: true, InApp: true}
+}
+
+// GetPreferences returns the notification preferences of a user, or the
+// defaults when they never set any.
+func (s *UserModel) GetPreferences(userID int) (*NotificationPreferences, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	preferences := DefaultNotificationPreferences()
+	query := `SELECT reminders, email, webhook, in_app, updated_at FROM notification_preferences WHERE user_id = $1`
+	err := s.ReadDB.QueryRowContext(ctx, query, userID).Scan(
+		&preferences.Reminders, &preferences.Email, &preferences.Webhook, &preferences.InApp, &preferences.UpdatedAt)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	return &preferences, nil
+}
+
+// SetPreferences stores the notification preferences of a user.
+func (s *UserModel) SetPreferences(userID int, preferences *NotificationPreferences) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	now := time.Now().UTC()
+	query := `
+		INSERT INTO notification_preferences (user_id, reminders, email, webhook, in_app, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (user_id) DO UPDATE SET
+			reminders = excluded.reminders, email = excluded.email, webhook = excluded.webhook,
+			in_app = excluded.in_app, updated_at = excluded.updated_at`
+	_, err := s.DB.ExecContext(ctx, query, userID, preferences.Reminders, preferences.Email, preferences.Webhook, preferences.InApp, now)
+	if err != nil {
+		return err
+	}
+	preferences.UpdatedAt = &now
+	return nil
+}
@@ -0,0 +1,124 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+)
+
+// Promo code kinds. A percent code takes Amount percent off the price; a
+// fixed code takes Amount minor units off, never going below zero.
+const (
+	PromoKindPercent = "percent"
+	PromoKindFixed   = "fixed"
+)
+
+type PromoCodeModel struct {
+	DB     *sql.DB
+	ReadDB *sql.DB
+}
+
+type PromoCode struct {
+	ID      int    `json:"id"`
+	EventID int    `json:"event_id"`
+	Code    string `json:"code" binding:"required,min=3,max=32,alphanum"`
+	Kind    string `json:"kind" binding:"required,oneof=percent fixed"`
+	Amount  int64  `json:"amount" binding:"required,min=1"`
+	// MaxUses caps the paid orders and unexpired reservations using the
+	// code; nil means unlimited.
+	MaxUses *int `json:"max_uses,omitempty" binding:"omitempty,min=1"`
+	// Uses is computed on read.
+	Uses int `json:"uses" binding:"-"`
+}
+
+// NormalizePromoCode makes codes case-insensitive.
+func NormalizePromoCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// Apply returns price with the code's discount taken off.
+func (p *PromoCode) Apply(price int64) int64 {
+	var discount int64
+	switch p.Kind {
+	case PromoKindPercent:
+		discount = price * min(p.Amount, 100) / 100
+	case PromoKindFixed:
+		discount = p.Amount
+	}
+	return max(price-discount, 0)
+}
+
+// promoUses counts the orders holding a use of promo code p at $now.
+const promoUses = `(SELECT COUNT(*) FROM orders o WHERE o.promo_code_id = p.id
+	AND (o.status = 'paid' OR (o.status = 'pending' AND o.expires_at > $now)))`
+
+func (s *PromoCodeModel) Insert(promo *PromoCode) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	promo.Code = NormalizePromoCode(promo.Code)
+	query := `
+		INSERT INTO promo_codes (event_id, code, kind, amount, max_uses, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id`
+	return s.DB.QueryRowContext(ctx, query, promo.EventID, promo.Code, promo.Kind, promo.Amount, promo.MaxUses, time.Now().UTC()).Scan(&promo.ID)
+}
+
+// GetByEvent lists the promo codes of an event.
+func (s *PromoCodeModel) GetByEvent(eventID int) ([]*PromoCode, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `
+		SELECT p.id, p.event_id, p.code, p.kind, p.amount, p.max_uses, ` + promoUses + `
+		FROM promo_codes p WHERE p.event_id = $event ORDER BY p.code`
+	rows, err := s.ReadDB.QueryContext(ctx, query, sql.Named("event", eventID), sql.Named("now", time.Now().UTC()))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	promos := []*PromoCode{}
+	for rows.Next() {
+		var promo PromoCode
+		if err := rows.Scan(&promo.ID, &promo.EventID, &promo.Code, &promo.Kind, &promo.Amount, &promo.MaxUses, &promo.Uses); err != nil {
+			return nil, err
+		}
+		promos = append(promos, &promo)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return promos, nil
+}
+
+// GetByCode returns the event's promo code, or nil when there is none.
+func (s *PromoCodeModel) GetByCode(eventID int, code string) (*PromoCode, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `
+		SELECT p.id, p.event_id, p.code, p.kind, p.amount, p.max_uses, ` + promoUses + `
+		FROM promo_codes p WHERE p.event_id = $event AND p.code = $code`
+	var promo PromoCode
+	err := s.ReadDB.QueryRowContext(ctx, query, sql.Named("event", eventID), sql.Named("code", NormalizePromoCode(code)), sql.Named("now", time.Now().UTC())).
+		Scan(&promo.ID, &promo.EventID, &promo.Code, &promo.Kind, &promo.Amount, &promo.MaxUses, &promo.Uses)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &promo, nil
+}
+
+// Delete removes a promo code. Orders that used it keep their price.
+func (s *PromoCodeModel) Delete(eventID, id int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `DELETE FROM promo_codes WHERE id = $1 AND event_id = $2`
+	return expectOneRow(s.DB.ExecContext(ctx, query, id, eventID))
+}
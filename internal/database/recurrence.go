@@ -0,0 +1,574 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/teambition/rrule-go"
+)
+
+// ErrNotAnOccurrence is returned when a date is not produced by an
+// event's recurrence rule (or is one of its exception dates).
+var ErrNotAnOccurrence = errors.New("date is not an occurrence of this event")
+
+// Occurrence is one instance of an event. For a single event there is
+// exactly one, on its own date; recurring events expand into many, and
+// any instance edited on its own carries Modified.
+type Occurrence struct {
+	EventID        int      `json:"event_id"`
+	OwnerId        int      `json:"owner_id"`
+	OccurrenceDate string   `json:"occurrence_date"`
+	Name           string   `json:"name"`
+	Description    string   `json:"description"`
+	Date           string   `json:"date"`
+	Location       string   `json:"location"`
+	Latitude       *float64 `json:"latitude,omitempty"`
+	Longitude      *float64 `json:"longitude,omitempty"`
+	Tags           []string `json:"tags,omitempty"`
+	Status         string   `json:"status"`
+	Recurring      bool     `json:"recurring"`
+	Modified       bool     `json:"modified"`
+}
+
+// ParseRRule parses an RRULE value, with or without the "RRULE:" prefix.
+// DTSTART is not accepted here because it always comes from the event date.
+func ParseRRule(rule string) (*rrule.ROption, error) {
+	rule = strings.TrimPrefix(strings.TrimSpace(rule), "RRULE:")
+	if strings.ContainsAny(rule, "\n") || strings.Contains(strings.ToUpper(rule), "DTSTART") {
+		return nil, errors.New("rrule must not contain DTSTART")
+	}
+	return rrule.StrToROption(rule)
+}
+
+func (e *Event) IsRecurring() bool {
+	return e.RRule != ""
+}
+
+// OccurrenceDates returns the dates of e between from and to, inclusive,
+// with exception dates removed.
+func (e *Event) OccurrenceDates(from, to time.Time) ([]time.Time, error) {
+	start, err := time.Parse(time.DateOnly, e.Date)
+	if err != nil {
+		return nil, err
+	}
+	if !e.IsRecurring() {
+		if start.Before(from) || start.After(to) {
+			return nil, nil
+		}
+		return []time.Time{start}, nil
+	}
+
+	rule, err := e.rule(start)
+	if err != nil {
+		return nil, err
+	}
+	var dates []time.Time
+	for _, date := range rule.Between(from, to, true) {
+		if !slices.Contains(e.ExDates, date.Format(time.DateOnly)) {
+			dates = append(dates, date)
+		}
+	}
+	return dates, nil
+}
+
+// HasOccurrence reports whether date (YYYY-MM-DD) is an occurrence of e.
+func (e *Event) HasOccurrence(date string) bool {
+	day, err := time.Parse(time.DateOnly, date)
+	if err != nil {
+		return false
+	}
+	dates, err := e.OccurrenceDates(day, day)
+	return err == nil && len(dates) == 1
+}
+
+func (e *Event) rule(start time.Time) (*rrule.RRule, error) {
+	option, err := ParseRRule(e.RRule)
+	if err != nil {
+		return nil, err
+	}
+	option.Dtstart = start
+	return rrule.NewRRule(*option)
+}
+
+// SplitRRule cuts a rule that starts on start into the part before at and
+// the part from at onwards. COUNT is divided between the two halves;
+// otherwise the first half ends with UNTIL the day before at.
+func SplitRRule(rule string, start, at time.Time) (before string, after string, err error) {
+	option, err := ParseRRule(rule)
+	if err != nil {
+		return "", "", err
+	}
+	first := *option
+	first.Dtstart = start
+	firstRule, err := rrule.NewRRule(first)
+	if err != nil {
+		return "", "", err
+	}
+	earlier := len(firstRule.Between(start, at.Add(-time.Second), true))
+	if earlier == 0 {
+		return "", "", errors.New("cannot split a series at its first occurrence")
+	}
+
+	second := *option
+	if option.Count > 0 {
+		first.Count = earlier
+		second.Count = option.Count - earlier
+	} else {
+		first.Until = at.Add(-time.Second)
+	}
+	first.Dtstart = time.Time{}
+	return first.RRuleString(), second.RRuleString(), nil
+}
+
+// GetOccurrences expands every event with an instance between from and to
+// into its occurrences, ordered by date.
+func (s *EventModel) GetOccurrences(from, to time.Time, filter EventFilter) ([]*Occurrence, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	where, args := filter.where()
+	query := `
+		SELECT ` + eventColumns + ` FROM events e
+		WHERE ` + where + `
+		AND ((e.rrule = '' AND e.date BETWEEN ? AND ?) OR (e.rrule != '' AND e.date <= ?))`
+	args = append(args, from.Format(time.DateOnly), to.Format(time.DateOnly), to.Format(time.DateOnly))
+	rows, err := s.ReadDB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []*Event
+	for rows.Next() {
+		var event Event
+		if err := scanEvent(rows, &event); err != nil {
+			return nil, err
+		}
+		events = append(events, &event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	overrides, err := s.overridesBetween(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	occurrences := []*Occurrence{}
+	for _, event := range events {
+		dates, err := event.OccurrenceDates(from, to)
+		if err != nil {
+			return nil, fmt.Errorf("event %d: %w", event.ID, err)
+		}
+		for _, date := range dates {
+			occurrence := newOccurrence(event, date.Format(time.DateOnly))
+			if override, ok := overrides[overrideKey{event.ID, occurrence.OccurrenceDate}]; ok {
+				occurrence.apply(override)
+			}
+			occurrences = append(occurrences, occurrence)
+		}
+	}
+	sort.SliceStable(occurrences, func(i, j int) bool {
+		if occurrences[i].Date != occurrences[j].Date {
+			return occurrences[i].Date < occurrences[j].Date
+		}
+		return occurrences[i].EventID < occurrences[j].EventID
+	})
+	return occurrences, nil
+}
+
+// GetOccurrence returns a single instance of event, with its override
+// applied when there is one.
+func (s *EventModel) GetOccurrence(event *Event, date string) (*Occurrence, error) {
+	if !event.HasOccurrence(date) {
+		return nil, ErrNotAnOccurrence
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	occurrence := newOccurrence(event, date)
+	override, err := findOverride(ctx, s.ReadDB, event.ID, date)
+	if err != nil {
+		return nil, err
+	}
+	if override != nil {
+		occurrence.apply(override)
+	}
+	return occurrence, nil
+}
+
+// SaveOccurrence stores occurrence as an edit of that single instance of
+// event. The series is bumped to a new version, with the same check as
+// Update, since its occurrences are part of what it looks like.
+func (s *EventModel) SaveOccurrence(actor Actor, event *Event, occurrence *Occurrence) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before := newOccurrence(event, occurrence.OccurrenceDate)
+	override, err := findOverride(ctx, tx, event.ID, occurrence.OccurrenceDate)
+	if err != nil {
+		return err
+	}
+	if override != nil {
+		before.apply(override)
+	}
+
+	err = tx.QueryRowContext(ctx,
+		`UPDATE events SET version = version + 1 WHERE id = $1 AND version = $2 AND deleted_at IS NULL RETURNING version`,
+		event.ID, event.Version,
+	).Scan(&event.Version)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrEditConflict
+		}
+		return err
+	}
+
+	query := `
+		INSERT INTO event_occurrences (event_id, occurrence_date, name, description, date, location)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (event_id, occurrence_date) DO UPDATE SET
+			name = excluded.name, description = excluded.description,
+			date = excluded.date, location = excluded.location`
+	_, err = tx.ExecContext(ctx, query,
+		occurrence.EventID,
+		occurrence.OccurrenceDate,
+		occurrence.Name,
+		occurrence.Description,
+		occurrence.Date,
+		occurrence.Location,
+	)
+	if err != nil {
+		return err
+	}
+	if err := recordEventChange(ctx, tx, DomainEventUpdated, event.ID); err != nil {
+		return err
+	}
+	if err := insertAudit(ctx, tx, actor, AuditEntityEvent, event.ID, event.ID, AuditUpdate, before, occurrence); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	occurrence.Modified = true
+	return nil
+}
+
+// CancelOccurrence removes a single instance by adding it to the event's
+// exception dates and dropping its override and attendees.
+func (s *EventModel) CancelOccurrence(actor Actor, event *Event, date string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	previous, err := eventBeforeChange(ctx, tx, event.ID)
+	if err != nil {
+		return err
+	}
+
+	exdates := append(slices.Clone(event.ExDates), date)
+	err = tx.QueryRowContext(ctx,
+		`UPDATE events SET exdates = $1, version = version + 1 WHERE id = $2 AND version = $3 RETURNING version`,
+		joinDates(exdates), event.ID, event.Version,
+	).Scan(&event.Version)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrEditConflict
+		}
+		return err
+	}
+	if err := deleteOccurrenceData(ctx, tx, actor, event.ID, "occurrence_date = $2", date); err != nil {
+		return err
+	}
+	if err := recordEventChange(ctx, tx, DomainEventUpdated, event.ID); err != nil {
+		return err
+	}
+	if err := auditEventChange(ctx, tx, actor, AuditUpdate, previous, event.ID); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	event.ExDates = splitDates(joinDates(exdates))
+	return nil
+}
+
+// EndSeries stops a recurring event before at, dropping the overrides and
+// attendees of the occurrences that no longer exist.
+func (s *EventModel) EndSeries(actor Actor, event *Event, at time.Time) error {
+	start, err := time.Parse(time.DateOnly, event.Date)
+	if err != nil {
+		return err
+	}
+	before, _, err := SplitRRule(event.RRule, start, at)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	previous, err := eventBeforeChange(ctx, tx, event.ID)
+	if err != nil {
+		return err
+	}
+
+	err = tx.QueryRowContext(ctx,
+		`UPDATE events SET rrule = $1, version = version + 1 WHERE id = $2 AND version = $3 RETURNING version`,
+		before, event.ID, event.Version,
+	).Scan(&event.Version)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrEditConflict
+		}
+		return err
+	}
+	if err := deleteOccurrenceData(ctx, tx, actor, event.ID, "occurrence_date >= $2", at.Format(time.DateOnly)); err != nil {
+		return err
+	}
+	if err := recordEventChange(ctx, tx, DomainEventUpdated, event.ID); err != nil {
+		return err
+	}
+	if err := auditEventChange(ctx, tx, actor, AuditUpdate, previous, event.ID); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	event.RRule = before
+	return nil
+}
+
+// SplitSeries implements "this and following": master keeps the
+// occurrences before at and next, which must already carry the new
+// fields, becomes a new series starting on next.Date. Exception dates,
+// overrides and attendees from at onwards move to the new series, shifted
+// by however many days next.Date moved away from at. The new series gets
+// copies of the organizers, tags, registration form and ticket types;
+// promo codes stay with master, so their use limits are not doubled.
+func (s *EventModel) SplitSeries(actor Actor, master *Event, at time.Time, next *Event) error {
+	start, err := time.Parse(time.DateOnly, master.Date)
+	if err != nil {
+		return err
+	}
+	nextStart, err := time.Parse(time.DateOnly, next.Date)
+	if err != nil {
+		return err
+	}
+	shiftDays := int(nextStart.Sub(at).Hours() / 24)
+
+	before, after, err := SplitRRule(master.RRule, start, at)
+	if err != nil {
+		return err
+	}
+	if next.RRule == master.RRule {
+		next.RRule = after
+	}
+	var keep, moved []string
+	for _, date := range master.ExDates {
+		if date < at.Format(time.DateOnly) {
+			keep = append(keep, date)
+		} else if day, err := time.Parse(time.DateOnly, date); err == nil {
+			moved = append(moved, day.AddDate(0, 0, shiftDays).Format(time.DateOnly))
+		}
+	}
+	next.ExDates = moved
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	previous, err := eventBeforeChange(ctx, tx, master.ID)
+	if err != nil {
+		return err
+	}
+
+	err = tx.QueryRowContext(ctx,
+		`UPDATE events SET rrule = $1, exdates = $2, version = version + 1 WHERE id = $3 AND version = $4 RETURNING version`,
+		before, joinDates(keep), master.ID, master.Version,
+	).Scan(&master.Version)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrEditConflict
+		}
+		return err
+	}
+
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO events (owner_id, name, description, date, location, latitude, longitude, rrule, exdates, status, visibility)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		RETURNING id, version`,
+		next.OwnerId, next.Name, next.Description, next.Date, next.Location, next.Latitude, next.Longitude, next.RRule, joinDates(next.ExDates), next.Status, next.Visibility,
+	).Scan(&next.ID, &next.Version)
+	if err != nil {
+		return err
+	}
+	for _, query := range []string{
+		`INSERT INTO event_organizers (event_id, user_id, role, created_at)
+			SELECT $1, user_id, role, created_at FROM event_organizers WHERE event_id = $2`,
+		`INSERT INTO event_tags (event_id, tag_id) SELECT $1, tag_id FROM event_tags WHERE event_id = $2`,
+		`INSERT INTO event_forms (event_id, questions, updated_at)
+			SELECT $1, questions, updated_at FROM event_forms WHERE event_id = $2`,
+		`INSERT INTO ticket_types (event_id, name, price_minor, currency, quantity, sales_start, sales_end, created_at)
+			SELECT $1, name, price_minor, currency, quantity, sales_start, sales_end, created_at FROM ticket_types WHERE event_id = $2 ORDER BY id`,
+	} {
+		if _, err := tx.ExecContext(ctx, query, next.ID, master.ID); err != nil {
+			return err
+		}
+	}
+
+	shift := fmt.Sprintf("%+d days", shiftDays)
+	for _, table := range []string{"event_occurrences", "attendees"} {
+		query := fmt.Sprintf(`
+			UPDATE %s SET event_id = $1, occurrence_date = date(occurrence_date, $2)
+			WHERE event_id = $3 AND occurrence_date >= $4`, table)
+		if _, err := tx.ExecContext(ctx, query, next.ID, shift, master.ID, at.Format(time.DateOnly)); err != nil {
+			return err
+		}
+	}
+	if err := recordEventChange(ctx, tx, DomainEventUpdated, master.ID); err != nil {
+		return err
+	}
+	if err := recordEventChange(ctx, tx, DomainEventCreated, next.ID); err != nil {
+		return err
+	}
+	if err := auditEventChange(ctx, tx, actor, AuditUpdate, previous, master.ID); err != nil {
+		return err
+	}
+	if err := auditEventChange(ctx, tx, actor, AuditCreate, nil, next.ID); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	master.RRule = before
+	master.ExDates = keep
+	return nil
+}
+
+type overrideKey struct {
+	eventID int
+	date    string
+}
+
+func (s *EventModel) overridesBetween(ctx context.Context, from, to time.Time) (map[overrideKey]*Occurrence, error) {
+	query := `
+		SELECT event_id, occurrence_date, name, description, date, location
+		FROM event_occurrences WHERE occurrence_date BETWEEN $1 AND $2`
+	rows, err := s.ReadDB.QueryContext(ctx, query, from.Format(time.DateOnly), to.Format(time.DateOnly))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	overrides := map[overrideKey]*Occurrence{}
+	for rows.Next() {
+		var o Occurrence
+		if err := rows.Scan(&o.EventID, &o.OccurrenceDate, &o.Name, &o.Description, &o.Date, &o.Location); err != nil {
+			return nil, err
+		}
+		overrides[overrideKey{o.EventID, o.OccurrenceDate}] = &o
+	}
+	return overrides, rows.Err()
+}
+
+func findOverride(ctx context.Context, db queryRower, eventID int, date string) (*Occurrence, error) {
+	query := `
+		SELECT event_id, occurrence_date, name, description, date, location
+		FROM event_occurrences WHERE event_id = $1 AND occurrence_date = $2`
+	var o Occurrence
+	err := db.QueryRowContext(ctx, query, eventID, date).Scan(&o.EventID, &o.OccurrenceDate, &o.Name, &o.Description, &o.Date, &o.Location)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &o, nil
+}
+
+// deleteOccurrenceData drops the overrides and attendees of the
+// occurrences matching condition, auditing each removed attendee.
+func deleteOccurrenceData(ctx context.Context, tx *sql.Tx, actor Actor, eventID int, condition string, date string) error {
+	if err := auditRemovedAttendees(ctx, tx, actor, "event_id = $1 AND "+condition, eventID, date); err != nil {
+		return err
+	}
+	for _, table := range []string{"event_occurrences", "attendees"} {
+		query := fmt.Sprintf(`DELETE FROM %s WHERE event_id = $1 AND %s`, table, condition)
+		if _, err := tx.ExecContext(ctx, query, eventID, date); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func newOccurrence(event *Event, date string) *Occurrence {
+	return &Occurrence{
+		EventID:        event.ID,
+		OwnerId:        event.OwnerId,
+		OccurrenceDate: date,
+		Name:           event.Name,
+		Description:    event.Description,
+		Date:           date,
+		Location:       event.Location,
+		Latitude:       event.Latitude,
+		Longitude:      event.Longitude,
+		Tags:           event.Tags,
+		Status:         event.Status,
+		Recurring:      event.IsRecurring(),
+	}
+}
+
+func (o *Occurrence) apply(override *Occurrence) {
+	o.Name = override.Name
+	o.Description = override.Description
+	o.Date = override.Date
+	if override.Location != o.Location {
+		// Overrides carry no coordinates, so the series' ones no longer apply.
+		o.Latitude, o.Longitude = nil, nil
+	}
+	o.Location = override.Location
+	o.Modified = true
+}
+
+func splitDates(dates string) []string {
+	if dates == "" {
+		return nil
+	}
+	return strings.Split(dates, ",")
+}
+
+func joinDates(dates []string) string {
+	sorted := slices.Clone(dates)
+	slices.Sort(sorted)
+	return strings.Join(slices.Compact(sorted), ",")
+}
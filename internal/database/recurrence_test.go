@@ -0,0 +1,183 @@
+package database
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func day(t *testing.T, date string) time.Time {
+	t.Helper()
+	d, err := time.Parse(time.DateOnly, date)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+// dates lists the occurrences of a rule starting on start, as far as a
+// couple of years out.
+func dates(t *testing.T, start, rule string) []string {
+	t.Helper()
+	event := &Event{Date: start, RRule: rule}
+	occurrences, err := event.OccurrenceDates(day(t, start), day(t, start).AddDate(2, 0, 0))
+	if err != nil {
+		t.Fatalf("OccurrenceDates(%q): %v", rule, err)
+	}
+	var out []string
+	for _, d := range occurrences {
+		out = append(out, d.Format(time.DateOnly))
+	}
+	return out
+}
+
+func TestSplitRRule(t *testing.T) {
+	tests := []struct {
+		name       string
+		rule       string
+		start, at  string
+		wantBefore []string
+		wantAfter  []string
+	}{
+		{
+			name:       "count",
+			rule:       "FREQ=WEEKLY;COUNT=5",
+			start:      "2026-11-03",
+			at:         "2026-11-17",
+			wantBefore: []string{"2026-11-03", "2026-11-10"},
+			wantAfter:  []string{"2026-11-17", "2026-11-24", "2026-12-01"},
+		},
+		{
+			name:       "until",
+			rule:       "FREQ=DAILY;UNTIL=20261107T000000Z",
+			start:      "2026-11-03",
+			at:         "2026-11-05",
+			wantBefore: []string{"2026-11-03", "2026-11-04"},
+			wantAfter:  []string{"2026-11-05", "2026-11-06", "2026-11-07"},
+		},
+		{
+			name:       "between occurrences",
+			rule:       "FREQ=WEEKLY;COUNT=4",
+			start:      "2026-11-03",
+			at:         "2026-11-12",
+			wantBefore: []string{"2026-11-03", "2026-11-10"},
+			wantAfter:  []string{"2026-11-12", "2026-11-19"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before, after, err := SplitRRule(tt.rule, day(t, tt.start), day(t, tt.at))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := dates(t, tt.start, before); !slices.Equal(got, tt.wantBefore) {
+				t.Errorf("before %q = %v, want %v", before, got, tt.wantBefore)
+			}
+			if got := dates(t, tt.at, after); !slices.Equal(got, tt.wantAfter) {
+				t.Errorf("after %q = %v, want %v", after, got, tt.wantAfter)
+			}
+		})
+	}
+
+	if _, _, err := SplitRRule("FREQ=WEEKLY;COUNT=5", day(t, "2026-11-03"), day(t, "2026-11-03")); err == nil {
+		t.Error("SplitRRule at the first occurrence succeeded")
+	}
+}
+
+func TestSplitSeries(t *testing.T) {
+	models := newTestModels(t)
+	owner := &User{Username: "studyhost", Email: "studyhost@example.com", Password: "x"}
+	early := &User{Username: "november", Email: "november@example.com", Password: "x"}
+	late := &User{Username: "december", Email: "december@example.com", Password: "x"}
+	for _, user := range []*User{owner, early, late} {
+		if err := models.Users.Insert(Actor{}, user); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	master := &Event{
+		OwnerId:     owner.ID,
+		Name:        "Go study group",
+		Description: "Reading through the spec, one chapter a week",
+		Date:        "2026-11-03",
+		Location:    "Bahir Dar",
+		RRule:       "FREQ=WEEKLY;COUNT=6",
+		ExDates:     []string{"2026-11-10", "2026-12-01"},
+		Status:      EventStatusPublished,
+	}
+	if err := models.Events.Insert(Actor{}, master); err != nil {
+		t.Fatal(err)
+	}
+	stale := *master
+	if err := models.Tags.SetForEvent(Actor{}, master, []string{"go"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := models.TicketTypes.Insert(&TicketType{EventID: master.ID, Name: "General", Currency: "USD", Quantity: 10}); err != nil {
+		t.Fatal(err)
+	}
+	if err := models.Forms.Set(Actor{}, master.ID, []Question{{Key: "diet", Label: "Diet", Type: "text"}}); err != nil {
+		t.Fatal(err)
+	}
+	for _, attendee := range []*Attendee{
+		{EventID: master.ID, UserID: early.ID, OccurrenceDate: "2026-11-03"},
+		{EventID: master.ID, UserID: late.ID, OccurrenceDate: "2026-11-24"},
+	} {
+		if err := models.Attendees.Insert(Actor{}, attendee); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	master, err := models.Events.GetByID(master.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The new series starts a day later than the occurrence it replaces.
+	next := *master
+	next.Date = "2026-11-18"
+	next.Name = "Go study group, new venue"
+	conflicting := next
+	if err := models.Events.SplitSeries(Actor{}, &stale, day(t, "2026-11-17"), &conflicting); !errors.Is(err, ErrEditConflict) {
+		t.Fatalf("SplitSeries with a stale version = %v, want ErrEditConflict", err)
+	}
+	if err := models.Events.SplitSeries(Actor{}, master, day(t, "2026-11-17"), &next); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := dates(t, master.Date, master.RRule), []string{"2026-11-03", "2026-11-10"}; !slices.Equal(got, want) {
+		t.Errorf("master rule %q covers %v, want %v", master.RRule, got, want)
+	}
+	if !slices.Equal(master.ExDates, []string{"2026-11-10"}) {
+		t.Errorf("master keeps exdates %v, want [2026-11-10]", master.ExDates)
+	}
+	if !slices.Equal(next.ExDates, []string{"2026-12-02"}) {
+		t.Errorf("new series exdates = %v, want the moved [2026-12-02]", next.ExDates)
+	}
+	if got, want := dates(t, next.Date, next.RRule), []string{"2026-11-18", "2026-11-25", "2026-12-02", "2026-12-09"}; !slices.Equal(got, want) {
+		t.Errorf("new series rule %q covers %v, want %v", next.RRule, got, want)
+	}
+
+	if a, err := models.Attendees.GetByEventAndUserId(master.ID, early.ID, "2026-11-03"); err != nil || a == nil {
+		t.Errorf("attendee before the split left master: %v, %v", a, err)
+	}
+	if a, err := models.Attendees.GetByEventAndUserId(next.ID, late.ID, "2026-11-25"); err != nil || a == nil {
+		t.Errorf("attendee after the split not moved to the shifted occurrence: %v, %v", a, err)
+	}
+
+	copied, err := models.Events.GetByID(next.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(copied.Tags, []string{"go"}) {
+		t.Errorf("new series tags = %v, want [go]", copied.Tags)
+	}
+	if role, err := models.Organizers.GetRole(next.ID, owner.ID); err != nil || role != "owner" {
+		t.Errorf("new series owner role = %q, %v", role, err)
+	}
+	if ticketTypes, err := models.TicketTypes.GetByEvent(next.ID); err != nil || len(ticketTypes) != 1 || ticketTypes[0].Name != "General" {
+		t.Errorf("new series ticket types = %v, %v", ticketTypes, err)
+	}
+	if questions, err := models.Forms.Get(next.ID); err != nil || len(questions) != 1 || questions[0].Key != "diet" {
+		t.Errorf("new series form = %v, %v", questions, err)
+	}
+}
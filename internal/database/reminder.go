@@ -0,0 +1,187 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+	"time"
+)
+
+// JobReminderScan is the type of the recurring job that sends the
+// reminders that are due.
+const JobReminderScan = "reminder.scan"
+
+// attendanceStartsOn is the date attendee a's occurrence of event e starts
+// on. Queries using it left join the occurrence's override as oc.
+const attendanceStartsOn = `COALESCE(oc.date, NULLIF(a.occurrence_date, ''), date(e.date))`
+
+type ReminderModel struct {
+	DB     *sql.DB
+	ReadDB *sql.DB
+}
+
+// Reminder tells an attendee that an event, or the occurrence of a
+// recurring one they attend, starts on StartsOn. Events carry no time of
+// day, so they are taken to start at the beginning of their date in UTC
+// and Offset counts back from there.
+//
+// A reminder is sent at most once for each date an occurrence starts on,
+// so moving an event reschedules its reminders while restarts and retried
+// scans never send one twice.
+type Reminder struct {
+	EventID        int
+	OccurrenceDate string
+	UserID         int
+	Email          string
+	Name           string
+	Location       string
+	StartsOn       string
+	Offset         time.Duration
+	Preferences    NotificationPreferences
+}
+
+// StartsAt is when the reminded occurrence starts.
+func (r *Reminder) StartsAt() time.Time {
+	start, _ := time.Parse(time.DateOnly, r.StartsOn)
+	return start
+}
+
+// Due returns the reminders to send at now, one per attendance of a
+// published event starting within the largest of offsets, for attendees
+// who have not turned reminders off. Each gets the smallest offset that
+// has passed, unless a reminder that close to the start already went out,
+// so an attendee who signs up late gets one reminder rather than all the
+// ones they missed.
+func (s *ReminderModel) Due(now time.Time, offsets []time.Duration) ([]*Reminder, error) {
+	if len(offsets) == 0 {
+		return nil, nil
+	}
+	offsets = slices.Sorted(slices.Values(offsets))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	now = now.UTC()
+	query := `
+		WITH attendances AS (
+			SELECT a.event_id, a.occurrence_date, a.user_id, u.email,
+				COALESCE(oc.name, e.name) AS name, COALESCE(oc.location, e.location) AS location,
+				` + attendanceStartsOn + ` AS starts_on,
+				COALESCE(p.email, 1) AS email_enabled, COALESCE(p.webhook, 1) AS webhook_enabled,
+				COALESCE(p.in_app, 1) AS in_app_enabled
+			FROM attendees a
+			JOIN events e ON e.id = a.event_id AND e.deleted_at IS NULL AND e.status = 'published'
+			JOIN users u ON u.id = a.user_id AND u.deleted_at IS NULL
+			LEFT JOIN event_occurrences oc ON oc.event_id = a.event_id AND oc.occurrence_date = a.occurrence_date
+			LEFT JOIN notification_preferences p ON p.user_id = a.user_id
+			WHERE COALESCE(p.reminders, 1)
+		)
+		SELECT t.event_id, t.occurrence_date, t.user_id, t.email, t.name, t.location, t.starts_on,
+			t.email_enabled, t.webhook_enabled, t.in_app_enabled,
+			(SELECT MIN(r.offset_seconds) FROM event_reminders r
+				WHERE r.event_id = t.event_id AND r.occurrence_date = t.occurrence_date
+				AND r.user_id = t.user_id AND r.starts_on = t.starts_on)
+		FROM attendances t
+		WHERE t.starts_on > $from AND t.starts_on <= $to
+		ORDER BY t.starts_on, t.event_id, t.user_id`
+	rows, err := s.ReadDB.QueryContext(ctx, query,
+		sql.Named("from", now.Format(time.DateOnly)),
+		sql.Named("to", now.Add(offsets[len(offsets)-1]).Format(time.DateOnly)),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reminders []*Reminder
+	for rows.Next() {
+		var r Reminder
+		var sent sql.NullInt64
+		r.Preferences.Reminders = true
+		err := rows.Scan(&r.EventID, &r.OccurrenceDate, &r.UserID, &r.Email, &r.Name, &r.Location, &r.StartsOn,
+			&r.Preferences.Email, &r.Preferences.Webhook, &r.Preferences.InApp, &sent)
+		if err != nil {
+			return nil, err
+		}
+		start := r.StartsAt()
+		for _, offset := range offsets {
+			if start.Add(-offset).After(now) {
+				continue
+			}
+			if !sent.Valid || time.Duration(sent.Int64)*time.Second > offset {
+				r.Offset = offset
+				reminders = append(reminders, &r)
+			}
+			break
+		}
+	}
+	return reminders, rows.Err()
+}
+
+// ReminderDeliveries is what sending a reminder queues: Jobs to run,
+// Notification for the in-app inbox, and WebhookPayload for every webhook
+// of the attendee subscribed to WebhookType. Each is optional.
+type ReminderDeliveries struct {
+	Jobs           []*Job
+	Notification   *Notification
+	WebhookType    string
+	WebhookPayload []byte
+}
+
+// Send records that reminder went out and queues its deliveries in the
+// same transaction. It returns false, queuing nothing, when the reminder
+// was already sent.
+func (s *ReminderModel) Send(reminder *Reminder, deliveries ReminderDeliveries) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO event_reminders (event_id, occurrence_date, user_id, starts_on, offset_seconds, sent_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT DO NOTHING`
+	res, err := tx.ExecContext(ctx, query, reminder.EventID, reminder.OccurrenceDate, reminder.UserID,
+		reminder.StartsOn, int64(reminder.Offset/time.Second), time.Now().UTC())
+	if err != nil {
+		return false, err
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return false, err
+	}
+
+	for _, job := range deliveries.Jobs {
+		if err := insertJob(ctx, tx, job); err != nil {
+			return false, err
+		}
+	}
+	if deliveries.Notification != nil {
+		_, err := insertNotifications(ctx, tx, deliveries.Notification, `SELECT $user AS user_id`, sql.Named("user", reminder.UserID))
+		if err != nil {
+			return false, err
+		}
+	}
+	if deliveries.WebhookPayload != nil {
+		if _, err := enqueueForUser(ctx, tx, reminder.UserID, deliveries.WebhookType, deliveries.WebhookPayload); err != nil {
+			return false, err
+		}
+	}
+	return true, tx.Commit()
+}
+
+// Purge removes the record of reminders for occurrences that started
+// before cutoff, which can no longer be sent again.
+func (s *ReminderModel) Purge(cutoff time.Time) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	res, err := s.DB.ExecContext(ctx, `DELETE FROM event_reminders WHERE starts_on < $1`, cutoff.UTC().Format(time.DateOnly))
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
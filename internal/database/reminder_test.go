@@ -0,0 +1,138 @@
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+func insertMember(t *testing.T, models Models, name string) *User {
+	t.Helper()
+	user := &User{Username: name, Email: name + "@example.com", Password: "x"}
+	if err := models.Users.Insert(Actor{}, user); err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+// insertWorkshop inserts an event of owner on 2026-10-20, the day the
+// reminder tests count down to.
+func insertWorkshop(t *testing.T, models Models, owner *User, status string) *Event {
+	t.Helper()
+	event := &Event{OwnerId: owner.ID, Name: "Pottery workshop", Description: "Throwing clay for beginners", Date: "2026-10-20", Location: "Arba Minch", Status: status}
+	if err := models.Events.Insert(Actor{}, event); err != nil {
+		t.Fatal(err)
+	}
+	return event
+}
+
+func TestReminderDue(t *testing.T) {
+	models := newTestModels(t)
+	owner := insertMember(t, models, "potter")
+	early := insertMember(t, models, "early")
+	optedOut := insertMember(t, models, "optedout")
+	event := insertWorkshop(t, models, owner, EventStatusPublished)
+	draft := insertWorkshop(t, models, owner, EventStatusDraft)
+	for _, attendee := range []*Attendee{
+		{EventID: event.ID, UserID: early.ID},
+		{EventID: event.ID, UserID: optedOut.ID},
+		{EventID: draft.ID, UserID: early.ID},
+	} {
+		if err := models.Attendees.Insert(Actor{}, attendee); err != nil {
+			t.Fatal(err)
+		}
+	}
+	preferences := DefaultNotificationPreferences()
+	preferences.Reminders = false
+	if err := models.Users.SetPreferences(optedOut.ID, &preferences); err != nil {
+		t.Fatal(err)
+	}
+
+	offsets := []time.Duration{time.Hour, 24 * time.Hour}
+	due := func(now string) []*Reminder {
+		t.Helper()
+		at, err := time.Parse(time.RFC3339, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reminders, err := models.Reminders.Due(at, offsets)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return reminders
+	}
+	send := func(reminder *Reminder) bool {
+		t.Helper()
+		sent, err := models.Reminders.Send(reminder, ReminderDeliveries{
+			Notification: &Notification{Type: NotificationEventReminder, EventID: &reminder.EventID, Title: "Soon", Key: "reminder"},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sent
+	}
+
+	if reminders := due("2026-10-18T12:00:00Z"); len(reminders) != 0 {
+		t.Fatalf("Due two days ahead = %d reminders, want none", len(reminders))
+	}
+
+	reminders := due("2026-10-19T01:00:00Z")
+	if len(reminders) != 1 {
+		t.Fatalf("Due a day ahead = %d reminders, want 1 for the attendee who kept reminders on", len(reminders))
+	}
+	reminder := reminders[0]
+	if reminder.EventID != event.ID || reminder.UserID != early.ID || reminder.Offset != 24*time.Hour || reminder.StartsOn != "2026-10-20" {
+		t.Errorf("reminder = %+v", reminder)
+	}
+	if !send(reminder) {
+		t.Fatal("Send reported the reminder as already sent")
+	}
+	if send(reminder) {
+		t.Error("Send sent the same reminder twice")
+	}
+	if reminders := due("2026-10-19T12:00:00Z"); len(reminders) != 0 {
+		t.Errorf("Due after the day-ahead reminder went out = %d reminders, want none", len(reminders))
+	}
+
+	reminders = due("2026-10-19T23:30:00Z")
+	if len(reminders) != 1 || reminders[0].Offset != time.Hour {
+		t.Fatalf("Due an hour ahead = %+v, want the hour reminder", reminders)
+	}
+	if !send(reminders[0]) {
+		t.Fatal("Send of the hour reminder reported it as already sent")
+	}
+	if page, err := models.Notifications.List(early.ID, 0, 10, false); err != nil || len(page.Notifications) != 1 {
+		t.Errorf("inbox = %v, %v; want one reminder notification despite two reminders with the same key", page, err)
+	}
+
+	// Moving the event reschedules its reminders.
+	event, err := models.Events.GetByID(event.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	event.Date = "2026-10-21"
+	if err := models.Events.Update(Actor{}, event); err != nil {
+		t.Fatal(err)
+	}
+	if reminders := due("2026-10-20T00:30:00Z"); len(reminders) != 1 || reminders[0].StartsOn != "2026-10-21" || reminders[0].Offset != 24*time.Hour {
+		t.Errorf("Due after moving the event = %+v, want a day-ahead reminder for the new date", reminders)
+	}
+}
+
+func TestReminderDueLateSignup(t *testing.T) {
+	models := newTestModels(t)
+	owner := insertMember(t, models, "potter")
+	late := insertMember(t, models, "late")
+	event := insertWorkshop(t, models, owner, EventStatusPublished)
+	if err := models.Attendees.Insert(Actor{}, &Attendee{EventID: event.ID, UserID: late.ID}); err != nil {
+		t.Fatal(err)
+	}
+
+	at, _ := time.Parse(time.RFC3339, "2026-10-19T23:30:00Z")
+	reminders, err := models.Reminders.Due(at, []time.Duration{24 * time.Hour, time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reminders) != 1 || reminders[0].Offset != time.Hour {
+		t.Errorf("Due for a late signup = %+v, want only the hour reminder", reminders)
+	}
+}
@@ -0,0 +1,235 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math"
+	"time"
+)
+
+var (
+	// ErrNotReviewable is returned when a user did not check in to an
+	// occurrence of the event that is over.
+	ErrNotReviewable = errors.New("only checked-in attendees can review an event once it is over")
+	// ErrDuplicateReview is returned when a user already reviewed the event.
+	ErrDuplicateReview = errors.New("event already reviewed")
+	// ErrOwnEvent is returned when an organizer reviews their own event,
+	// which would raise their own reputation.
+	ErrOwnEvent = errors.New("organizers cannot review their own event")
+)
+
+// Reputation is worked out as a Bayesian average: every organizer starts
+// with reputationPriorWeight reviews of reputationPrior stars, so a few
+// reviews move the score less than many.
+const (
+	reputationPrior       = 3.0
+	reputationPriorWeight = 5.0
+)
+
+type ReviewModel struct {
+	DB     *sql.DB
+	ReadDB *sql.DB
+}
+
+// Review is an attendee's 1 to 5 star rating of an event they went to,
+// with an optional text and the organizers' reply.
+type Review struct {
+	ID        int        `json:"id"`
+	EventID   int        `json:"event_id"`
+	UserID    int        `json:"user_id"`
+	Username  string     `json:"username"`
+	Rating    int        `json:"rating"`
+	Body      string     `json:"body,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	Reply     *string    `json:"reply,omitempty"`
+	RepliedAt *time.Time `json:"replied_at,omitempty"`
+}
+
+// RatingSummary is the average and number of an event's ratings.
+type RatingSummary struct {
+	Average float64 `json:"average"`
+	Count   int     `json:"count"`
+}
+
+// Reputation sums up the reviews of every event a user owns. Score is the
+// average pulled towards 3 stars while there are few reviews; it is what
+// organizers should be ranked by.
+type Reputation struct {
+	Score   float64 `json:"score"`
+	Average float64 `json:"average"`
+	Reviews int     `json:"reviews"`
+	Events  int     `json:"events"`
+}
+
+// Profile is what anyone may see of a user.
+type Profile struct {
+	ID         int         `json:"id"`
+	Username   string      `json:"username"`
+	Reputation *Reputation `json:"reputation,omitempty"`
+}
+
+const reviewColumns = `r.id, r.event_id, r.user_id, u.name, r.rating, r.body, r.created_at, r.reply, r.replied_at`
+
+func scanReview(row rowScanner, review *Review) error {
+	return row.Scan(&review.ID, &review.EventID, &review.UserID, &review.Username, &review.Rating, &review.Body,
+		&review.CreatedAt, &review.Reply, &review.RepliedAt)
+}
+
+// Insert stores review, dated now. Its user must have checked in to an
+// occurrence of the event that started before today, or Insert returns
+// ErrNotReviewable; its organizers get ErrOwnEvent and a second review of
+// the same event returns ErrDuplicateReview.
+func (s *ReviewModel) Insert(review *Review) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+	var organizer, attended, reviewed bool
+	query := `
+		SELECT
+			EXISTS (SELECT 1 FROM event_organizers WHERE event_id = $1 AND user_id = $2),
+			EXISTS (SELECT 1 FROM attendees a
+				JOIN events e ON e.id = a.event_id
+				LEFT JOIN event_occurrences oc ON oc.event_id = a.event_id AND oc.occurrence_date = a.occurrence_date
+				WHERE a.event_id = $1 AND a.user_id = $2 AND a.checked_in_at IS NOT NULL
+				AND ` + attendanceStartsOn + ` < $3),
+			EXISTS (SELECT 1 FROM reviews WHERE event_id = $1 AND user_id = $2)`
+	err = tx.QueryRowContext(ctx, query, review.EventID, review.UserID, now.Format(time.DateOnly)).Scan(&organizer, &attended, &reviewed)
+	if err != nil {
+		return err
+	}
+	if organizer {
+		return ErrOwnEvent
+	}
+	if !attended {
+		return ErrNotReviewable
+	}
+	if reviewed {
+		return ErrDuplicateReview
+	}
+
+	review.CreatedAt = now
+	query = `INSERT INTO reviews (event_id, user_id, rating, body, created_at) VALUES ($1, $2, $3, $4, $5) RETURNING id`
+	err = tx.QueryRowContext(ctx, query, review.EventID, review.UserID, review.Rating, review.Body, now).Scan(&review.ID)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Get returns a review of an event, or nil when there is none.
+func (s *ReviewModel) Get(eventID, id int) (*Review, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var review Review
+	query := `SELECT ` + reviewColumns + ` FROM reviews r JOIN users u ON u.id = r.user_id WHERE r.id = $1 AND r.event_id = $2`
+	err := scanReview(s.ReadDB.QueryRowContext(ctx, query, id, eventID), &review)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &review, nil
+}
+
+// GetByEvent lists the reviews of an event, newest first.
+func (s *ReviewModel) GetByEvent(eventID, limit, offset int) ([]*Review, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	query := `
+		SELECT ` + reviewColumns + ` FROM reviews r JOIN users u ON u.id = r.user_id
+		WHERE r.event_id = $1 ORDER BY r.id DESC LIMIT $2 OFFSET $3`
+	rows, err := s.ReadDB.QueryContext(ctx, query, eventID, limit, max(offset, 0))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reviews := []*Review{}
+	for rows.Next() {
+		var review Review
+		if err := scanReview(rows, &review); err != nil {
+			return nil, err
+		}
+		reviews = append(reviews, &review)
+	}
+	return reviews, rows.Err()
+}
+
+// SetReply records an organizer's reply to a review on behalf of actor,
+// replacing any earlier one.
+func (s *ReviewModel) SetReply(actor Actor, review *Review, reply string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+	query := `UPDATE reviews SET reply = $1, replied_at = $2, replied_by = $3 WHERE id = $4`
+	if err := expectOneRow(tx.ExecContext(ctx, query, reply, now, actor.UserID, review.ID)); err != nil {
+		return err
+	}
+	replied := *review
+	replied.Reply = &reply
+	replied.RepliedAt = &now
+	if err := insertAudit(ctx, tx, actor, AuditEntityReview, review.ID, review.EventID, AuditUpdate, review, &replied); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	*review = replied
+	return nil
+}
+
+// GetProfile returns the public profile of a user with their reputation
+// as an organizer, or nil when there is no such user. Reputation is nil
+// until an event they own is reviewed.
+func (s *ReviewModel) GetProfile(userID int) (*Profile, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var profile Profile
+	var sum sql.NullFloat64
+	var reviews, events int
+	query := `
+		SELECT u.id, u.name,
+			(SELECT SUM(r.rating) FROM reviews r JOIN events e ON e.id = r.event_id WHERE e.owner_id = u.id AND e.deleted_at IS NULL),
+			(SELECT COUNT(*) FROM reviews r JOIN events e ON e.id = r.event_id WHERE e.owner_id = u.id AND e.deleted_at IS NULL),
+			(SELECT COUNT(DISTINCT r.event_id) FROM reviews r JOIN events e ON e.id = r.event_id WHERE e.owner_id = u.id AND e.deleted_at IS NULL)
+		FROM users u WHERE u.id = $1 AND u.deleted_at IS NULL`
+	err := s.ReadDB.QueryRowContext(ctx, query, userID).Scan(&profile.ID, &profile.Username, &sum, &reviews, &events)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if reviews > 0 {
+		score := (reputationPrior*reputationPriorWeight + sum.Float64) / (reputationPriorWeight + float64(reviews))
+		profile.Reputation = &Reputation{
+			Score:   math.Round(score*100) / 100,
+			Average: math.Round(sum.Float64/float64(reviews)*100) / 100,
+			Reviews: reviews,
+			Events:  events,
+		}
+	}
+	return &profile, nil
+}
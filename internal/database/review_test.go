@@ -0,0 +1,93 @@
+package database
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestReviewEligibility(t *testing.T) {
+	models := newTestModels(t)
+	today := time.Now().UTC()
+
+	users := map[string]*User{}
+	for _, name := range []string{"brewer", "cohost", "taster", "latecomer", "absentee"} {
+		user := &User{Username: name, Email: name + "@example.com", Password: "x"}
+		if err := models.Users.Insert(Actor{}, user); err != nil {
+			t.Fatal(err)
+		}
+		users[name] = user
+	}
+	insertTasting := func(date time.Time) *Event {
+		t.Helper()
+		event := &Event{
+			OwnerId:     users["brewer"].ID,
+			Name:        "Honey wine tasting",
+			Description: "Five tej houses pour their best",
+			Date:        date.Format(time.DateOnly),
+			Location:    "Bishoftu",
+			Status:      EventStatusPublished,
+		}
+		if err := models.Events.Insert(Actor{}, event); err != nil {
+			t.Fatal(err)
+		}
+		return event
+	}
+	attend := func(event *Event, user *User, checkIn bool) {
+		t.Helper()
+		attendee := &Attendee{EventID: event.ID, UserID: user.ID}
+		if err := models.Attendees.Insert(Actor{}, attendee); err != nil {
+			t.Fatal(err)
+		}
+		if checkIn {
+			if err := models.Attendees.CheckIn(Actor{}, attendee); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	past := insertTasting(today.AddDate(0, 0, -2))
+	running := insertTasting(today)
+	if err := models.Organizers.Set(Actor{}, past.ID, users["cohost"].ID, OrganizerRoleCoHost); err != nil {
+		t.Fatal(err)
+	}
+	attend(past, users["taster"], true)
+	attend(past, users["cohost"], true)
+	attend(past, users["absentee"], false)
+	attend(running, users["latecomer"], true)
+
+	tests := []struct {
+		name  string
+		event *Event
+		user  string
+		want  error
+	}{
+		{"checked in to a past event", past, "taster", nil},
+		{"reviewing twice", past, "taster", ErrDuplicateReview},
+		{"owner", past, "brewer", ErrOwnEvent},
+		{"co-host who checked in", past, "cohost", ErrOwnEvent},
+		{"attendee who never checked in", past, "absentee", ErrNotReviewable},
+		{"not an attendee", past, "latecomer", ErrNotReviewable},
+		{"event still under way", running, "latecomer", ErrNotReviewable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			review := &Review{EventID: tt.event.ID, UserID: users[tt.user].ID, Rating: 4}
+			err := models.Reviews.Insert(review)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("Insert = %v, want %v", err, tt.want)
+			}
+			if tt.want == nil && review.ID == 0 {
+				t.Error("Insert did not set the review ID")
+			}
+		})
+	}
+
+	reviews, err := models.Reviews.GetByEvent(past.ID, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reviews) != 1 || reviews[0].UserID != users["taster"].ID {
+		t.Errorf("reviews of the past event = %+v, want only the taster's", reviews)
+	}
+}
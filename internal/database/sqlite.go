@@ -0,0 +1,131 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/Yiheyistm/go-restful-api/internal/env"
+)
+
+// Config holds the SQLite connection settings. The pragmas are passed
+// through the DSN so the driver applies them to every new connection,
+// not only to the first one handed out by the pool.
+type Config struct {
+	Path         string
+	JournalMode  string
+	BusyTimeout  time.Duration
+	Synchronous  string
+	ForeignKeys  bool
+	CacheSizeKiB int
+	MaxReadConns int
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Path:         "./data.db",
+		JournalMode:  "WAL",
+		BusyTimeout:  5 * time.Second,
+		Synchronous:  "NORMAL",
+		ForeignKeys:  true,
+		CacheSizeKiB: 20000,
+		MaxReadConns: 4,
+	}
+}
+
+// ConfigFromEnv starts from DefaultConfig and applies any DB_* overrides.
+func ConfigFromEnv() Config {
+	cfg := DefaultConfig()
+	cfg.Path = env.GetEnvString("DB_PATH", cfg.Path)
+	cfg.JournalMode = env.GetEnvString("DB_JOURNAL_MODE", cfg.JournalMode)
+	cfg.BusyTimeout = env.GetEnvDuration("DB_BUSY_TIMEOUT", cfg.BusyTimeout)
+	cfg.Synchronous = env.GetEnvString("DB_SYNCHRONOUS", cfg.Synchronous)
+	cfg.ForeignKeys = env.GetEnvBool("DB_FOREIGN_KEYS", cfg.ForeignKeys)
+	cfg.CacheSizeKiB = env.GetEnvInt("DB_CACHE_SIZE_KB", cfg.CacheSizeKiB)
+	cfg.MaxReadConns = env.GetEnvInt("DB_MAX_READ_CONNS", cfg.MaxReadConns)
+	return cfg
+}
+
+// DB bundles the two pools used by the models. SQLite only allows one
+// writer at a time, so Writer is capped at a single connection and
+// writes queue in Go instead of failing with "database is locked".
+// Reader is query-only and can be as wide as MaxReadConns.
+type DB struct {
+	Writer *sql.DB
+	Reader *sql.DB
+}
+
+func Open(cfg Config) (*DB, error) {
+	writer, err := sql.Open("sqlite3", cfg.dsn(false))
+	if err != nil {
+		return nil, err
+	}
+	writer.SetMaxOpenConns(1)
+	writer.SetMaxIdleConns(1)
+	writer.SetConnMaxIdleTime(0)
+
+	// Ping the writer first so journal_mode is switched to WAL before
+	// any reader connects.
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if err := writer.PingContext(ctx); err != nil {
+		writer.Close()
+		return nil, err
+	}
+
+	reader, err := sql.Open("sqlite3", cfg.dsn(true))
+	if err != nil {
+		writer.Close()
+		return nil, err
+	}
+	maxReadConns := cfg.MaxReadConns
+	if maxReadConns < 1 {
+		maxReadConns = 1
+	}
+	reader.SetMaxOpenConns(maxReadConns)
+	reader.SetMaxIdleConns(maxReadConns)
+	if err := reader.PingContext(ctx); err != nil {
+		writer.Close()
+		reader.Close()
+		return nil, err
+	}
+
+	return &DB{Writer: writer, Reader: reader}, nil
+}
+
+func (db *DB) Close() error {
+	rerr := db.Reader.Close()
+	if err := db.Writer.Close(); err != nil {
+		return err
+	}
+	return rerr
+}
+
+func (cfg Config) dsn(readOnly bool) string {
+	params := url.Values{}
+	if cfg.JournalMode != "" {
+		params.Set("_journal_mode", cfg.JournalMode)
+	}
+	if cfg.BusyTimeout > 0 {
+		params.Set("_busy_timeout", strconv.FormatInt(cfg.BusyTimeout.Milliseconds(), 10))
+	}
+	if cfg.Synchronous != "" {
+		params.Set("_synchronous", cfg.Synchronous)
+	}
+	params.Set("_foreign_keys", strconv.FormatBool(cfg.ForeignKeys))
+	if cfg.CacheSizeKiB > 0 {
+		// A negative cache_size is interpreted by SQLite as KiB rather than pages.
+		params.Set("_cache_size", strconv.Itoa(-cfg.CacheSizeKiB))
+	}
+	if readOnly {
+		params.Set("_query_only", "true")
+	} else {
+		// Take the write lock at BEGIN so a transaction never has to upgrade
+		// from a read lock, which is where SQLITE_BUSY usually comes from.
+		params.Set("_txlock", "immediate")
+	}
+	return fmt.Sprintf("file:%s?%s", cfg.Path, params.Encode())
+}
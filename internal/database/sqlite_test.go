@@ -0,0 +1,138 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+const testMigrations = "../../cmd/migrate/migrations"
+
+// newTestModels opens a fresh database in a temporary directory with every
+// migration applied.
+func newTestModels(t testing.TB) Models {
+	t.Helper()
+	cfg := DefaultConfig()
+	cfg.Path = filepath.Join(t.TempDir(), "test.db")
+	db, err := Open(cfg)
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := db.MigrateUp(testMigrations); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return NewModels(db)
+}
+
+func TestOpenAppliesPragmas(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Path = filepath.Join(t.TempDir(), "test.db")
+	db, err := Open(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	for _, pool := range []struct {
+		name string
+		db   *sql.DB
+	}{{"writer", db.Writer}, {"reader", db.Reader}} {
+		var journal string
+		var foreignKeys, busyTimeout int
+		if err := pool.db.QueryRow(`PRAGMA journal_mode`).Scan(&journal); err != nil {
+			t.Fatal(err)
+		}
+		if err := pool.db.QueryRow(`PRAGMA foreign_keys`).Scan(&foreignKeys); err != nil {
+			t.Fatal(err)
+		}
+		if err := pool.db.QueryRow(`PRAGMA busy_timeout`).Scan(&busyTimeout); err != nil {
+			t.Fatal(err)
+		}
+		if journal != "wal" || foreignKeys != 1 || busyTimeout != int(cfg.BusyTimeout.Milliseconds()) {
+			t.Errorf("%s: journal_mode=%s foreign_keys=%d busy_timeout=%d", pool.name, journal, foreignKeys, busyTimeout)
+		}
+	}
+	if n := db.Writer.Stats().MaxOpenConnections; n != 1 {
+		t.Errorf("writer allows %d connections, want 1", n)
+	}
+	if _, err := db.Reader.Exec(`CREATE TABLE t (id INTEGER)`); err == nil {
+		t.Error("the reader pool accepted a write")
+	}
+}
+
+// BenchmarkEventReadWrite runs concurrent EventModel inserts, each followed
+// by reads of the new event, against the driver defaults (one shared pool,
+// rollback journal, no busy timeout) and against the pools from Open. The
+// locked/op metric counts "database is locked" failures.
+func BenchmarkEventReadWrite(b *testing.B) {
+	const readsPerWrite = 4
+
+	open := map[string]func(path string) (*DB, error){
+		"defaults": func(path string) (*DB, error) {
+			db, err := sql.Open("sqlite3", path)
+			if err != nil {
+				return nil, err
+			}
+			return &DB{Writer: db, Reader: db}, nil
+		},
+		"tuned": func(path string) (*DB, error) {
+			cfg := DefaultConfig()
+			cfg.Path = path
+			return Open(cfg)
+		},
+	}
+	for _, name := range []string{"defaults", "tuned"} {
+		b.Run(name, func(b *testing.B) {
+			db, err := open[name](filepath.Join(b.TempDir(), "bench.db"))
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer db.Close()
+			if err := db.MigrateUp(testMigrations); err != nil {
+				b.Fatal(err)
+			}
+			models := NewModels(db)
+			owner := &User{Username: "bench", Email: "bench@example.com", Password: "x"}
+			if err := models.Users.Insert(Actor{}, owner); err != nil {
+				b.Fatal(err)
+			}
+
+			var worker, failed, locked atomic.Int64
+			b.SetParallelism(4)
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				id := worker.Add(1)
+				countError := func(err error) {
+					failed.Add(1)
+					if strings.Contains(err.Error(), "database is locked") {
+						locked.Add(1)
+					}
+				}
+				for i := 0; pb.Next(); i++ {
+					event := &Event{
+						OwnerId:     owner.ID,
+						Name:        fmt.Sprintf("bench %d-%d", id, i),
+						Description: "benchmark event description",
+						Date:        "2030-01-01",
+						Location:    "somewhere",
+					}
+					if err := models.Events.Insert(Actor{}, event); err != nil {
+						countError(err)
+						continue
+					}
+					for range readsPerWrite {
+						if _, err := models.Events.GetByID(event.ID); err != nil {
+							countError(err)
+						}
+					}
+				}
+			})
+			b.ReportMetric(float64(failed.Load())/float64(b.N), "errors/op")
+			b.ReportMetric(float64(locked.Load())/float64(b.N), "locked/op")
+		})
+	}
+}
@@ -0,0 +1,166 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const (
+	MaxTagsPerEvent = 10
+	MaxTagLength    = 32
+)
+
+type TagModel struct {
+	DB     *sql.DB
+	ReadDB *sql.DB
+}
+
+// TagCount is a tag together with the number of live events using it.
+type TagCount struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// NormalizeTag lowercases name and turns it into a slug: runs of anything
+// other than letters and digits become a single dash.
+func NormalizeTag(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(strings.TrimSpace(name)) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+			dash = false
+		} else if !dash && b.Len() > 0 {
+			b.WriteRune('-')
+			dash = true
+		}
+	}
+	return strings.TrimSuffix(b.String(), "-")
+}
+
+// NormalizeTags normalizes and de-duplicates names and enforces the
+// per-event limits.
+func NormalizeTags(names []string) ([]string, error) {
+	seen := map[string]bool{}
+	tags := []string{}
+	for _, name := range names {
+		tag := NormalizeTag(name)
+		if tag == "" {
+			return nil, fmt.Errorf("tag %q is empty after normalization", name)
+		}
+		if len(tag) > MaxTagLength {
+			return nil, fmt.Errorf("tag %q is longer than %d characters", tag, MaxTagLength)
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+	if len(tags) > MaxTagsPerEvent {
+		return nil, fmt.Errorf("an event can have at most %d tags", MaxTagsPerEvent)
+	}
+	return tags, nil
+}
+
+// SetForEvent replaces the tags of event with tags, which must already be
+// normalized. Tags are part of the event, so it moves to a new version
+// with the same check as EventModel.Update.
+func (s *TagModel) SetForEvent(actor Actor, event *Event, tags []string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := eventBeforeChange(ctx, tx, event.ID)
+	if err != nil {
+		return err
+	}
+
+	eventID := event.ID
+	err = tx.QueryRowContext(ctx,
+		`UPDATE events SET version = version + 1 WHERE id = $1 AND version = $2 AND deleted_at IS NULL RETURNING version`,
+		eventID, event.Version,
+	).Scan(&event.Version)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrEditConflict
+		}
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM event_tags WHERE event_id = $1`, eventID); err != nil {
+		return err
+	}
+	for _, tag := range tags {
+		var tagID int
+		err := tx.QueryRowContext(ctx, `
+			INSERT INTO tags (name) VALUES ($1)
+			ON CONFLICT (name) DO UPDATE SET name = excluded.name
+			RETURNING id`, tag).Scan(&tagID)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `INSERT INTO event_tags (event_id, tag_id) VALUES ($1, $2)`, eventID, tagID); err != nil {
+			return err
+		}
+	}
+	if err := recordEventChange(ctx, tx, DomainEventUpdated, eventID); err != nil {
+		return err
+	}
+	if err := auditEventChange(ctx, tx, actor, AuditUpdate, before, eventID); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	event.Tags = tags
+	return nil
+}
+
+// GetAllWithCounts lists every tag used by at least one live, published
+// or finished public event, most used first.
+func (s *TagModel) GetAllWithCounts() ([]*TagCount, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `
+		SELECT t.name, COUNT(e.id) FROM tags t
+		JOIN event_tags et ON et.tag_id = t.id
+		JOIN events e ON e.id = et.event_id
+		WHERE e.deleted_at IS NULL AND e.status <> 'draft' AND e.visibility = 'public'
+		GROUP BY t.id
+		ORDER BY COUNT(e.id) DESC, t.name`
+	rows, err := s.ReadDB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []*TagCount{}
+	for rows.Next() {
+		var tag TagCount
+		if err := rows.Scan(&tag.Name, &tag.Count); err != nil {
+			return nil, err
+		}
+		tags = append(tags, &tag)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+func splitTags(tags string) []string {
+	if tags == "" {
+		return nil
+	}
+	return strings.Split(tags, ",")
+}
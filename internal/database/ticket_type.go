@@ -0,0 +1,147 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+type TicketTypeModel struct {
+	DB     *sql.DB
+	ReadDB *sql.DB
+}
+
+// TicketType is one tier of tickets sold for an event. Prices are in the
+// currency's minor unit (cents for USD), and a free tier has price 0.
+type TicketType struct {
+	ID         int        `json:"id"`
+	EventID    int        `json:"event_id"`
+	Name       string     `json:"name" binding:"required,min=1,max=100"`
+	PriceMinor int64      `json:"price_minor" binding:"min=0"`
+	Currency   string     `json:"currency" binding:"required,iso4217"`
+	Quantity   int        `json:"quantity" binding:"required,min=1"`
+	SalesStart *time.Time `json:"sales_start,omitempty"`
+	SalesEnd   *time.Time `json:"sales_end,omitempty"`
+	// Available is Quantity less the paid orders and the unexpired
+	// reservations. It is computed on read.
+	Available int `json:"available" binding:"-"`
+}
+
+// OnSale reports whether the tier's sales window includes now.
+func (t *TicketType) OnSale(now time.Time) bool {
+	if t.SalesStart != nil && now.Before(*t.SalesStart) {
+		return false
+	}
+	if t.SalesEnd != nil && !now.Before(*t.SalesEnd) {
+		return false
+	}
+	return true
+}
+
+// reservedOrders counts the orders holding a ticket of tier tt at $now.
+const reservedOrders = `(SELECT COUNT(*) FROM orders o WHERE o.ticket_type_id = tt.id
+	AND (o.status = 'paid' OR (o.status = 'pending' AND o.expires_at > $now)))`
+
+func (s *TicketTypeModel) Insert(ticketType *TicketType) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `
+		INSERT INTO ticket_types (event_id, name, price_minor, currency, quantity, sales_start, sales_end, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id`
+	err := s.DB.QueryRowContext(ctx, query,
+		ticketType.EventID,
+		ticketType.Name,
+		ticketType.PriceMinor,
+		ticketType.Currency,
+		ticketType.Quantity,
+		ticketType.SalesStart,
+		ticketType.SalesEnd,
+		time.Now().UTC(),
+	).Scan(&ticketType.ID)
+	if err != nil {
+		return err
+	}
+	ticketType.Available = ticketType.Quantity
+	return nil
+}
+
+// GetByEvent lists the ticket tiers of an event, cheapest first.
+func (s *TicketTypeModel) GetByEvent(eventID int) ([]*TicketType, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `
+		SELECT tt.id, tt.event_id, tt.name, tt.price_minor, tt.currency, tt.quantity, tt.sales_start, tt.sales_end,
+		tt.quantity - ` + reservedOrders + `
+		FROM ticket_types tt WHERE tt.event_id = $event ORDER BY tt.price_minor, tt.id`
+	rows, err := s.ReadDB.QueryContext(ctx, query, sql.Named("event", eventID), sql.Named("now", time.Now().UTC()))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ticketTypes := []*TicketType{}
+	for rows.Next() {
+		var ticketType TicketType
+		if err := scanTicketType(rows, &ticketType); err != nil {
+			return nil, err
+		}
+		ticketTypes = append(ticketTypes, &ticketType)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return ticketTypes, nil
+}
+
+// Get returns a ticket tier of the event, or nil when there is none.
+func (s *TicketTypeModel) Get(eventID, id int) (*TicketType, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `
+		SELECT tt.id, tt.event_id, tt.name, tt.price_minor, tt.currency, tt.quantity, tt.sales_start, tt.sales_end,
+		tt.quantity - ` + reservedOrders + `
+		FROM ticket_types tt WHERE tt.id = $id AND tt.event_id = $event`
+	var ticketType TicketType
+	err := scanTicketType(s.ReadDB.QueryRowContext(ctx, query, sql.Named("id", id), sql.Named("event", eventID), sql.Named("now", time.Now().UTC())), &ticketType)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &ticketType, nil
+}
+
+// HasTicketTypes reports whether the event sells tickets. Such events
+// take attendees through checkout rather than plain RSVPs.
+func (s *TicketTypeModel) HasTicketTypes(eventID int) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var exists bool
+	query := `SELECT EXISTS (SELECT 1 FROM ticket_types WHERE event_id = $1)`
+	err := s.ReadDB.QueryRowContext(ctx, query, eventID).Scan(&exists)
+	return exists, err
+}
+
+// Delete removes a ticket tier that has never been ordered. It returns
+// sql.ErrNoRows when there is no such tier or it has orders.
+func (s *TicketTypeModel) Delete(eventID, id int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `
+		DELETE FROM ticket_types WHERE id = $1 AND event_id = $2
+		AND NOT EXISTS (SELECT 1 FROM orders WHERE ticket_type_id = $1)`
+	return expectOneRow(s.DB.ExecContext(ctx, query, id, eventID))
+}
+
+func scanTicketType(row rowScanner, ticketType *TicketType) error {
+	return row.Scan(&ticketType.ID, &ticketType.EventID, &ticketType.Name, &ticketType.PriceMinor, &ticketType.Currency,
+		&ticketType.Quantity, &ticketType.SalesStart, &ticketType.SalesEnd, &ticketType.Available)
+}
@@ -9,7 +9,8 @@ import (
 )
 
 type UserModel struct {
-	DB *sql.DB
+	DB     *sql.DB
+	ReadDB *sql.DB
 }
 
 type User struct {
@@ -17,6 +18,7 @@ type User struct {
 	Username string `json:"username"`
 	Email    string `json:"email"`
 	Password string `json:"-"`
+	IsAdmin  bool   `json:"is_admin"`
 }
 
 func (s *UserModel) GenerateToken(id int, appJwtSecret string) (string, error) {
@@ -31,31 +33,172 @@ func (s *UserModel) GenerateToken(id int, appJwtSecret string) (string, error) {
 	return tokenString, nil
 }
 
-func (s *UserModel) Insert(user *User) error {
+func (s *UserModel) Insert(actor Actor, user *User) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
 	query := `INSERT INTO users(email, password, name) VALUES ($1, $2, $3) RETURNING id`
-	return s.DB.QueryRowContext(ctx, query, user.Email, user.Password, user.Username).Scan(&user.ID)
+	if err := tx.QueryRowContext(ctx, query, user.Email, user.Password, user.Username).Scan(&user.ID); err != nil {
+		return err
+	}
+	if err := insertAudit(ctx, tx, actor, AuditEntityUser, user.ID, 0, AuditCreate, nil, user); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
 func (s *UserModel) Get(id int) (*User, error) {
-	query := `SELECT id, name, email, password FROM users WHERE id = $1`
+	query := `SELECT id, name, email, password, is_admin FROM users WHERE id = $1 AND deleted_at IS NULL`
 	return s.getUser(query, id)
 }
 
 func (s *UserModel) GetByEmail(email string) (*User, error) {
-	query := `SELECT id, name, email, password FROM users WHERE email = $1`
+	query := `SELECT id, name, email, password, is_admin FROM users WHERE email = $1 AND deleted_at IS NULL`
 	return s.getUser(query, email)
 }
 
+func (s *UserModel) SetAdmin(actor Actor, email string, isAdmin bool) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := userInTx(ctx, tx, `email = $1`, email)
+	if err != nil {
+		return err
+	}
+	query := `UPDATE users SET is_admin = $1 WHERE id = $2`
+	if err := expectOneRow(tx.ExecContext(ctx, query, isAdmin, before.ID)); err != nil {
+		return err
+	}
+	after := *before
+	after.IsAdmin = isAdmin
+	if err := insertAudit(ctx, tx, actor, AuditEntityUser, before.ID, 0, AuditUpdate, before, &after); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *UserModel) Delete(actor Actor, id int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := userInTx(ctx, tx, `id = $1 AND deleted_at IS NULL`, id)
+	if err != nil {
+		return err
+	}
+	query := `UPDATE users SET deleted_at = $1 WHERE id = $2 AND deleted_at IS NULL`
+	if err := expectOneRow(tx.ExecContext(ctx, query, time.Now().UTC(), id)); err != nil {
+		return err
+	}
+	if err := insertAudit(ctx, tx, actor, AuditEntityUser, id, 0, AuditDelete, before, nil); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Restore brings back a user that was soft-deleted at or after since.
+func (s *UserModel) Restore(actor Actor, id int, since time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE users SET deleted_at = NULL WHERE id = $1 AND deleted_at >= $2`
+	if err := expectOneRow(tx.ExecContext(ctx, query, id, since.UTC())); err != nil {
+		return err
+	}
+	after, err := userInTx(ctx, tx, `id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if err := insertAudit(ctx, tx, actor, AuditEntityUser, id, 0, AuditRestore, nil, after); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Purge permanently removes users soft-deleted before cutoff, together
+// with their events and attendances, and audits all three as deleted on
+// behalf of actor. Their comments on other events are deleted rather than
+// removed, so the replies to them keep their place.
+func (s *UserModel) Purge(actor Actor, cutoff time.Time) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	purged := `SELECT id FROM users WHERE deleted_at IS NOT NULL AND deleted_at < $1`
+	attendees := `user_id IN (` + purged + `) OR event_id IN (SELECT id FROM events WHERE owner_id IN (` + purged + `))`
+	if err := auditRemovedAttendees(ctx, tx, actor, attendees, cutoff.UTC()); err != nil {
+		return 0, err
+	}
+	if err := auditRemovedEvents(ctx, tx, actor, `e.owner_id IN (`+purged+`)`, cutoff.UTC()); err != nil {
+		return 0, err
+	}
+	if err := auditRemovedUsers(ctx, tx, actor, `id IN (`+purged+`)`, cutoff.UTC()); err != nil {
+		return 0, err
+	}
+
+	query := `UPDATE comments SET body = '', deleted_at = COALESCE(deleted_at, $2) WHERE user_id IN (` + purged + `)`
+	if _, err := tx.ExecContext(ctx, query, cutoff.UTC(), time.Now().UTC()); err != nil {
+		return 0, err
+	}
+	res, err := tx.ExecContext(ctx, `DELETE FROM users WHERE id IN (`+purged+`)`, cutoff.UTC())
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return n, tx.Commit()
+}
+
+// userInTx returns the first user matching where as it stands in tx,
+// soft-deleted or not.
+func userInTx(ctx context.Context, tx *sql.Tx, where string, args ...any) (*User, error) {
+	var user User
+	query := `SELECT id, name, email, password, is_admin FROM users WHERE ` + where
+	err := tx.QueryRowContext(ctx, query, args...).Scan(&user.ID, &user.Username, &user.Email, &user.Password, &user.IsAdmin)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
 func (s *UserModel) getUser(query string, args ...any) (*User, error) {
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	row := s.DB.QueryRowContext(ctx, query, args...)
+	row := s.ReadDB.QueryRowContext(ctx, query, args...)
 	var user User
-	if err := row.Scan(&user.ID, &user.Username, &user.Email, &user.Password); err != nil {
+	if err := row.Scan(&user.ID, &user.Username, &user.Email, &user.Password, &user.IsAdmin); err != nil {
 		if err == sql.ErrNoRows {
 			return nil, err
 		}
@@ -0,0 +1,254 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// JobWaitlistPromote is the type of the recurring job that hands freed
+// tickets to the users waiting for them.
+const JobWaitlistPromote = "waitlist.promote"
+
+var (
+	// ErrTicketsAvailable is returned when joining the waitlist of a tier
+	// that still has tickets to sell.
+	ErrTicketsAvailable = errors.New("tickets are still available")
+	// ErrAlreadyWaiting is returned when the user is already on the
+	// waitlist of the tier, or holds a ticket it promoted them to.
+	ErrAlreadyWaiting = errors.New("already on the waitlist")
+)
+
+type WaitlistModel struct {
+	DB     *sql.DB
+	ReadDB *sql.DB
+}
+
+// WaitlistEntry is a user waiting for a ticket of a sold-out tier. When a
+// ticket frees up, the user who has waited longest is promoted: OrderID
+// is a pending order holding the ticket for them until it expires, which
+// checkout takes over.
+type WaitlistEntry struct {
+	ID             int        `json:"id"`
+	EventID        int        `json:"event_id"`
+	TicketTypeID   int        `json:"ticket_type_id"`
+	UserID         int        `json:"user_id"`
+	OccurrenceDate string     `json:"occurrence_date,omitempty"`
+	OrderID        *int       `json:"order_id,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	PromotedAt     *time.Time `json:"promoted_at,omitempty"`
+	// Position counts the entries waiting ahead of this one, plus one. It
+	// is computed on join.
+	Position int `json:"position,omitempty"`
+}
+
+// Join puts entry at the end of its tier's waitlist. It returns
+// ErrTicketsAvailable while the tier has tickets left and
+// ErrAlreadyWaiting when the user is already on it. An earlier promotion
+// the user let lapse does not count.
+func (s *WaitlistModel) Join(entry *WaitlistEntry) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	now := time.Now().UTC()
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var available int
+	err = tx.QueryRowContext(ctx, `SELECT tt.quantity - `+reservedOrders+` FROM ticket_types tt WHERE tt.id = $id`,
+		sql.Named("id", entry.TicketTypeID), sql.Named("now", now)).Scan(&available)
+	if err != nil {
+		return err
+	}
+	if available > 0 {
+		return ErrTicketsAvailable
+	}
+
+	query := `
+		DELETE FROM waitlist_entries
+		WHERE ticket_type_id = $1 AND user_id = $2 AND occurrence_date = $3 AND promoted_at IS NOT NULL
+		AND NOT EXISTS (SELECT 1 FROM orders o WHERE o.id = waitlist_entries.order_id
+			AND (o.status = 'paid' OR (o.status = 'pending' AND o.expires_at > $4)))`
+	if _, err := tx.ExecContext(ctx, query, entry.TicketTypeID, entry.UserID, entry.OccurrenceDate, now); err != nil {
+		return err
+	}
+	var waiting bool
+	query = `SELECT EXISTS (SELECT 1 FROM waitlist_entries WHERE ticket_type_id = $1 AND user_id = $2 AND occurrence_date = $3)`
+	if err := tx.QueryRowContext(ctx, query, entry.TicketTypeID, entry.UserID, entry.OccurrenceDate).Scan(&waiting); err != nil {
+		return err
+	}
+	if waiting {
+		return ErrAlreadyWaiting
+	}
+
+	entry.CreatedAt = now
+	query = `
+		INSERT INTO waitlist_entries (event_id, ticket_type_id, user_id, occurrence_date, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id`
+	err = tx.QueryRowContext(ctx, query, entry.EventID, entry.TicketTypeID, entry.UserID, entry.OccurrenceDate, now).Scan(&entry.ID)
+	if err != nil {
+		return err
+	}
+	query = `SELECT COUNT(*) FROM waitlist_entries WHERE ticket_type_id = $1 AND promoted_at IS NULL AND id <= $2`
+	if err := tx.QueryRowContext(ctx, query, entry.TicketTypeID, entry.ID).Scan(&entry.Position); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Leave takes a user off a waitlist they are still waiting on. It returns
+// sql.ErrNoRows when there is no such entry of theirs or it was already
+// promoted.
+func (s *WaitlistModel) Leave(eventID, userID, id int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `DELETE FROM waitlist_entries WHERE id = $1 AND event_id = $2 AND user_id = $3 AND promoted_at IS NULL`
+	return expectOneRow(s.DB.ExecContext(ctx, query, id, eventID, userID))
+}
+
+// waitlistTier is a tier of a published event with tickets to hand to its
+// waitlist.
+type waitlistTier struct {
+	id         int
+	available  int
+	priceMinor int64
+	currency   string
+	name       string
+	eventName  string
+}
+
+// Promote hands every ticket that freed up to the users waiting longest
+// for it: each gets a pending order holding the ticket for ttl and a
+// waitlist.promoted notification, and the promotion is written to the
+// outbox for the organizers' live dashboards. Users who got in some other way
+// meanwhile are taken off the waitlist first. It returns the entries
+// promoted.
+func (s *WaitlistModel) Promote(ttl time.Duration) ([]*WaitlistEntry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	now := time.Now().UTC()
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `UPDATE orders SET status = 'expired' WHERE status = 'pending' AND expires_at <= $1`, now)
+	if err != nil {
+		return nil, err
+	}
+	query := `
+		DELETE FROM waitlist_entries WHERE promoted_at IS NULL AND EXISTS (
+			SELECT 1 FROM attendees a WHERE a.event_id = waitlist_entries.event_id
+			AND a.user_id = waitlist_entries.user_id AND a.occurrence_date = waitlist_entries.occurrence_date)`
+	if _, err := tx.ExecContext(ctx, query); err != nil {
+		return nil, err
+	}
+
+	query = `
+		SELECT tt.id, tt.quantity - ` + reservedOrders + `, tt.price_minor, tt.currency, tt.name, e.name
+		FROM ticket_types tt
+		JOIN events e ON e.id = tt.event_id AND e.deleted_at IS NULL AND e.status = 'published'
+		WHERE (tt.sales_end IS NULL OR tt.sales_end > $now)
+		AND EXISTS (SELECT 1 FROM waitlist_entries w WHERE w.ticket_type_id = tt.id AND w.promoted_at IS NULL)`
+	rows, err := tx.QueryContext(ctx, query, sql.Named("now", now))
+	if err != nil {
+		return nil, err
+	}
+	var tiers []waitlistTier
+	for rows.Next() {
+		var tier waitlistTier
+		if err := rows.Scan(&tier.id, &tier.available, &tier.priceMinor, &tier.currency, &tier.name, &tier.eventName); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if tier.available > 0 {
+			tiers = append(tiers, tier)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	promoted := []*WaitlistEntry{}
+	for _, tier := range tiers {
+		entries, err := waitingEntries(ctx, tx, tier.id, tier.available)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if err := promoteEntry(ctx, tx, entry, tier, now, ttl); err != nil {
+				return nil, err
+			}
+			promoted = append(promoted, entry)
+		}
+	}
+	return promoted, tx.Commit()
+}
+
+// waitingEntries returns the first limit entries still waiting on a tier.
+func waitingEntries(ctx context.Context, tx *sql.Tx, ticketTypeID, limit int) ([]*WaitlistEntry, error) {
+	query := `
+		SELECT id, event_id, ticket_type_id, user_id, occurrence_date, created_at FROM waitlist_entries
+		WHERE ticket_type_id = $1 AND promoted_at IS NULL ORDER BY id LIMIT $2`
+	rows, err := tx.QueryContext(ctx, query, ticketTypeID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []*WaitlistEntry
+	for rows.Next() {
+		var entry WaitlistEntry
+		err := rows.Scan(&entry.ID, &entry.EventID, &entry.TicketTypeID, &entry.UserID, &entry.OccurrenceDate, &entry.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, &entry)
+	}
+	return entries, rows.Err()
+}
+
+// promoteEntry holds a ticket of tier for entry's user until ttl from now,
+// tells them in their inbox and writes the promotion to the outbox.
+func promoteEntry(ctx context.Context, tx *sql.Tx, entry *WaitlistEntry, tier waitlistTier, now time.Time, ttl time.Duration) error {
+	expiresAt := now.Add(ttl)
+	var orderID int
+	query := `
+		INSERT INTO orders (event_id, user_id, ticket_type_id, occurrence_date, amount_minor, currency, status, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, 'pending', $7, $8)
+		RETURNING id`
+	err := tx.QueryRowContext(ctx, query, entry.EventID, entry.UserID, entry.TicketTypeID, entry.OccurrenceDate,
+		tier.priceMinor, tier.currency, expiresAt, now).Scan(&orderID)
+	if err != nil {
+		return err
+	}
+	query = `UPDATE waitlist_entries SET order_id = $1, promoted_at = $2 WHERE id = $3`
+	if _, err := tx.ExecContext(ctx, query, orderID, now, entry.ID); err != nil {
+		return err
+	}
+	entry.OrderID = &orderID
+	entry.PromotedAt = &now
+	if err := insertDomainEvent(ctx, tx, AggregateEvent, entry.EventID, DomainWaitlistPromoted, entry); err != nil {
+		return err
+	}
+
+	_, err = insertNotifications(ctx, tx, &Notification{
+		Type:    NotificationWaitlistPromoted,
+		EventID: &entry.EventID,
+		Title:   fmt.Sprintf("A ticket for %s is yours", tier.eventName),
+		Body: fmt.Sprintf("A %s ticket is held for you until %s UTC. Check out before then to keep it.",
+			tier.name, expiresAt.Format(time.DateTime)),
+		Key: fmt.Sprintf("%s:%d", NotificationWaitlistPromoted, entry.ID),
+	}, `SELECT $user AS user_id`, sql.Named("user", entry.UserID))
+	return err
+}
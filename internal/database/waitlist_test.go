@@ -0,0 +1,169 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+)
+
+// insertSoldOutShow inserts a published show with a single ticket and a
+// fan who has reserved it until an hour from now.
+func insertSoldOutShow(t *testing.T, models Models) (*TicketType, *Order) {
+	t.Helper()
+	comedian := &User{Username: "comedian", Email: "comedian@example.com", Password: "x"}
+	fan := &User{Username: "first_fan", Email: "first_fan@example.com", Password: "x"}
+	for _, user := range []*User{comedian, fan} {
+		if err := models.Users.Insert(Actor{}, user); err != nil {
+			t.Fatal(err)
+		}
+	}
+	event := &Event{
+		OwnerId:     comedian.ID,
+		Name:        "Stand-up night",
+		Description: "One comedian, one chair, one seat left",
+		Date:        "2026-12-18",
+		Location:    "Lalibela",
+		Status:      EventStatusPublished,
+	}
+	if err := models.Events.Insert(Actor{}, event); err != nil {
+		t.Fatal(err)
+	}
+	tier := &TicketType{EventID: event.ID, Name: "Front row", PriceMinor: 1500, Currency: "ETB", Quantity: 1}
+	if err := models.TicketTypes.Insert(tier); err != nil {
+		t.Fatal(err)
+	}
+	order := &Order{EventID: event.ID, UserID: fan.ID, TicketTypeID: tier.ID, AmountMinor: 1500, Currency: "ETB", ExpiresAt: time.Now().Add(time.Hour)}
+	if err := models.Orders.Reserve(order); err != nil {
+		t.Fatal(err)
+	}
+	return tier, order
+}
+
+// expireOrder makes an order's reservation lapse.
+func expireOrder(t *testing.T, models Models, order *Order) {
+	t.Helper()
+	_, err := models.Orders.DB.Exec(`UPDATE orders SET expires_at = ? WHERE id = ?`, time.Now().UTC().Add(-time.Minute), order.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWaitlistPromotesInOrder(t *testing.T) {
+	models := newTestModels(t)
+	tier, held := insertSoldOutShow(t, models)
+	alice := insertBuyer(t, models, "alice")
+	bob := insertBuyer(t, models, "bob")
+
+	join := func(user *User) (*WaitlistEntry, error) {
+		entry := &WaitlistEntry{EventID: tier.EventID, TicketTypeID: tier.ID, UserID: user.ID}
+		return entry, models.Waitlist.Join(entry)
+	}
+	first, err := join(alice)
+	if err != nil || first.Position != 1 {
+		t.Fatalf("Join = %+v, %v; want position 1", first, err)
+	}
+	second, err := join(bob)
+	if err != nil || second.Position != 2 {
+		t.Fatalf("Join = %+v, %v; want position 2", second, err)
+	}
+	if _, err := join(alice); !errors.Is(err, ErrAlreadyWaiting) {
+		t.Errorf("Join twice = %v, want ErrAlreadyWaiting", err)
+	}
+
+	if promoted, err := models.Waitlist.Promote(15 * time.Minute); err != nil || len(promoted) != 0 {
+		t.Fatalf("Promote while sold out = %+v, %v; want nobody", promoted, err)
+	}
+	expireOrder(t, models, held)
+	promoted, err := models.Waitlist.Promote(15 * time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(promoted) != 1 || promoted[0].ID != first.ID || promoted[0].OrderID == nil {
+		t.Fatalf("Promote = %+v, want alice's entry with an order", promoted)
+	}
+	page, err := models.Notifications.List(alice.ID, 0, 10, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Notifications) != 1 || page.Notifications[0].Type != NotificationWaitlistPromoted {
+		t.Errorf("alice's inbox = %+v, want a waitlist.promoted notification", page.Notifications)
+	}
+
+	checkout := func(user *User) (*Order, error) {
+		order := &Order{EventID: tier.EventID, UserID: user.ID, TicketTypeID: tier.ID, AmountMinor: 1500, Currency: "ETB", ExpiresAt: time.Now().Add(time.Hour)}
+		return order, models.Orders.Reserve(order)
+	}
+	if _, err := checkout(bob); !errors.Is(err, ErrSoldOut) {
+		t.Errorf("Reserve by bob = %v, want ErrSoldOut while alice's ticket is held", err)
+	}
+	order, err := checkout(alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.ID != *promoted[0].OrderID || order.ExpiresAt.After(time.Now().Add(15*time.Minute)) {
+		t.Errorf("Reserve by alice = order %d until %s, want the held order %d", order.ID, order.ExpiresAt, *promoted[0].OrderID)
+	}
+	if err := models.Orders.MarkPaid(Actor{}, order); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := models.Waitlist.Leave(tier.EventID, alice.ID, second.ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Leave of someone else's entry = %v, want sql.ErrNoRows", err)
+	}
+	if err := models.Waitlist.Leave(tier.EventID, bob.ID, second.ID); err != nil {
+		t.Errorf("Leave = %v", err)
+	}
+	if err := models.Waitlist.Leave(tier.EventID, bob.ID, second.ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Leave twice = %v, want sql.ErrNoRows", err)
+	}
+}
+
+func TestWaitlistLapsedPromotionMovesOn(t *testing.T) {
+	models := newTestModels(t)
+	tier, held := insertSoldOutShow(t, models)
+	carol := insertBuyer(t, models, "carol")
+	dave := insertBuyer(t, models, "dave")
+
+	entries := map[*User]*WaitlistEntry{}
+	for _, user := range []*User{carol, dave} {
+		entry := &WaitlistEntry{EventID: tier.EventID, TicketTypeID: tier.ID, UserID: user.ID}
+		if err := models.Waitlist.Join(entry); err != nil {
+			t.Fatal(err)
+		}
+		entries[user] = entry
+	}
+
+	expireOrder(t, models, held)
+	promoted, err := models.Waitlist.Promote(15 * time.Minute)
+	if err != nil || len(promoted) != 1 || promoted[0].UserID != carol.ID {
+		t.Fatalf("Promote = %+v, %v; want carol", promoted, err)
+	}
+	if err := models.Waitlist.Leave(tier.EventID, carol.ID, entries[carol].ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Leave of a promoted entry = %v, want sql.ErrNoRows", err)
+	}
+
+	// Carol lets her hold lapse, so the ticket goes to dave and she may
+	// queue again behind him.
+	expireOrder(t, models, &Order{ID: *promoted[0].OrderID})
+	promoted, err = models.Waitlist.Promote(15 * time.Minute)
+	if err != nil || len(promoted) != 1 || promoted[0].UserID != dave.ID {
+		t.Fatalf("Promote after carol's hold lapsed = %+v, %v; want dave", promoted, err)
+	}
+	again := &WaitlistEntry{EventID: tier.EventID, TicketTypeID: tier.ID, UserID: carol.ID}
+	if err := models.Waitlist.Join(again); err != nil || again.Position != 1 {
+		t.Errorf("Join after a lapsed promotion = %+v, %v; want position 1", again, err)
+	}
+}
+
+func TestWaitlistJoinNeedsASoldOutTier(t *testing.T) {
+	models := newTestModels(t)
+	tier, held := insertSoldOutShow(t, models)
+	erin := insertBuyer(t, models, "erin")
+	expireOrder(t, models, held)
+
+	entry := &WaitlistEntry{EventID: tier.EventID, TicketTypeID: tier.ID, UserID: erin.ID}
+	if err := models.Waitlist.Join(entry); !errors.Is(err, ErrTicketsAvailable) {
+		t.Errorf("Join with a ticket left = %v, want ErrTicketsAvailable", err)
+	}
+}
@@ -0,0 +1,416 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+)
+
+// Webhook delivery statuses. A pending delivery waits for its next
+// attempt; it becomes succeeded once the endpoint answers with a 2xx
+// status and failed when it runs out of attempts.
+const (
+	DeliveryStatusPending   = "pending"
+	DeliveryStatusSucceeded = "succeeded"
+	DeliveryStatusFailed    = "failed"
+)
+
+// JobWebhookDelivery is the type of the jobs that send a delivery.
+// WebhookMaxAttempts is how many times a delivery is tried before it is
+// given up as failed.
+const (
+	JobWebhookDelivery = "webhook.deliver"
+	WebhookMaxAttempts = 8
+)
+
+type WebhookModel struct {
+	DB     *sql.DB
+	ReadDB *sql.DB
+}
+
+// Webhook is an endpoint a user registered to be told about changes to the
+// events they own or co-host. Events lists the event types it subscribes
+// to. Secret signs every delivery; it is only returned when the webhook is
+// created.
+type Webhook struct {
+	ID        int       `json:"id"`
+	UserID    int       `json:"user_id"`
+	URL       string    `json:"url"`
+	Secret    string    `json:"secret,omitempty"`
+	Events    []string  `json:"events"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// WebhookDelivery is one event sent to one webhook. Attempts counts the
+// tries since it was created or last redelivered.
+type WebhookDelivery struct {
+	ID            int       `json:"id"`
+	WebhookID     int       `json:"webhook_id"`
+	EventType     string    `json:"event_type"`
+	Payload       string    `json:"payload"`
+	Status        string    `json:"status"`
+	Attempts      int       `json:"attempts"`
+	NextAttemptAt time.Time `json:"next_attempt_at"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// PendingDelivery is a pending delivery together with where to send it.
+type PendingDelivery struct {
+	WebhookDelivery
+	URL    string
+	Secret string
+}
+
+// WebhookAttempt logs one try of a delivery. StatusCode is 0 when the
+// endpoint could not be reached.
+type WebhookAttempt struct {
+	ID          int       `json:"id"`
+	DeliveryID  int       `json:"delivery_id"`
+	AttemptedAt time.Time `json:"attempted_at"`
+	StatusCode  int       `json:"status_code"`
+	Error       string    `json:"error,omitempty"`
+	DurationMs  int64     `json:"duration_ms"`
+}
+
+func (s *WebhookModel) Insert(webhook *Webhook) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	webhook.CreatedAt = time.Now().UTC()
+	query := `INSERT INTO webhooks (user_id, url, secret, events, created_at) VALUES ($1, $2, $3, $4, $5) RETURNING id`
+	return s.DB.QueryRowContext(ctx, query, webhook.UserID, webhook.URL, webhook.Secret,
+		strings.Join(webhook.Events, ","), webhook.CreatedAt).Scan(&webhook.ID)
+}
+
+// GetByUser lists a user's webhooks, without their secrets.
+func (s *WebhookModel) GetByUser(userID int) ([]*Webhook, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `SELECT id, user_id, url, events, created_at FROM webhooks WHERE user_id = $1 ORDER BY id`
+	rows, err := s.ReadDB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	webhooks := []*Webhook{}
+	for rows.Next() {
+		var webhook Webhook
+		if err := scanWebhook(rows, &webhook); err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, &webhook)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return webhooks, nil
+}
+
+// Get returns a webhook of the user, without its secret, or nil when there
+// is none.
+func (s *WebhookModel) Get(userID, id int) (*Webhook, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `SELECT id, user_id, url, events, created_at FROM webhooks WHERE id = $1 AND user_id = $2`
+	var webhook Webhook
+	err := scanWebhook(s.ReadDB.QueryRowContext(ctx, query, id, userID), &webhook)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+// Delete removes a webhook of the user along with its delivery log.
+func (s *WebhookModel) Delete(userID, id int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `DELETE FROM webhooks WHERE id = $1 AND user_id = $2`
+	return expectOneRow(s.DB.ExecContext(ctx, query, id, userID))
+}
+
+func scanWebhook(row rowScanner, webhook *Webhook) error {
+	var events string
+	if err := row.Scan(&webhook.ID, &webhook.UserID, &webhook.URL, &events, &webhook.CreatedAt); err != nil {
+		return err
+	}
+	webhook.Events = strings.Split(events, ",")
+	return nil
+}
+
+// Enqueue queues payload, the domain event outboxID, for every webhook
+// subscribed to eventType whose user owns or co-hosts the event, along
+// with a JobWebhookDelivery job to send each delivery, and returns how
+// many deliveries were queued. A webhook gets one delivery per domain
+// event, however often the relay hands it over.
+func (s *WebhookModel) Enqueue(outboxID, eventID int, eventType string, payload []byte) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO webhook_deliveries (webhook_id, outbox_id, event_type, payload, status, attempts, next_attempt_at, created_at)
+		SELECT w.id, $outbox, $type, $payload, 'pending', 0, $now, $now
+		FROM webhooks w
+		JOIN event_organizers o ON o.user_id = w.user_id AND o.event_id = $event AND o.role IN ('owner', 'co-host')
+		JOIN users u ON u.id = w.user_id AND u.deleted_at IS NULL
+		WHERE instr(',' || w.events || ',', ',' || $type || ',') > 0
+		ON CONFLICT (webhook_id, outbox_id) DO NOTHING
+		RETURNING id`
+	n, err := queueDeliveries(ctx, tx, query,
+		sql.Named("outbox", outboxID),
+		sql.Named("type", eventType),
+		sql.Named("payload", string(payload)),
+		sql.Named("now", time.Now().UTC()),
+		sql.Named("event", eventID),
+	)
+	if err != nil {
+		return 0, err
+	}
+	return n, tx.Commit()
+}
+
+// enqueueForUser queues payload for every webhook of the user subscribed
+// to eventType, whatever events they organize.
+func enqueueForUser(ctx context.Context, tx *sql.Tx, userID int, eventType string, payload []byte) (int, error) {
+	query := `
+		INSERT INTO webhook_deliveries (webhook_id, event_type, payload, status, attempts, next_attempt_at, created_at)
+		SELECT w.id, $type, $payload, 'pending', 0, $now, $now
+		FROM webhooks w
+		WHERE w.user_id = $user AND instr(',' || w.events || ',', ',' || $type || ',') > 0
+		RETURNING id`
+	return queueDeliveries(ctx, tx, query,
+		sql.Named("type", eventType),
+		sql.Named("payload", string(payload)),
+		sql.Named("now", time.Now().UTC()),
+		sql.Named("user", userID),
+	)
+}
+
+// queueDeliveries runs query, which inserts deliveries and returns their
+// IDs, and adds a job to send each of them.
+func queueDeliveries(ctx context.Context, tx *sql.Tx, query string, args ...any) (int, error) {
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, id := range ids {
+		if err := insertDeliveryJob(ctx, tx, id); err != nil {
+			return 0, err
+		}
+	}
+	return len(ids), nil
+}
+
+// DeliveryJob is the payload of a JobWebhookDelivery job.
+type DeliveryJob struct {
+	DeliveryID int `json:"delivery_id"`
+}
+
+func insertDeliveryJob(ctx context.Context, tx *sql.Tx, deliveryID int) error {
+	job, err := NewJob(JobWebhookDelivery, DeliveryJob{DeliveryID: deliveryID})
+	if err != nil {
+		return err
+	}
+	job.MaxAttempts = WebhookMaxAttempts
+	return insertJob(ctx, tx, job)
+}
+
+// GetPending returns a pending delivery together with where to send it,
+// or nil when it is no longer pending or its webhook is gone.
+func (s *WebhookModel) GetPending(id int) (*PendingDelivery, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `
+		SELECT d.id, d.webhook_id, d.event_type, d.payload, d.status, d.attempts, d.next_attempt_at, d.created_at, w.url, w.secret
+		FROM webhook_deliveries d JOIN webhooks w ON w.id = d.webhook_id
+		WHERE d.id = $1 AND d.status = 'pending'`
+	var delivery PendingDelivery
+	err := s.ReadDB.QueryRowContext(ctx, query, id).Scan(&delivery.ID, &delivery.WebhookID, &delivery.EventType,
+		&delivery.Payload, &delivery.Status, &delivery.Attempts, &delivery.NextAttemptAt, &delivery.CreatedAt,
+		&delivery.URL, &delivery.Secret)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+// RecordAttempt logs an attempt of a pending delivery and moves it to
+// status, to be tried again at next while it stays pending. It returns
+// sql.ErrNoRows when the delivery is no longer pending, as happens when
+// its webhook was deleted meanwhile.
+func (s *WebhookModel) RecordAttempt(attempt *WebhookAttempt, status string, next time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+		UPDATE webhook_deliveries SET status = $1, attempts = attempts + 1, next_attempt_at = $2
+		WHERE id = $3 AND status = 'pending'`
+	if err := expectOneRow(tx.ExecContext(ctx, query, status, next.UTC(), attempt.DeliveryID)); err != nil {
+		return err
+	}
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO webhook_attempts (delivery_id, attempted_at, status_code, error, duration_ms)
+		VALUES ($1, $2, $3, $4, $5) RETURNING id`,
+		attempt.DeliveryID, attempt.AttemptedAt.UTC(), attempt.StatusCode, attempt.Error, attempt.DurationMs,
+	).Scan(&attempt.ID)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetDeliveries lists the deliveries of a webhook, newest first.
+func (s *WebhookModel) GetDeliveries(webhookID, limit, offset int) ([]*WebhookDelivery, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	if limit <= 0 || limit > 100 {
+		limit = 100
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	query := `
+		SELECT id, webhook_id, event_type, payload, status, attempts, next_attempt_at, created_at
+		FROM webhook_deliveries WHERE webhook_id = $1 ORDER BY id DESC LIMIT $2 OFFSET $3`
+	rows, err := s.ReadDB.QueryContext(ctx, query, webhookID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []*WebhookDelivery{}
+	for rows.Next() {
+		var delivery WebhookDelivery
+		if err := scanDelivery(rows, &delivery); err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, &delivery)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+// GetDelivery returns a delivery of the webhook, or nil when there is
+// none.
+func (s *WebhookModel) GetDelivery(webhookID, id int) (*WebhookDelivery, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `
+		SELECT id, webhook_id, event_type, payload, status, attempts, next_attempt_at, created_at
+		FROM webhook_deliveries WHERE id = $1 AND webhook_id = $2`
+	var delivery WebhookDelivery
+	err := scanDelivery(s.ReadDB.QueryRowContext(ctx, query, id, webhookID), &delivery)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+func scanDelivery(row rowScanner, delivery *WebhookDelivery) error {
+	return row.Scan(&delivery.ID, &delivery.WebhookID, &delivery.EventType, &delivery.Payload, &delivery.Status,
+		&delivery.Attempts, &delivery.NextAttemptAt, &delivery.CreatedAt)
+}
+
+// GetAttempts lists every attempt of a delivery, oldest first.
+func (s *WebhookModel) GetAttempts(deliveryID int) ([]*WebhookAttempt, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `
+		SELECT id, delivery_id, attempted_at, status_code, error, duration_ms
+		FROM webhook_attempts WHERE delivery_id = $1 ORDER BY id`
+	rows, err := s.ReadDB.QueryContext(ctx, query, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	attempts := []*WebhookAttempt{}
+	for rows.Next() {
+		var attempt WebhookAttempt
+		err := rows.Scan(&attempt.ID, &attempt.DeliveryID, &attempt.AttemptedAt, &attempt.StatusCode, &attempt.Error, &attempt.DurationMs)
+		if err != nil {
+			return nil, err
+		}
+		attempts = append(attempts, &attempt)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return attempts, nil
+}
+
+// Redeliver queues a succeeded or failed delivery to be sent again right
+// away with a fresh set of attempts. Its attempt log is kept. It returns
+// sql.ErrNoRows when the delivery is still pending.
+func (s *WebhookModel) Redeliver(delivery *WebhookDelivery) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+	query := `UPDATE webhook_deliveries SET status = 'pending', attempts = 0, next_attempt_at = $1 WHERE id = $2 AND status <> 'pending'`
+	if err := expectOneRow(tx.ExecContext(ctx, query, now, delivery.ID)); err != nil {
+		return err
+	}
+	if err := insertDeliveryJob(ctx, tx, delivery.ID); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	delivery.Status = DeliveryStatusPending
+	delivery.Attempts = 0
+	delivery.NextAttemptAt = now
+	return nil
+}
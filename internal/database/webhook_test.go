@@ -0,0 +1,140 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+)
+
+// insertSubscriber inserts a user who subscribes webhooks to changes of
+// the event they own.
+func insertSubscriber(t *testing.T, models Models, name string) (*User, *Event) {
+	t.Helper()
+	user := &User{Username: name, Email: name + "@example.com", Password: "x"}
+	if err := models.Users.Insert(Actor{}, user); err != nil {
+		t.Fatal(err)
+	}
+	event := &Event{OwnerId: user.ID, Name: "Hackathon", Description: "Forty-eight hours of building", Date: "2026-11-21", Location: "Adama"}
+	if err := models.Events.Insert(Actor{}, event); err != nil {
+		t.Fatal(err)
+	}
+	return user, event
+}
+
+func TestWebhookEnqueueOncePerOutboxEvent(t *testing.T) {
+	models := newTestModels(t)
+	owner, event := insertSubscriber(t, models, "integrator")
+	other, _ := insertSubscriber(t, models, "bystander")
+
+	subscribed := &Webhook{UserID: owner.ID, URL: "https://example.com/a", Secret: "s", Events: []string{"event.created", "event.updated"}}
+	unsubscribed := &Webhook{UserID: owner.ID, URL: "https://example.com/b", Secret: "s", Events: []string{"attendee.added"}}
+	stranger := &Webhook{UserID: other.ID, URL: "https://example.com/c", Secret: "s", Events: []string{"event.updated"}}
+	for _, webhook := range []*Webhook{subscribed, unsubscribed, stranger} {
+		if err := models.Webhooks.Insert(webhook); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	n, err := models.Webhooks.Enqueue(7, event.ID, "event.updated", []byte(`{}`))
+	if err != nil || n != 1 {
+		t.Fatalf("Enqueue = %d, %v; want 1 delivery", n, err)
+	}
+	// The relay hands the same outbox row over again after a crash.
+	n, err = models.Webhooks.Enqueue(7, event.ID, "event.updated", []byte(`{}`))
+	if err != nil || n != 0 {
+		t.Fatalf("second Enqueue = %d, %v; want 0 deliveries", n, err)
+	}
+	n, err = models.Webhooks.Enqueue(8, event.ID, "event.updated", []byte(`{}`))
+	if err != nil || n != 1 {
+		t.Fatalf("Enqueue of the next outbox event = %d, %v; want 1 delivery", n, err)
+	}
+
+	deliveries, err := models.Webhooks.GetDeliveries(subscribed.ID, 0, 0)
+	if err != nil || len(deliveries) != 2 {
+		t.Fatalf("GetDeliveries = %d, %v; want 2", len(deliveries), err)
+	}
+	jobs, err := models.Jobs.Find(JobFilter{Type: JobWebhookDelivery})
+	if err != nil || len(jobs) != 2 {
+		t.Fatalf("delivery jobs = %d, %v; want 2", len(jobs), err)
+	}
+	if jobs[0].MaxAttempts != WebhookMaxAttempts {
+		t.Errorf("job MaxAttempts = %d, want %d", jobs[0].MaxAttempts, WebhookMaxAttempts)
+	}
+}
+
+func TestWebhookAttemptsAndRedeliver(t *testing.T) {
+	models := newTestModels(t)
+	owner, event := insertSubscriber(t, models, "integrator")
+	webhook := &Webhook{UserID: owner.ID, URL: "https://example.com/a", Secret: "s", Events: []string{"event.updated"}}
+	if err := models.Webhooks.Insert(webhook); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := models.Webhooks.Enqueue(1, event.ID, "event.updated", []byte(`{"a":1}`)); err != nil {
+		t.Fatal(err)
+	}
+	deliveries, err := models.Webhooks.GetDeliveries(webhook.ID, 0, 0)
+	if err != nil || len(deliveries) != 1 {
+		t.Fatalf("GetDeliveries = %d, %v", len(deliveries), err)
+	}
+	id := deliveries[0].ID
+
+	pending, err := models.Webhooks.GetPending(id)
+	if err != nil || pending == nil {
+		t.Fatalf("GetPending = %v, %v", pending, err)
+	}
+	if pending.URL != webhook.URL || pending.Secret != "s" || pending.Payload != `{"a":1}` {
+		t.Errorf("GetPending = %+v", pending)
+	}
+
+	if err := models.Webhooks.Redeliver(deliveries[0]); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Redeliver of a pending delivery = %v, want sql.ErrNoRows", err)
+	}
+
+	now := time.Now()
+	failed := &WebhookAttempt{DeliveryID: id, AttemptedAt: now, StatusCode: 500, Error: "endpoint answered 500", DurationMs: 12}
+	if err := models.Webhooks.RecordAttempt(failed, DeliveryStatusPending, now.Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	succeeded := &WebhookAttempt{DeliveryID: id, AttemptedAt: now, StatusCode: 200, DurationMs: 8}
+	if err := models.Webhooks.RecordAttempt(succeeded, DeliveryStatusSucceeded, now); err != nil {
+		t.Fatal(err)
+	}
+	if err := models.Webhooks.RecordAttempt(&WebhookAttempt{DeliveryID: id, AttemptedAt: now}, DeliveryStatusSucceeded, now); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("RecordAttempt on a finished delivery = %v, want sql.ErrNoRows", err)
+	}
+	if pending, err := models.Webhooks.GetPending(id); err != nil || pending != nil {
+		t.Errorf("GetPending of a succeeded delivery = %v, %v; want nil", pending, err)
+	}
+
+	attempts, err := models.Webhooks.GetAttempts(id)
+	if err != nil || len(attempts) != 2 {
+		t.Fatalf("GetAttempts = %d, %v; want 2", len(attempts), err)
+	}
+	if attempts[0].StatusCode != 500 || attempts[0].Error == "" || attempts[1].StatusCode != 200 {
+		t.Errorf("attempts logged out of order or incomplete: %+v, %+v", attempts[0], attempts[1])
+	}
+
+	delivery, err := models.Webhooks.GetDelivery(webhook.ID, id)
+	if err != nil || delivery == nil {
+		t.Fatalf("GetDelivery = %v, %v", delivery, err)
+	}
+	if delivery.Status != DeliveryStatusSucceeded || delivery.Attempts != 2 {
+		t.Errorf("delivery = %s after %d attempts, want succeeded after 2", delivery.Status, delivery.Attempts)
+	}
+
+	if err := models.Webhooks.Redeliver(delivery); err != nil {
+		t.Fatal(err)
+	}
+	delivery, err = models.Webhooks.GetDelivery(webhook.ID, id)
+	if err != nil || delivery.Status != DeliveryStatusPending || delivery.Attempts != 0 {
+		t.Errorf("redelivered delivery = %+v, %v; want pending with no attempts", delivery, err)
+	}
+	if attempts, _ := models.Webhooks.GetAttempts(id); len(attempts) != 2 {
+		t.Errorf("Redeliver dropped the attempt log: %d attempts left", len(attempts))
+	}
+	jobs, err := models.Jobs.Find(JobFilter{Type: JobWebhookDelivery})
+	if err != nil || len(jobs) != 2 {
+		t.Errorf("delivery jobs = %d, %v; want 2 after Redeliver", len(jobs), err)
+	}
+}
@@ -0,0 +1,28 @@
+// Package markdown renders user-written Markdown to HTML that is safe to
+// embed in a page.
+package markdown
+
+import (
+	"bytes"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+)
+
+var (
+	renderer = goldmark.New(goldmark.WithExtensions(extension.GFM))
+	// policy keeps the formatting Markdown produces and drops scripts,
+	// styles, event handlers and unsafe URLs, whether they came from raw
+	// HTML in the source or from a crafted link.
+	policy = bluemonday.UGCPolicy().RequireNoFollowOnLinks(true).AddTargetBlankToFullyQualifiedLinks(true)
+)
+
+// Render converts source to sanitized HTML.
+func Render(source string) (string, error) {
+	var buf bytes.Buffer
+	if err := renderer.Convert([]byte(source), &buf); err != nil {
+		return "", err
+	}
+	return policy.Sanitize(buf.String()), nil
+}
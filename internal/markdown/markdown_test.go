@@ -0,0 +1,75 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  string
+		want    []string
+		notWant []string
+	}{
+		{
+			name:   "formatting",
+			source: "**Doors** open at *six*\n\n- bring a ~~ticket~~ ID",
+			want:   []string{"<strong>Doors</strong>", "<em>six</em>", "<li>", "<del>ticket</del>"},
+		},
+		{
+			name:   "table",
+			source: "| Time | Talk |\n|---|---|\n| 18:00 | Welcome |",
+			want:   []string{"<table>", "<th>Time</th>", "<td>Welcome</td>"},
+		},
+		{
+			name:   "external link",
+			source: "[Venue map](https://example.com/map)",
+			want:   []string{`href="https://example.com/map"`, `rel="nofollow noopener"`, `target="_blank"`},
+		},
+		{
+			name:    "script tag",
+			source:  "Hello <script>alert(1)</script>",
+			notWant: []string{"<script"},
+		},
+		{
+			name:    "javascript link",
+			source:  "[click](javascript:alert(1))",
+			want:    []string{"click"},
+			notWant: []string{"javascript:", "href"},
+		},
+		{
+			name:    "event handler",
+			source:  `<img src=x onerror="alert(1)">`,
+			notWant: []string{"onerror", "<img"},
+		},
+		{
+			name:    "inline style",
+			source:  `<a href="/x" style="position:fixed">y</a>`,
+			notWant: []string{"style", "position"},
+		},
+		{
+			name:    "iframe",
+			source:  `<iframe src="https://example.com"></iframe>`,
+			notWant: []string{"<iframe"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			html, err := Render(tt.source)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(html, want) {
+					t.Errorf("Render(%q) = %q, want it to contain %q", tt.source, html, want)
+				}
+			}
+			for _, notWant := range tt.notWant {
+				if strings.Contains(html, notWant) {
+					t.Errorf("Render(%q) = %q, want no %q", tt.source, html, notWant)
+				}
+			}
+		})
+	}
+}
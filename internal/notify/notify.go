@@ -0,0 +1,37 @@
+// Package notify delivers messages about events to users.
+package notify
+
+import (
+	"context"
+	"log"
+)
+
+// Message is one notification addressed to a single user.
+type Message struct {
+	UserID  int
+	Email   string
+	EventID int
+	Subject string
+	Body    string
+}
+
+// Notifier delivers messages. Implementations must be safe for concurrent
+// use.
+type Notifier interface {
+	Notify(ctx context.Context, msg Message) error
+}
+
+// LogNotifier writes every message to a logger instead of delivering it.
+// It is used until a real delivery channel is configured.
+type LogNotifier struct {
+	Logger *log.Logger
+}
+
+func (n LogNotifier) Notify(ctx context.Context, msg Message) error {
+	logger := n.Logger
+	if logger == nil {
+		logger = log.Default()
+	}
+	logger.Printf("notify user %d <%s>: %s", msg.UserID, msg.Email, msg.Subject)
+	return nil
+}
@@ -0,0 +1,134 @@
+// Package outbox publishes the domain events written to the outbox table
+// to in-process subscribers and external sinks.
+package outbox
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/Yiheyistm/go-restful-api/internal/database"
+)
+
+// Handler receives a domain event. Returning an error leaves the event
+// unpublished, so it is handed to every subscriber and sink again later.
+type Handler func(ctx context.Context, event *database.DomainEvent) error
+
+const (
+	defaultBatchSize = 100
+	maxRetryDelay    = time.Minute
+)
+
+type subscription struct {
+	eventType string
+	handler   Handler
+}
+
+type aggregateKey struct {
+	aggregateType string
+	aggregateID   int
+}
+
+// Relay polls the outbox and publishes every domain event to the
+// subscribers of its type and to every sink, then marks it published.
+// Delivery is at least once: an event is published again until all of
+// them accept it. Events of one aggregate are published in order; while
+// one is failing, the later ones of its aggregate wait.
+type Relay struct {
+	Outbox       *database.OutboxModel
+	Sinks        []Sink
+	PollInterval time.Duration
+	BatchSize    int
+
+	subscribers []subscription
+	failures    map[int]int
+	retryAt     map[int]time.Time
+}
+
+// Subscribe registers handler for domain events of eventType, or for all
+// of them when eventType is empty. Subscribe before calling Run.
+func (r *Relay) Subscribe(eventType string, handler Handler) {
+	r.subscribers = append(r.subscribers, subscription{eventType: eventType, handler: handler})
+}
+
+// Run publishes domain events until ctx is cancelled.
+func (r *Relay) Run(ctx context.Context) {
+	r.failures = map[int]int{}
+	r.retryAt = map[int]time.Time{}
+	ticker := time.NewTicker(r.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		r.relay(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// relay publishes what is in the outbox, a batch at a time. It reads on
+// past events it cannot publish yet, so an aggregate that keeps failing
+// only holds up its own later events.
+func (r *Relay) relay(ctx context.Context) {
+	batchSize := r.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultBatchSize
+	}
+
+	blocked := map[aggregateKey]bool{}
+	afterID := 0
+	for {
+		events, err := r.Outbox.Unpublished(afterID, batchSize)
+		if err != nil {
+			log.Println("Outbox read error:", err)
+			return
+		}
+		for _, event := range events {
+			if ctx.Err() != nil {
+				return
+			}
+			afterID = event.ID
+			key := aggregateKey{event.AggregateType, event.AggregateID}
+			if blocked[key] || time.Now().Before(r.retryAt[event.ID]) {
+				blocked[key] = true
+				continue
+			}
+			if err := r.publish(ctx, event); err != nil {
+				blocked[key] = true
+				r.failures[event.ID]++
+				delay := min(time.Second<<min(r.failures[event.ID], 6), maxRetryDelay)
+				r.retryAt[event.ID] = time.Now().Add(delay)
+				log.Printf("Outbox event %d (%s) publish error, retrying in %s: %v", event.ID, event.Type, delay, err)
+				continue
+			}
+			delete(r.failures, event.ID)
+			delete(r.retryAt, event.ID)
+			if err := r.Outbox.MarkPublished(event); err != nil {
+				log.Printf("Outbox event %d mark error: %v", event.ID, err)
+				return
+			}
+		}
+		if len(events) < batchSize {
+			return
+		}
+	}
+}
+
+func (r *Relay) publish(ctx context.Context, event *database.DomainEvent) error {
+	for _, s := range r.subscribers {
+		if s.eventType != "" && s.eventType != event.Type {
+			continue
+		}
+		if err := s.handler(ctx, event); err != nil {
+			return err
+		}
+	}
+	for _, sink := range r.Sinks {
+		if err := sink.Publish(ctx, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/Yiheyistm/go-restful-api/internal/database"
+)
+
+func newTestRelay(t *testing.T) (*Relay, *database.DB) {
+	t.Helper()
+	cfg := database.DefaultConfig()
+	cfg.Path = filepath.Join(t.TempDir(), "test.db")
+	db, err := database.Open(cfg)
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := db.MigrateUp("../../cmd/migrate/migrations"); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	models := database.NewModels(db)
+	return &Relay{
+		Outbox:   &models.Outbox,
+		failures: map[int]int{},
+		retryAt:  map[int]time.Time{},
+	}, db
+}
+
+// writeEvents adds a domain event to the outbox for each aggregate ID in
+// turn and returns their IDs.
+func writeEvents(t *testing.T, db *database.DB, aggregateIDs ...int) []int {
+	t.Helper()
+	var ids []int
+	for _, aggregateID := range aggregateIDs {
+		var id int
+		err := db.Writer.QueryRow(`
+			INSERT INTO outbox (aggregate_type, aggregate_id, event_type, payload, created_at)
+			VALUES ('event', $1, 'event.updated', '{}', $2) RETURNING id`,
+			aggregateID, time.Now().UTC()).Scan(&id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func unpublished(t *testing.T, r *Relay) []int {
+	t.Helper()
+	events, err := r.Outbox.Unpublished(0, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []int
+	for _, event := range events {
+		ids = append(ids, event.ID)
+	}
+	return ids
+}
+
+func TestRelayPublishesInOrder(t *testing.T) {
+	r, db := newTestRelay(t)
+	r.BatchSize = 2
+	ids := writeEvents(t, db, 1, 2, 1, 2, 1)
+
+	var got []int
+	r.Subscribe("", func(ctx context.Context, event *database.DomainEvent) error {
+		got = append(got, event.ID)
+		return nil
+	})
+	var created int
+	r.Subscribe(database.DomainEventCreated, func(ctx context.Context, event *database.DomainEvent) error {
+		created++
+		return nil
+	})
+	var sink bytes.Buffer
+	r.Sinks = []Sink{&WriterSink{W: &sink}}
+	r.relay(context.Background())
+
+	if !slices.Equal(got, ids) {
+		t.Errorf("published %v, want %v", got, ids)
+	}
+	if created != 0 {
+		t.Errorf("an event.created subscriber got %d event.updated events", created)
+	}
+	if lines := bytes.Count(sink.Bytes(), []byte("\n")); lines != len(ids) {
+		t.Errorf("sink got %d events, want %d", lines, len(ids))
+	}
+	if left := unpublished(t, r); len(left) != 0 {
+		t.Errorf("events %v left unpublished", left)
+	}
+}
+
+func TestRelayFailingAggregateOnlyBlocksItself(t *testing.T) {
+	r, db := newTestRelay(t)
+	r.BatchSize = 2
+	// Aggregate 1 fills more than a batch, so the relay has to read on past
+	// it to reach aggregate 2.
+	ids := writeEvents(t, db, 1, 1, 1, 2, 2)
+
+	failing := true
+	var got []int
+	r.Subscribe("", func(ctx context.Context, event *database.DomainEvent) error {
+		if event.AggregateID == 1 && failing {
+			got = append(got, -event.ID)
+			return errors.New("sink down")
+		}
+		got = append(got, event.ID)
+		return nil
+	})
+
+	r.relay(context.Background())
+	if want := []int{-ids[0], ids[3], ids[4]}; !slices.Equal(got, want) {
+		t.Errorf("first relay handled %v, want %v", got, want)
+	}
+	if left := unpublished(t, r); !slices.Equal(left, ids[:3]) {
+		t.Errorf("unpublished %v, want %v", left, ids[:3])
+	}
+
+	// The failed event waits out its retry delay.
+	got = nil
+	r.relay(context.Background())
+	if len(got) != 0 {
+		t.Errorf("relay during the retry delay handled %v", got)
+	}
+
+	failing = false
+	r.retryAt[ids[0]] = time.Now()
+	got = nil
+	r.relay(context.Background())
+	if !slices.Equal(got, ids[:3]) {
+		t.Errorf("relay after recovery handled %v, want %v", got, ids[:3])
+	}
+	if left := unpublished(t, r); len(left) != 0 {
+		t.Errorf("events %v left unpublished", left)
+	}
+	if len(r.failures) != 0 || len(r.retryAt) != 0 {
+		t.Errorf("retry state kept after publishing: %v, %v", r.failures, r.retryAt)
+	}
+}
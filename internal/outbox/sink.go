@@ -0,0 +1,113 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/Yiheyistm/go-restful-api/internal/database"
+	"github.com/Yiheyistm/go-restful-api/internal/webhook"
+)
+
+// Sink is an external destination for domain events.
+type Sink interface {
+	Publish(ctx context.Context, event *database.DomainEvent) error
+}
+
+// WriterSink writes each domain event to W as one line of JSON.
+type WriterSink struct {
+	W  io.Writer
+	mu sync.Mutex
+}
+
+func (s *WriterSink) Publish(ctx context.Context, event *database.DomainEvent) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.W.Write(append(line, '\n'))
+	return err
+}
+
+// FileSink appends each domain event to the file at Path as one line of
+// JSON, syncing it to disk before the event counts as published.
+type FileSink struct {
+	Path string
+	mu   sync.Mutex
+}
+
+func (s *FileSink) Publish(ctx context.Context, event *database.DomainEvent) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.OpenFile(s.Path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// WebhookSink posts each domain event to URL, signed with Secret the same
+// way as user webhooks. The outbox ID is sent as the Webhook-Id so the
+// receiver can drop events it has already seen.
+type WebhookSink struct {
+	URL    string
+	Secret string
+	Sender webhook.Sender
+}
+
+func (s *WebhookSink) Publish(ctx context.Context, event *database.DomainEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return s.Sender.Send(ctx, webhook.Request{
+		URL:        s.URL,
+		Secret:     s.Secret,
+		DeliveryID: event.ID,
+		EventType:  event.Type,
+		Body:       body,
+	}).Err
+}
+
+// ParseSinks builds sinks from a comma-separated list of "stdout",
+// "file:<path>" and "webhook:<url>" entries. Webhook sinks sign with
+// webhookSecret. They are set by the operator, not by users, so unlike
+// user webhooks they may post to private addresses.
+func ParseSinks(spec, webhookSecret string) ([]Sink, error) {
+	var sinks []Sink
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		kind, target, _ := strings.Cut(entry, ":")
+		switch {
+		case entry == "":
+		case kind == "stdout":
+			sinks = append(sinks, &WriterSink{W: os.Stdout})
+		case kind == "file" && target != "":
+			sinks = append(sinks, &FileSink{Path: target})
+		case kind == "webhook" && target != "":
+			sinks = append(sinks, &WebhookSink{URL: target, Secret: webhookSecret, Sender: webhook.Sender{AllowPrivate: true}})
+		default:
+			return nil, fmt.Errorf("unknown outbox sink %q", entry)
+		}
+	}
+	return sinks, nil
+}
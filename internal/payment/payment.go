@@ -0,0 +1,48 @@
+// Package payment takes money for ticket orders through a pluggable
+// provider.
+package payment
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+// Webhook event types a provider reports back.
+const (
+	EventSucceeded = "payment.succeeded"
+	EventFailed    = "payment.failed"
+)
+
+// ErrInvalidSignature is returned by ParseWebhook when a webhook is not
+// signed by the provider, or its signature is too old.
+var ErrInvalidSignature = errors.New("invalid webhook signature")
+
+// Checkout describes what the buyer is asked to pay for one order.
+type Checkout struct {
+	OrderID     int
+	AmountMinor int64
+	Currency    string
+	Description string
+}
+
+// Session is a payment started at the provider. Reference identifies it
+// in later webhooks; URL, when set, is where the buyer completes it.
+type Session struct {
+	Reference string
+	URL       string
+}
+
+// WebhookEvent is a verified notification about a payment.
+type WebhookEvent struct {
+	Type      string `json:"type"`
+	Reference string `json:"reference"`
+}
+
+// Provider starts payments and verifies the webhooks reporting their
+// outcome. Implementations must be safe for concurrent use.
+type Provider interface {
+	Name() string
+	CreateCheckout(ctx context.Context, checkout Checkout) (*Session, error)
+	ParseWebhook(header http.Header, body []byte) (*WebhookEvent, error)
+}
@@ -0,0 +1,165 @@
+// Package queue runs background jobs stored in the jobs table with a pool
+// of workers.
+package queue
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/Yiheyistm/go-restful-api/internal/database"
+)
+
+// Handler does the work of one job. A returned error fails the attempt;
+// the job is retried with Backoff until it runs out of attempts. ctx is
+// cancelled when the lease on the job ends or the queue shuts down.
+type Handler func(ctx context.Context, job *database.Job) error
+
+const (
+	firstRetryDelay = 30 * time.Second
+	maxRetryDelay   = 6 * time.Hour
+)
+
+// Backoff returns how long a job waits after its attempts-th failed
+// attempt: 30s, doubling each time, at most 6h.
+func Backoff(attempts int) time.Duration {
+	delay := firstRetryDelay
+	for i := 1; i < attempts && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, maxRetryDelay)
+}
+
+// Queue dispatches claimed jobs to the handlers registered for their type.
+// Register every handler before calling Run.
+type Queue struct {
+	Jobs *database.JobModel
+	// Workers is how many jobs run at once.
+	Workers int
+	// PollInterval is how long an idle worker waits before looking for
+	// due jobs again.
+	PollInterval time.Duration
+	// Lease is how long a worker holds a job before another may claim it;
+	// it bounds how long a handler may run.
+	Lease time.Duration
+
+	handlers  map[string]Handler
+	recurring map[string]time.Duration
+}
+
+// Register sets the handler for jobs of jobType.
+func (q *Queue) Register(jobType string, handler Handler) {
+	if q.handlers == nil {
+		q.handlers = map[string]Handler{}
+	}
+	q.handlers[jobType] = handler
+}
+
+// Every registers handler for jobType and keeps one such job scheduled,
+// running it when the queue starts and then interval after each run
+// finishes, whether it succeeded or died.
+func (q *Queue) Every(jobType string, interval time.Duration, handler Handler) {
+	q.Register(jobType, handler)
+	if q.recurring == nil {
+		q.recurring = map[string]time.Duration{}
+	}
+	q.recurring[jobType] = interval
+}
+
+// Run starts the workers and blocks until ctx is cancelled and every
+// worker has stopped. Jobs interrupted by the shutdown are handed back
+// without using up an attempt.
+func (q *Queue) Run(ctx context.Context) {
+	for jobType := range q.recurring {
+		q.schedule(jobType, time.Now())
+	}
+
+	var wg sync.WaitGroup
+	for range max(q.Workers, 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			q.work(ctx)
+		}()
+	}
+	wg.Wait()
+}
+
+func (q *Queue) work(ctx context.Context) {
+	for ctx.Err() == nil {
+		job, err := q.Jobs.Claim(q.Lease)
+		if err != nil {
+			log.Println("Job claim error:", err)
+		}
+		if job == nil {
+			select {
+			case <-ctx.Done():
+			case <-time.After(q.PollInterval):
+			}
+			continue
+		}
+		q.process(ctx, job)
+	}
+}
+
+func (q *Queue) process(ctx context.Context, job *database.Job) {
+	handler, ok := q.handlers[job.Type]
+	if !ok {
+		// Nothing can ever run it, so it goes straight to the dead letters.
+		reason := fmt.Sprintf("no handler for job type %q", job.Type)
+		log.Printf("Job %d (%s) buried: %s", job.ID, job.Type, reason)
+		if err := q.Jobs.Bury(job, reason); err != nil && !errors.Is(err, sql.ErrNoRows) {
+			log.Printf("Job %d failure log error: %v", job.ID, err)
+		}
+		return
+	}
+
+	jobCtx, cancel := context.WithTimeout(ctx, q.Lease)
+	err := run(jobCtx, handler, job)
+	cancel()
+	if err != nil && ctx.Err() != nil {
+		if err := q.Jobs.Release(job); err != nil && !errors.Is(err, sql.ErrNoRows) {
+			log.Printf("Job %d release error: %v", job.ID, err)
+		}
+		return
+	}
+
+	if err != nil {
+		q.fail(job, err)
+	} else if err := q.Jobs.Complete(job); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		log.Printf("Job %d completion error: %v", job.ID, err)
+	}
+	if interval, ok := q.recurring[job.Type]; ok && job.Status != database.JobStatusQueued {
+		q.schedule(job.Type, time.Now().Add(interval))
+	}
+}
+
+func (q *Queue) fail(job *database.Job, cause error) {
+	log.Printf("Job %d (%s) attempt %d/%d failed: %v", job.ID, job.Type, job.Attempts, job.MaxAttempts, cause)
+	err := q.Jobs.Fail(job, cause.Error(), time.Now().Add(Backoff(job.Attempts)))
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		log.Printf("Job %d failure log error: %v", job.ID, err)
+	}
+}
+
+func (q *Queue) schedule(jobType string, at time.Time) {
+	_, err := q.Jobs.EnqueueOnce(&database.Job{Type: jobType, RunAt: at})
+	if err != nil {
+		log.Printf("Failed to schedule %s job: %v", jobType, err)
+	}
+}
+
+// run calls handler, turning a panic into an error so one bad job cannot
+// take a worker down.
+func run(ctx context.Context, handler Handler, job *database.Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return handler(ctx, job)
+}
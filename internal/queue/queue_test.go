@@ -0,0 +1,168 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Yiheyistm/go-restful-api/internal/database"
+)
+
+func newTestQueue(t *testing.T) *Queue {
+	t.Helper()
+	cfg := database.DefaultConfig()
+	cfg.Path = filepath.Join(t.TempDir(), "test.db")
+	db, err := database.Open(cfg)
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := db.MigrateUp("../../cmd/migrate/migrations"); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	models := database.NewModels(db)
+	return &Queue{Jobs: &models.Jobs, Workers: 1, PollInterval: 10 * time.Millisecond, Lease: time.Minute}
+}
+
+// claim queues a job of jobType and claims it, as a worker would.
+func claim(t *testing.T, q *Queue, jobType string) *database.Job {
+	t.Helper()
+	if err := q.Jobs.Enqueue(&database.Job{Type: jobType}); err != nil {
+		t.Fatal(err)
+	}
+	job, err := q.Jobs.Claim(q.Lease)
+	if err != nil || job == nil {
+		t.Fatalf("Claim = %v, %v", job, err)
+	}
+	return job
+}
+
+func get(t *testing.T, q *Queue, id int) *database.Job {
+	t.Helper()
+	job, err := q.Jobs.Get(id)
+	if err != nil || job == nil {
+		t.Fatalf("Get(%d) = %v, %v", id, job, err)
+	}
+	return job
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{0, 30 * time.Second},
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{8, 64 * time.Minute},
+		{10, 256 * time.Minute},
+		{11, 6 * time.Hour},
+		{1000, 6 * time.Hour},
+	}
+	for _, tt := range tests {
+		if got := Backoff(tt.attempts); got != tt.want {
+			t.Errorf("Backoff(%d) = %s, want %s", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestProcess(t *testing.T) {
+	q := newTestQueue(t)
+	q.Register("ok", func(ctx context.Context, job *database.Job) error { return nil })
+	q.Register("fail", func(ctx context.Context, job *database.Job) error { return errors.New("boom") })
+	q.Register("panic", func(ctx context.Context, job *database.Job) error { panic("bad payload") })
+
+	job := claim(t, q, "ok")
+	q.process(context.Background(), job)
+	if got := get(t, q, job.ID); got.Status != database.JobStatusSucceeded {
+		t.Errorf("successful job is %s, want succeeded", got.Status)
+	}
+
+	job = claim(t, q, "fail")
+	q.process(context.Background(), job)
+	got := get(t, q, job.ID)
+	if got.Status != database.JobStatusQueued || got.LastError != "boom" {
+		t.Errorf("failed job = %+v, want queued with its error", got)
+	}
+	if wait := time.Until(got.RunAt); wait < Backoff(1)-5*time.Second || wait > Backoff(1) {
+		t.Errorf("failed job retries in %s, want about %s", wait, Backoff(1))
+	}
+
+	job = claim(t, q, "panic")
+	q.process(context.Background(), job)
+	if got := get(t, q, job.ID); got.Status != database.JobStatusQueued || !strings.Contains(got.LastError, "panic: bad payload") {
+		t.Errorf("panicking job = %+v, want queued with the panic as its error", got)
+	}
+
+	job = claim(t, q, "unknown")
+	q.process(context.Background(), job)
+	if got := get(t, q, job.ID); got.Status != database.JobStatusDead || got.Attempts != 1 {
+		t.Errorf("job without a handler = %+v, want dead after one attempt", got)
+	}
+}
+
+func TestProcessReleasesOnShutdown(t *testing.T) {
+	q := newTestQueue(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	q.Register("slow", func(jobCtx context.Context, job *database.Job) error {
+		cancel()
+		<-jobCtx.Done()
+		return jobCtx.Err()
+	})
+
+	job := claim(t, q, "slow")
+	q.process(ctx, job)
+	got := get(t, q, job.ID)
+	if got.Status != database.JobStatusQueued || got.Attempts != 0 || got.LastError != "" {
+		t.Errorf("interrupted job = %+v, want queued again without using an attempt", got)
+	}
+}
+
+func TestRunSchedulesRecurringJobs(t *testing.T) {
+	q := newTestQueue(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	ran := make(chan struct{}, 1)
+	q.Every("scan", time.Hour, func(ctx context.Context, job *database.Job) error {
+		ran <- struct{}{}
+		return nil
+	})
+
+	done := make(chan struct{})
+	go func() {
+		q.Run(ctx)
+		close(done)
+	}()
+	select {
+	case <-ran:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the recurring job did not run when the queue started")
+	}
+
+	// The next run is scheduled once the first one is recorded.
+	deadline := time.Now().Add(5 * time.Second)
+	var queued []*database.Job
+	for time.Now().Before(deadline) {
+		var err error
+		queued, err = q.Jobs.Find(database.JobFilter{Type: "scan", Status: database.JobStatusQueued})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(queued) > 0 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	<-done
+
+	if len(queued) != 1 {
+		t.Fatalf("%d scan jobs queued, want 1", len(queued))
+	}
+	if wait := time.Until(queued[0].RunAt); wait < 59*time.Minute || wait > time.Hour {
+		t.Errorf("next scan in %s, want about an hour", wait)
+	}
+}
@@ -0,0 +1,129 @@
+// Package stream fans live changes out to Server-Sent Events and
+// WebSocket subscribers and keeps the latest ones for clients that reconnect.
+package stream
+
+import (
+	"sync"
+
+	"github.com/Yiheyistm/go-restful-api/internal/database"
+)
+
+// Message is one change to an event or its attendees. ID is the ID of the
+// domain event it came from, so IDs only grow. Event is the event as of
+// the change; Attendee and Counts, the event's attendance after it, are
+// set for attendee changes. Promotion and Counts are set when the
+// waitlist promotes a user.
+type Message struct {
+	ID        int
+	Type      string
+	Event     *database.Event
+	Attendee  *database.Attendee
+	Promotion *database.WaitlistEntry
+	Counts    *database.CheckInCounts
+}
+
+// subscriberBuffer is how many messages a subscriber may fall behind
+// before it is dropped.
+const subscriberBuffer = 64
+
+// Subscription receives every message published after it was made. C is
+// closed when the subscriber falls too far behind or the broker closes; a
+// client that reconnects with the last ID it saw gets the rest replayed.
+type Subscription struct {
+	C <-chan Message
+	c chan Message
+}
+
+// Broker publishes messages to subscribers and keeps the last Capacity
+// of them for replay.
+type Broker struct {
+	Capacity int
+
+	mu          sync.Mutex
+	buffer      []Message
+	floor       int
+	started     bool
+	closed      bool
+	subscribers map[*Subscription]struct{}
+}
+
+// Publish sends msg to every subscriber and adds it to the replay buffer.
+// Subscribers that cannot keep up are dropped rather than slowing the
+// publisher down. Messages republished after a retry are ignored.
+func (b *Broker) Publish(msg Message) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if n := len(b.buffer); n > 0 && msg.ID <= b.buffer[n-1].ID {
+		return
+	}
+	if !b.started {
+		b.floor = msg.ID - 1
+		b.started = true
+	}
+	b.buffer = append(b.buffer, msg)
+	if len(b.buffer) > max(b.Capacity, 1) {
+		b.floor = b.buffer[0].ID
+		b.buffer = b.buffer[1:]
+	}
+	for sub := range b.subscribers {
+		select {
+		case sub.c <- msg:
+		default:
+			delete(b.subscribers, sub)
+			close(sub.c)
+		}
+	}
+}
+
+// Subscribe starts a subscription and returns the buffered messages after
+// lastID, when lastID is not 0. complete is false when messages after
+// lastID have already left the buffer, so the replay has gaps.
+func (b *Broker) Subscribe(lastID int) (sub *Subscription, replay []Message, complete bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c := make(chan Message, subscriberBuffer)
+	sub = &Subscription{C: c, c: c}
+	if b.closed {
+		close(c)
+		return sub, nil, true
+	}
+	if b.subscribers == nil {
+		b.subscribers = map[*Subscription]struct{}{}
+	}
+	b.subscribers[sub] = struct{}{}
+
+	if lastID == 0 {
+		return sub, nil, true
+	}
+	for _, msg := range b.buffer {
+		if msg.ID > lastID {
+			replay = append(replay, msg)
+		}
+	}
+	return sub, replay, b.started && lastID >= b.floor
+}
+
+// Unsubscribe ends a subscription.
+func (b *Broker) Unsubscribe(sub *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.subscribers[sub]; ok {
+		delete(b.subscribers, sub)
+		close(sub.c)
+	}
+}
+
+// Close ends every subscription, for a server that is shutting down.
+func (b *Broker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for sub := range b.subscribers {
+		delete(b.subscribers, sub)
+		close(sub.c)
+	}
+}
@@ -0,0 +1,162 @@
+// Package webhook signs and sends the HTTP callbacks users subscribe to
+// for changes to their events.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Event types a webhook can subscribe to.
+const (
+	EventCreated    = "event.created"
+	EventUpdated    = "event.updated"
+	EventDeleted    = "event.deleted"
+	AttendeeAdded   = "attendee.added"
+	AttendeeRemoved = "attendee.removed"
+	// EventReminder is sent to the webhooks of an attendee, rather than
+	// of the event's organizers, ahead of an event they attend.
+	EventReminder = "event.reminder"
+)
+
+// EventTypes lists every event type in the order they are documented.
+var EventTypes = []string{EventCreated, EventUpdated, EventDeleted, AttendeeAdded, AttendeeRemoved, EventReminder}
+
+// Headers sent with every delivery. IDHeader stays the same across the
+// retries of a delivery so receivers can drop duplicates.
+const (
+	IDHeader        = "Webhook-Id"
+	EventHeader     = "Webhook-Event"
+	SignatureHeader = "Webhook-Signature"
+)
+
+// SignatureTolerance is how old a signed delivery may be before Verify
+// rejects it as a possible replay.
+const SignatureTolerance = 5 * time.Minute
+
+var ErrInvalidSignature = errors.New("invalid webhook signature")
+
+// NewSecret returns a random signing secret.
+func NewSecret() (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(buf), nil
+}
+
+// Sign returns the SignatureHeader value for body sent at time at:
+// "t=<unix seconds>,v1=<hex HMAC-SHA256 of "<t>.<body>">".
+func Sign(secret string, at time.Time, body []byte) string {
+	timestamp := strconv.FormatInt(at.Unix(), 10)
+	return fmt.Sprintf("t=%s,v1=%s", timestamp, sign(secret, timestamp, body))
+}
+
+// Verify checks a SignatureHeader value against body, as a receiver
+// would.
+func Verify(secret, header string, body []byte) error {
+	var timestamp, signature string
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			signature = value
+		}
+	}
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || signature == "" {
+		return ErrInvalidSignature
+	}
+	if age := time.Since(time.Unix(unix, 0)); age > SignatureTolerance || age < -SignatureTolerance {
+		return ErrInvalidSignature
+	}
+	if !hmac.Equal([]byte(signature), []byte(sign(secret, timestamp, body))) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+func sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Request is one delivery attempt.
+type Request struct {
+	URL        string
+	Secret     string
+	DeliveryID int
+	EventType  string
+	Body       []byte
+}
+
+// Result is the outcome of an attempt. Err is set when the endpoint could
+// not be reached or did not answer with a 2xx status.
+type Result struct {
+	StatusCode int
+	Duration   time.Duration
+	Err        error
+}
+
+// Sender posts signed deliveries. The zero value uses a client with a 10
+// second timeout that only connects to public addresses and does not
+// follow redirects; AllowPrivate lifts the address check, for local
+// development. A custom Client is used as is.
+type Sender struct {
+	Client       *http.Client
+	AllowPrivate bool
+}
+
+var (
+	defaultClient = newClient(false)
+	privateClient = newClient(true)
+)
+
+func (s Sender) Send(ctx context.Context, request Request) Result {
+	client := s.Client
+	if client == nil {
+		client = defaultClient
+		if s.AllowPrivate {
+			client = privateClient
+		}
+	}
+	start := time.Now()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, request.URL, bytes.NewReader(request.Body))
+	if err != nil {
+		return Result{Err: err}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "go-restful-api-webhooks/1.0")
+	req.Header.Set(IDHeader, strconv.Itoa(request.DeliveryID))
+	req.Header.Set(EventHeader, request.EventType)
+	req.Header.Set(SignatureHeader, Sign(request.Secret, start, request.Body))
+
+	resp, err := client.Do(req)
+	result := Result{Duration: time.Since(start)}
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	result.StatusCode = resp.StatusCode
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		result.Err = fmt.Errorf("endpoint answered %s", resp.Status)
+	}
+	return result
+}
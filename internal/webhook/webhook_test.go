@@ -0,0 +1,206 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignVerify(t *testing.T) {
+	body := []byte(`{"id":1}`)
+	header := Sign("secret", time.Now(), body)
+
+	if err := Verify("secret", header, body); err != nil {
+		t.Fatalf("Verify of a fresh signature: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		secret string
+		header string
+		body   []byte
+	}{
+		{"tampered body", "secret", header, []byte(`{"id":2}`)},
+		{"wrong secret", "other", header, body},
+		{"stale", "secret", Sign("secret", time.Now().Add(-SignatureTolerance-time.Minute), body), body},
+		{"from the future", "secret", Sign("secret", time.Now().Add(SignatureTolerance+time.Minute), body), body},
+		{"no signature", "secret", "t=" + strconv.FormatInt(time.Now().Unix(), 10), body},
+		{"no timestamp", "secret", "v1=abc", body},
+		{"garbage", "secret", "garbage", body},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Verify(tt.secret, tt.header, tt.body); !errors.Is(err, ErrInvalidSignature) {
+				t.Errorf("Verify = %v, want ErrInvalidSignature", err)
+			}
+		})
+	}
+}
+
+func TestNewSecret(t *testing.T) {
+	a, err := NewSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := NewSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(a, "whsec_") || a == b {
+		t.Errorf("NewSecret returned %q and %q", a, b)
+	}
+}
+
+func TestSend(t *testing.T) {
+	body := []byte(`{"type":"event.created"}`)
+	var got *http.Request
+	var gotBody []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		gotBody, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	result := Sender{AllowPrivate: true}.Send(context.Background(), Request{
+		URL:        srv.URL,
+		Secret:     "secret",
+		DeliveryID: 42,
+		EventType:  EventCreated,
+		Body:       body,
+	})
+	if result.Err != nil || result.StatusCode != http.StatusNoContent {
+		t.Fatalf("Send = %+v, want 204 and no error", result)
+	}
+	if got.Method != http.MethodPost {
+		t.Errorf("method = %s, want POST", got.Method)
+	}
+	if h := got.Header.Get(IDHeader); h != "42" {
+		t.Errorf("%s = %q, want 42", IDHeader, h)
+	}
+	if h := got.Header.Get(EventHeader); h != EventCreated {
+		t.Errorf("%s = %q, want %s", EventHeader, h, EventCreated)
+	}
+	if h := got.Header.Get("Content-Type"); h != "application/json" {
+		t.Errorf("Content-Type = %q", h)
+	}
+	if string(gotBody) != string(body) {
+		t.Errorf("body = %s, want %s", gotBody, body)
+	}
+	if err := Verify("secret", got.Header.Get(SignatureHeader), gotBody); err != nil {
+		t.Errorf("receiver could not verify the signature: %v", err)
+	}
+}
+
+func TestSendErrorStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "boom", http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	result := Sender{AllowPrivate: true}.Send(context.Background(), Request{URL: srv.URL, Secret: "secret"})
+	if result.Err == nil || result.StatusCode != http.StatusInternalServerError {
+		t.Errorf("Send = %+v, want a 500 with an error", result)
+	}
+}
+
+func TestSendDoesNotFollowRedirects(t *testing.T) {
+	redirected := false
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		redirected = true
+	}))
+	defer target.Close()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, target.URL, http.StatusTemporaryRedirect)
+	}))
+	defer srv.Close()
+
+	result := Sender{AllowPrivate: true}.Send(context.Background(), Request{URL: srv.URL, Secret: "secret"})
+	if redirected {
+		t.Error("the redirect was followed")
+	}
+	if result.Err == nil || result.StatusCode != http.StatusTemporaryRedirect {
+		t.Errorf("Send = %+v, want a 307 with an error", result)
+	}
+}
+
+func TestSendRefusesPrivateAddresses(t *testing.T) {
+	called := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer srv.Close()
+
+	result := Sender{}.Send(context.Background(), Request{URL: srv.URL, Secret: "secret"})
+	if called {
+		t.Error("the loopback endpoint was reached")
+	}
+	if !errors.Is(result.Err, ErrPrivateAddress) {
+		t.Errorf("Send error = %v, want ErrPrivateAddress", result.Err)
+	}
+}
+
+func TestIsPublic(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"fe80::1", false},
+		{"fc00::1", false},
+		{"0.0.0.0", false},
+		{"100.64.0.1", false},
+		{"224.0.0.1", false},
+		{"::ffff:127.0.0.1", false},
+		{"64:ff9b::a00:1", false},
+	}
+	for _, tt := range tests {
+		if got := IsPublic(netip.MustParseAddr(tt.addr)); got != tt.want {
+			t.Errorf("IsPublic(%s) = %v, want %v", tt.addr, got, tt.want)
+		}
+	}
+}
+
+func TestCheckURL(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		url     string
+		private bool
+		wantErr error
+		ok      bool
+	}{
+		{url: "https://93.184.216.34/hook", ok: true},
+		{url: "http://127.0.0.1:8080/hook", wantErr: ErrPrivateAddress},
+		{url: "http://[::1]/hook", wantErr: ErrPrivateAddress},
+		{url: "http://169.254.169.254/latest", wantErr: ErrPrivateAddress},
+		{url: "http://127.0.0.1:8080/hook", private: true, ok: true},
+		{url: "ftp://93.184.216.34/hook"},
+		{url: "/relative"},
+		{url: "https:///nohost"},
+	}
+	for _, tt := range tests {
+		err := Sender{AllowPrivate: tt.private}.CheckURL(ctx, tt.url)
+		switch {
+		case tt.ok && err != nil:
+			t.Errorf("CheckURL(%q) = %v, want nil", tt.url, err)
+		case !tt.ok && err == nil:
+			t.Errorf("CheckURL(%q) = nil, want an error", tt.url)
+		case tt.wantErr != nil && !errors.Is(err, tt.wantErr):
+			t.Errorf("CheckURL(%q) = %v, want %v", tt.url, err, tt.wantErr)
+		}
+	}
+}